@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	saveAnswers = flag.String("save-answers", "", "Write every answer section to the output directory (text|json)")
+)
+
+type answerRecord struct {
+	Name string     `json:"name"`
+	Type string     `json:"type"`
+	TTL  uint32     `json:"ttl"`
+	Data string     `json:"data"`
+	Svc  *svcRecord `json:"svc,omitempty"`
+}
+
+type answerEntry struct {
+	Domain  string         `json:"domain"`
+	Rcode   string         `json:"rcode"`
+	Answers []answerRecord `json:"answers"`
+}
+
+var (
+	answerFile   *os.File
+	answerWriter *bufio.Writer
+)
+
+func openAnswers() error {
+	var ext string
+	switch *saveAnswers {
+	case "":
+		return nil
+	case "text":
+		ext = "txt"
+	case "json":
+		ext = "jsonl"
+	default:
+		return fmt.Errorf("unknown answer format %q", *saveAnswers)
+	}
+
+	f, err := createOutputFile("answers", ext)
+	if err != nil {
+		return err
+	}
+	answerFile = f
+	answerWriter = bufio.NewWriter(f)
+	return nil
+}
+
+// rrData returns the presentation format of an RR without its header
+func rrData(rr dns.RR) string {
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+func normalizeRR(rr dns.RR) answerRecord {
+	h := rr.Header()
+	a := answerRecord{
+		Name: strings.ToLower(h.Name),
+		Type: typeName(h.Rrtype),
+		TTL:  h.Ttl,
+		Data: rrData(rr),
+	}
+	if svc, ok := parseSVCB(rr); ok {
+		a.Data, a.Svc = svc.String(), svc
+	}
+	return a
+}
+
+func writeAnswers(domain string, msg *dns.Msg) {
+	if answerWriter == nil {
+		return
+	}
+
+	entry := answerEntry{
+		Domain:  domain,
+		Rcode:   rcodeName(msg.Rcode),
+		Answers: make([]answerRecord, 0, len(msg.Answer)),
+	}
+	for _, a := range msg.Answer {
+		entry.Answers = append(entry.Answers, normalizeRR(a))
+	}
+
+	if *saveAnswers == "json" {
+		b, _ := json.Marshal(entry)
+		answerWriter.Write(b)
+		answerWriter.WriteByte('\n')
+		return
+	}
+
+	if len(entry.Answers) == 0 {
+		fmt.Fprintf(answerWriter, "%v\t%v\n", domain, entry.Rcode)
+	}
+	for _, a := range entry.Answers {
+		fmt.Fprintf(answerWriter, "%v\t%v\t%v\t%v\t%v\n",
+			domain, a.Name, a.TTL, a.Type, a.Data)
+	}
+}
+
+func closeAnswers() {
+	if answerWriter == nil {
+		return
+	}
+	answerWriter.Flush()
+	answerFile.Close()
+	answerWriter = nil
+}
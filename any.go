@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// anyHandling buckets of the responses to ANY queries
+const (
+	anyFull     = "Full"
+	anyMinimal  = "RFC 8482"
+	anySubset   = "Single Type"
+	anyRefused  = "Refused"
+	anyEmpty    = "Empty"
+	anyTruncate = "Truncated"
+)
+
+var anyOrder = []string{anyFull, anySubset, anyMinimal, anyTruncate, anyEmpty, anyRefused}
+
+var anyResponses map[string]int
+
+// classifyANY tells how a server handled an ANY query. RFC 8482 lets it
+// answer with a single synthesized HINFO or with one RRset of its choice
+// instead of everything it has.
+func classifyANY(msg *dns.Msg) string {
+	switch {
+	case msg.Rcode == dns.RcodeRefused || msg.Rcode == dns.RcodeNotImplemented:
+		return anyRefused
+	case msg.Truncated:
+		return anyTruncate
+	case len(msg.Answer) == 0:
+		return anyEmpty
+	}
+
+	types := make(map[uint16]bool)
+	for _, a := range msg.Answer {
+		if h, ok := a.(*dns.HINFO); ok && len(msg.Answer) == 1 &&
+			strings.EqualFold(h.Cpu, "RFC8482") {
+			return anyMinimal
+		}
+		if t := a.Header().Rrtype; t != dns.TypeRRSIG && t != dns.TypeCNAME {
+			types[t] = true
+		}
+	}
+	if len(types) > 1 {
+		return anyFull
+	}
+	return anySubset
+}
+
+func recordANY(qtype uint16, msg *dns.Msg) {
+	if qtype != dns.TypeANY {
+		return
+	}
+	anyResponses[classifyANY(msg)]++
+}
+
+func anyStats() {
+	total := 0
+	for _, n := range anyResponses {
+		total += n
+	}
+	if total == 0 {
+		return
+	}
+	fmt.Printf("[+] ANY Handling:\n")
+	for _, k := range anyOrder {
+		if n := anyResponses[k]; n > 0 {
+			fmt.Printf("[+]   %-16s%v (%.1f%%)\n", k+":", n, float64(n)/float64(total)*100)
+		}
+	}
+}
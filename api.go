@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"time"
+)
+
+var (
+	apiAddr = flag.String("api", "", "Serve the REST API of the monitor command on this address")
+)
+
+type runStatus struct {
+	Running  bool        `json:"running"`
+	Started  *time.Time  `json:"started,omitempty"`
+	Attempts int         `json:"attempts,omitempty"`
+	Success  int         `json:"success,omitempty"`
+	Fail     int         `json:"fail,omitempty"`
+	Runs     int         `json:"runs"`
+	NextRun  time.Time   `json:"next_run"`
+	LastRun  *runSummary `json:"last_run,omitempty"`
+}
+
+// apiHandler routes the monitor's REST API
+//
+//	GET  /status          state of the monitor and the run in progress
+//	POST /runs            start a run now instead of waiting for the schedule
+//	POST /stop            abort the run in progress
+//	GET  /results         every recorded run summary
+//	GET  /results/latest  the most recent run summary
+//	GET  /graphs/         graphs and output files of all runs
+//	GET  /series          rate samples of the current run
+//	GET  /latency         latency percentiles of the current run by second
+//	GET  /subnets         recorded runs grouped by client subnet
+//	GET  /metrics         Prometheus metrics
+//	GET  /                web dashboard
+func apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", apiStatus)
+	mux.HandleFunc("/runs", apiStart)
+	mux.HandleFunc("/stop", apiStop)
+	mux.HandleFunc("/results", apiResults)
+	mux.HandleFunc("/results/latest", apiLatest)
+	mux.HandleFunc("/series", apiSeries)
+	mux.HandleFunc("/latency", apiLatency)
+	mux.HandleFunc("/subnets", apiSubnets)
+	mux.HandleFunc("/metrics", serveMetrics)
+	mux.HandleFunc("/", serveDashboard)
+	mux.Handle("/graphs/", http.StripPrefix("/graphs/", http.FileServer(http.Dir(*outputDir))))
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+func currentStatus() runStatus {
+	monitorMu.Lock()
+	defer monitorMu.Unlock()
+
+	s := runStatus{
+		Running: cancelRun != nil,
+		Runs:    monitorRuns,
+		NextRun: nextRun,
+		LastRun: lastRun,
+	}
+	if s.Running {
+		started := runStart
+		s.Started = &started
+		statsMu.Lock()
+		s.Attempts, s.Success, s.Fail = stats.attempts, stats.success, stats.fail
+		statsMu.Unlock()
+	}
+	return s
+}
+
+func apiStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	writeJSON(w, http.StatusOK, currentStatus())
+}
+
+var (
+	errRunActive = errors.New("a run is already in progress")
+	errNoRun     = errors.New("no run in progress")
+)
+
+// startRun wakes the monitor so the next run starts without waiting for
+// the schedule
+func startRun() error {
+	if currentStatus().Running {
+		return errRunActive
+	}
+	select {
+	case runNow <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func stopRun() error {
+	monitorMu.Lock()
+	cancel := cancelRun
+	monitorMu.Unlock()
+	if cancel == nil {
+		return errNoRun
+	}
+	cancel()
+	return nil
+}
+
+func apiStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	if err := startRun(); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, currentStatus())
+}
+
+func apiStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	if err := stopRun(); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, currentStatus())
+}
+
+func apiResults(w http.ResponseWriter, r *http.Request) {
+	h, err := loadHistory()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if h == nil {
+		h = []runSummary{}
+	}
+	writeJSON(w, http.StatusOK, h)
+}
+
+func apiLatest(w http.ResponseWriter, r *http.Request) {
+	s := currentStatus()
+	if s.LastRun == nil {
+		writeError(w, http.StatusNotFound, "no run recorded yet")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.LastRun)
+}
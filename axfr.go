@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	axfrRun = flag.Bool("axfr-run", false, "Benchmark the nameserver with the names of the transferred zone")
+)
+
+type transferResult struct {
+	records []dns.RR
+	msgs    int
+	bytes   int
+	elapsed time.Duration
+}
+
+// transfer runs a zone transfer of m against addr, TSIG signed when a
+// key is configured
+func transfer(m *dns.Msg, addr string) (transferResult, error) {
+	t := &dns.Transfer{DialTimeout: retryDelay, ReadTimeout: 10 * retryDelay}
+	if tsig != nil {
+		t.TsigSecret = tsig.secrets()
+		tsig.sign(m)
+	}
+
+	var res transferResult
+	start := time.Now()
+	env, err := t.In(m, addr)
+	if err != nil {
+		return res, err
+	}
+	for e := range env {
+		if e.Error != nil {
+			return res, e.Error
+		}
+		res.msgs++
+		for _, rr := range e.RR {
+			res.bytes += dns.Len(rr)
+		}
+		res.records = append(res.records, e.RR...)
+	}
+	res.elapsed = time.Since(start)
+	return res, nil
+}
+
+// ownerNames returns the distinct owner names of the records without their
+// trailing dot, the way domain lists are read
+func ownerNames(records []dns.RR) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, rr := range records {
+		n := strings.ToLower(rr.Header().Name)
+		if seen[n] || strings.HasPrefix(n, "*.") {
+			continue
+		}
+		seen[n] = true
+		names = append(names, strings.TrimSuffix(n, "."))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runAXFR transfers the zone from each given server, or the nameserver,
+// and writes it to the output directory
+func runAXFR(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected a zone and optionally the servers to transfer it from")
+	}
+	zone := dns.Fqdn(strings.ToLower(args[0]))
+	servers := args[1:]
+	if len(servers) == 0 {
+		servers = []string{*nameserver}
+	}
+
+	var names []string
+	for _, s := range servers {
+		fmt.Printf("Zone Transfer\n"+
+			"[+] Zone:             %v\n"+
+			"[+] Server:           %v\n"+
+			"[+] TSIG:             %v\n\n", zone, s, tsig != nil)
+
+		m := new(dns.Msg)
+		m.SetAxfr(zone)
+		res, err := transfer(m, net.JoinHostPort(s, "53"))
+		if err != nil {
+			fmt.Printf("[-] Transfer failed:  %v\n\n", err)
+			continue
+		}
+
+		f, err := createOutputFile("axfr", "zone")
+		if err != nil {
+			return err
+		}
+		for _, rr := range res.records {
+			fmt.Fprintln(f, rr)
+		}
+		f.Close()
+
+		var serial uint32
+		if len(res.records) > 0 {
+			if soa, ok := res.records[0].(*dns.SOA); ok {
+				serial = soa.Serial
+			}
+		}
+		zn := ownerNames(res.records)
+		fmt.Printf("[+] Serial:           %v\n"+
+			"[+] Records:          %v\n"+
+			"[+] Names:            %v\n"+
+			"[+] Messages:         %v\n"+
+			"[+] Size:             %v bytes\n"+
+			"[+] Elapsed Time:     %v\n"+
+			"[+] Zone File:        %v\n\n",
+			serial, len(res.records), len(zn), res.msgs, res.bytes,
+			res.elapsed.Round(time.Millisecond), f.Name())
+		if names == nil {
+			names = zn
+		}
+	}
+
+	if !*axfrRun {
+		return nil
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no names transferred")
+	}
+	shardDomains = names
+	getBanner(sendingDelay, retryDelay, clientLabel())
+	completed, err := runBenchmark()
+	if err != nil {
+		return err
+	}
+	finalStats()
+	sum := summarize(completed)
+	notifyRun(sum)
+	writeSummaryJSON(sum)
+	return nil
+}
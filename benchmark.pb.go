@@ -0,0 +1,627 @@
+// Control and results service of the monitor command, served with -grpc
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.0
+// 	protoc        (unknown)
+// source: benchmark.proto
+
+package main
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Empty struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *Empty) Reset() {
+	*x = Empty{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_benchmark_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Empty) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Empty) ProtoMessage() {}
+
+func (x *Empty) ProtoReflect() protoreflect.Message {
+	mi := &file_benchmark_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Empty.ProtoReflect.Descriptor instead.
+func (*Empty) Descriptor() ([]byte, []int) {
+	return file_benchmark_proto_rawDescGZIP(), []int{0}
+}
+
+type RunStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Running     bool  `protobuf:"varint,1,opt,name=running,proto3" json:"running,omitempty"`
+	StartedUnix int64 `protobuf:"varint,2,opt,name=started_unix,json=startedUnix,proto3" json:"started_unix,omitempty"`
+	Attempts    int32 `protobuf:"varint,3,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	Success     int32 `protobuf:"varint,4,opt,name=success,proto3" json:"success,omitempty"`
+	Fail        int32 `protobuf:"varint,5,opt,name=fail,proto3" json:"fail,omitempty"`
+	Runs        int32 `protobuf:"varint,6,opt,name=runs,proto3" json:"runs,omitempty"`
+	NextRunUnix int64 `protobuf:"varint,7,opt,name=next_run_unix,json=nextRunUnix,proto3" json:"next_run_unix,omitempty"`
+}
+
+func (x *RunStatus) Reset() {
+	*x = RunStatus{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_benchmark_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RunStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunStatus) ProtoMessage() {}
+
+func (x *RunStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_benchmark_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunStatus.ProtoReflect.Descriptor instead.
+func (*RunStatus) Descriptor() ([]byte, []int) {
+	return file_benchmark_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RunStatus) GetRunning() bool {
+	if x != nil {
+		return x.Running
+	}
+	return false
+}
+
+func (x *RunStatus) GetStartedUnix() int64 {
+	if x != nil {
+		return x.StartedUnix
+	}
+	return 0
+}
+
+func (x *RunStatus) GetAttempts() int32 {
+	if x != nil {
+		return x.Attempts
+	}
+	return 0
+}
+
+func (x *RunStatus) GetSuccess() int32 {
+	if x != nil {
+		return x.Success
+	}
+	return 0
+}
+
+func (x *RunStatus) GetFail() int32 {
+	if x != nil {
+		return x.Fail
+	}
+	return 0
+}
+
+func (x *RunStatus) GetRuns() int32 {
+	if x != nil {
+		return x.Runs
+	}
+	return 0
+}
+
+func (x *RunStatus) GetNextRunUnix() int64 {
+	if x != nil {
+		return x.NextRunUnix
+	}
+	return 0
+}
+
+type QueryResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Domain       string   `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Rcode        string   `protobuf:"bytes,2,opt,name=rcode,proto3" json:"rcode,omitempty"`
+	Ips          []string `protobuf:"bytes,3,rep,name=ips,proto3" json:"ips,omitempty"`
+	Tries        int32    `protobuf:"varint,4,opt,name=tries,proto3" json:"tries,omitempty"`
+	TimeUnixNano int64    `protobuf:"varint,5,opt,name=time_unix_nano,json=timeUnixNano,proto3" json:"time_unix_nano,omitempty"`
+	LatencyMs    float64  `protobuf:"fixed64,6,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
+	// server identifies the answering server by NSID or hostname.bind
+	Server string `protobuf:"bytes,7,opt,name=server,proto3" json:"server,omitempty"`
+}
+
+func (x *QueryResult) Reset() {
+	*x = QueryResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_benchmark_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *QueryResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryResult) ProtoMessage() {}
+
+func (x *QueryResult) ProtoReflect() protoreflect.Message {
+	mi := &file_benchmark_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryResult.ProtoReflect.Descriptor instead.
+func (*QueryResult) Descriptor() ([]byte, []int) {
+	return file_benchmark_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *QueryResult) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *QueryResult) GetRcode() string {
+	if x != nil {
+		return x.Rcode
+	}
+	return ""
+}
+
+func (x *QueryResult) GetIps() []string {
+	if x != nil {
+		return x.Ips
+	}
+	return nil
+}
+
+func (x *QueryResult) GetTries() int32 {
+	if x != nil {
+		return x.Tries
+	}
+	return 0
+}
+
+func (x *QueryResult) GetTimeUnixNano() int64 {
+	if x != nil {
+		return x.TimeUnixNano
+	}
+	return 0
+}
+
+func (x *QueryResult) GetLatencyMs() float64 {
+	if x != nil {
+		return x.LatencyMs
+	}
+	return 0
+}
+
+func (x *QueryResult) GetServer() string {
+	if x != nil {
+		return x.Server
+	}
+	return ""
+}
+
+var File_benchmark_proto protoreflect.FileDescriptor
+
+var file_benchmark_proto_rawDesc = []byte{
+	0x0a, 0x0f, 0x62, 0x65, 0x6e, 0x63, 0x68, 0x6d, 0x61, 0x72, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x12, 0x09, 0x64, 0x6e, 0x73, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x22, 0x07, 0x0a, 0x05,
+	0x45, 0x6d, 0x70, 0x74, 0x79, 0x22, 0xca, 0x01, 0x0a, 0x09, 0x52, 0x75, 0x6e, 0x53, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x75, 0x6e, 0x6e, 0x69, 0x6e, 0x67, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x72, 0x75, 0x6e, 0x6e, 0x69, 0x6e, 0x67, 0x12, 0x21, 0x0a,
+	0x0c, 0x73, 0x74, 0x61, 0x72, 0x74, 0x65, 0x64, 0x5f, 0x75, 0x6e, 0x69, 0x78, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x0b, 0x73, 0x74, 0x61, 0x72, 0x74, 0x65, 0x64, 0x55, 0x6e, 0x69, 0x78,
+	0x12, 0x1a, 0x0a, 0x08, 0x61, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x73, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x08, 0x61, 0x74, 0x74, 0x65, 0x6d, 0x70, 0x74, 0x73, 0x12, 0x18, 0x0a, 0x07,
+	0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x73,
+	0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x61, 0x69, 0x6c, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x66, 0x61, 0x69, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x75,
+	0x6e, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x72, 0x75, 0x6e, 0x73, 0x12, 0x22,
+	0x0a, 0x0d, 0x6e, 0x65, 0x78, 0x74, 0x5f, 0x72, 0x75, 0x6e, 0x5f, 0x75, 0x6e, 0x69, 0x78, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x6e, 0x65, 0x78, 0x74, 0x52, 0x75, 0x6e, 0x55, 0x6e,
+	0x69, 0x78, 0x22, 0xc0, 0x01, 0x0a, 0x0b, 0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x65, 0x73, 0x75,
+	0x6c, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x64, 0x6f, 0x6d, 0x61, 0x69, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x72, 0x63,
+	0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x72, 0x63, 0x6f, 0x64, 0x65,
+	0x12, 0x10, 0x0a, 0x03, 0x69, 0x70, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x03, 0x69,
+	0x70, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x05, 0x74, 0x72, 0x69, 0x65, 0x73, 0x12, 0x24, 0x0a, 0x0e, 0x74, 0x69, 0x6d, 0x65,
+	0x5f, 0x75, 0x6e, 0x69, 0x78, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x0c, 0x74, 0x69, 0x6d, 0x65, 0x55, 0x6e, 0x69, 0x78, 0x4e, 0x61, 0x6e, 0x6f, 0x12, 0x1d,
+	0x0a, 0x0a, 0x6c, 0x61, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x5f, 0x6d, 0x73, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x01, 0x52, 0x09, 0x6c, 0x61, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x4d, 0x73, 0x12, 0x16, 0x0a,
+	0x06, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73,
+	0x65, 0x72, 0x76, 0x65, 0x72, 0x32, 0xdb, 0x01, 0x0a, 0x09, 0x42, 0x65, 0x6e, 0x63, 0x68, 0x6d,
+	0x61, 0x72, 0x6b, 0x12, 0x30, 0x0a, 0x06, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x10, 0x2e,
+	0x64, 0x6e, 0x73, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a,
+	0x14, 0x2e, 0x64, 0x6e, 0x73, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x2e, 0x52, 0x75, 0x6e, 0x53,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x32, 0x0a, 0x08, 0x53, 0x74, 0x61, 0x72, 0x74, 0x52, 0x75,
+	0x6e, 0x12, 0x10, 0x2e, 0x64, 0x6e, 0x73, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x2e, 0x45, 0x6d,
+	0x70, 0x74, 0x79, 0x1a, 0x14, 0x2e, 0x64, 0x6e, 0x73, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x2e,
+	0x52, 0x75, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x31, 0x0a, 0x07, 0x53, 0x74, 0x6f,
+	0x70, 0x52, 0x75, 0x6e, 0x12, 0x10, 0x2e, 0x64, 0x6e, 0x73, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74,
+	0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x14, 0x2e, 0x64, 0x6e, 0x73, 0x63, 0x6c, 0x69, 0x65,
+	0x6e, 0x74, 0x2e, 0x52, 0x75, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x35, 0x0a, 0x07,
+	0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x12, 0x10, 0x2e, 0x64, 0x6e, 0x73, 0x63, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x16, 0x2e, 0x64, 0x6e, 0x73, 0x63,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x2e, 0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x30, 0x01, 0x42, 0x32, 0x5a, 0x30, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2f, 0x72, 0x74, 0x6d, 0x6f, 0x72, 0x61, 0x6e, 0x6f, 0x72, 0x67, 0x2f, 0x64, 0x6e, 0x73,
+	0x2d, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x2d, 0x73, 0x75, 0x62, 0x6e, 0x65, 0x74, 0x2d, 0x65,
+	0x78, 0x74, 0x3b, 0x6d, 0x61, 0x69, 0x6e, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_benchmark_proto_rawDescOnce sync.Once
+	file_benchmark_proto_rawDescData = file_benchmark_proto_rawDesc
+)
+
+func file_benchmark_proto_rawDescGZIP() []byte {
+	file_benchmark_proto_rawDescOnce.Do(func() {
+		file_benchmark_proto_rawDescData = protoimpl.X.CompressGZIP(file_benchmark_proto_rawDescData)
+	})
+	return file_benchmark_proto_rawDescData
+}
+
+var file_benchmark_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_benchmark_proto_goTypes = []interface{}{
+	(*Empty)(nil),       // 0: dnsclient.Empty
+	(*RunStatus)(nil),   // 1: dnsclient.RunStatus
+	(*QueryResult)(nil), // 2: dnsclient.QueryResult
+}
+var file_benchmark_proto_depIdxs = []int32{
+	0, // 0: dnsclient.Benchmark.Status:input_type -> dnsclient.Empty
+	0, // 1: dnsclient.Benchmark.StartRun:input_type -> dnsclient.Empty
+	0, // 2: dnsclient.Benchmark.StopRun:input_type -> dnsclient.Empty
+	0, // 3: dnsclient.Benchmark.Results:input_type -> dnsclient.Empty
+	1, // 4: dnsclient.Benchmark.Status:output_type -> dnsclient.RunStatus
+	1, // 5: dnsclient.Benchmark.StartRun:output_type -> dnsclient.RunStatus
+	1, // 6: dnsclient.Benchmark.StopRun:output_type -> dnsclient.RunStatus
+	2, // 7: dnsclient.Benchmark.Results:output_type -> dnsclient.QueryResult
+	4, // [4:8] is the sub-list for method output_type
+	0, // [0:4] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_benchmark_proto_init() }
+func file_benchmark_proto_init() {
+	if File_benchmark_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_benchmark_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Empty); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_benchmark_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RunStatus); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_benchmark_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*QueryResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_benchmark_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_benchmark_proto_goTypes,
+		DependencyIndexes: file_benchmark_proto_depIdxs,
+		MessageInfos:      file_benchmark_proto_msgTypes,
+	}.Build()
+	File_benchmark_proto = out.File
+	file_benchmark_proto_rawDesc = nil
+	file_benchmark_proto_goTypes = nil
+	file_benchmark_proto_depIdxs = nil
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// BenchmarkClient is the client API for Benchmark service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type BenchmarkClient interface {
+	// Status reports the state of the monitor and the run in progress
+	Status(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*RunStatus, error)
+	// StartRun starts a run now instead of waiting for the schedule
+	StartRun(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*RunStatus, error)
+	// StopRun aborts the run in progress
+	StopRun(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*RunStatus, error)
+	// Results streams the outcome of every query until the client cancels
+	Results(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Benchmark_ResultsClient, error)
+}
+
+type benchmarkClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBenchmarkClient(cc grpc.ClientConnInterface) BenchmarkClient {
+	return &benchmarkClient{cc}
+}
+
+func (c *benchmarkClient) Status(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*RunStatus, error) {
+	out := new(RunStatus)
+	err := c.cc.Invoke(ctx, "/dnsclient.Benchmark/Status", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *benchmarkClient) StartRun(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*RunStatus, error) {
+	out := new(RunStatus)
+	err := c.cc.Invoke(ctx, "/dnsclient.Benchmark/StartRun", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *benchmarkClient) StopRun(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*RunStatus, error) {
+	out := new(RunStatus)
+	err := c.cc.Invoke(ctx, "/dnsclient.Benchmark/StopRun", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *benchmarkClient) Results(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Benchmark_ResultsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Benchmark_serviceDesc.Streams[0], "/dnsclient.Benchmark/Results", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &benchmarkResultsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Benchmark_ResultsClient interface {
+	Recv() (*QueryResult, error)
+	grpc.ClientStream
+}
+
+type benchmarkResultsClient struct {
+	grpc.ClientStream
+}
+
+func (x *benchmarkResultsClient) Recv() (*QueryResult, error) {
+	m := new(QueryResult)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// BenchmarkServer is the server API for Benchmark service.
+type BenchmarkServer interface {
+	// Status reports the state of the monitor and the run in progress
+	Status(context.Context, *Empty) (*RunStatus, error)
+	// StartRun starts a run now instead of waiting for the schedule
+	StartRun(context.Context, *Empty) (*RunStatus, error)
+	// StopRun aborts the run in progress
+	StopRun(context.Context, *Empty) (*RunStatus, error)
+	// Results streams the outcome of every query until the client cancels
+	Results(*Empty, Benchmark_ResultsServer) error
+}
+
+// UnimplementedBenchmarkServer can be embedded to have forward compatible implementations.
+type UnimplementedBenchmarkServer struct {
+}
+
+func (*UnimplementedBenchmarkServer) Status(context.Context, *Empty) (*RunStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (*UnimplementedBenchmarkServer) StartRun(context.Context, *Empty) (*RunStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartRun not implemented")
+}
+func (*UnimplementedBenchmarkServer) StopRun(context.Context, *Empty) (*RunStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopRun not implemented")
+}
+func (*UnimplementedBenchmarkServer) Results(*Empty, Benchmark_ResultsServer) error {
+	return status.Errorf(codes.Unimplemented, "method Results not implemented")
+}
+
+func RegisterBenchmarkServer(s *grpc.Server, srv BenchmarkServer) {
+	s.RegisterService(&_Benchmark_serviceDesc, srv)
+}
+
+func _Benchmark_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BenchmarkServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dnsclient.Benchmark/Status",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BenchmarkServer).Status(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Benchmark_StartRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BenchmarkServer).StartRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dnsclient.Benchmark/StartRun",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BenchmarkServer).StartRun(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Benchmark_StopRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BenchmarkServer).StopRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dnsclient.Benchmark/StopRun",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BenchmarkServer).StopRun(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Benchmark_Results_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BenchmarkServer).Results(m, &benchmarkResultsServer{stream})
+}
+
+type Benchmark_ResultsServer interface {
+	Send(*QueryResult) error
+	grpc.ServerStream
+}
+
+type benchmarkResultsServer struct {
+	grpc.ServerStream
+}
+
+func (x *benchmarkResultsServer) Send(m *QueryResult) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Benchmark_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "dnsclient.Benchmark",
+	HandlerType: (*BenchmarkServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Status",
+			Handler:    _Benchmark_Status_Handler,
+		},
+		{
+			MethodName: "StartRun",
+			Handler:    _Benchmark_StartRun_Handler,
+		},
+		{
+			MethodName: "StopRun",
+			Handler:    _Benchmark_StopRun_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Results",
+			Handler:       _Benchmark_Results_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "benchmark.proto",
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	breakerPct   = flag.Float64("breaker-pct", 0, "Failure percentage of recent attempts that stops queries to the primary in favour of -secondary, 0 disables")
+	breakerProbe = flag.Duration("breaker-probe", 2*time.Second, "Interval a tripped nameserver gets a single query to test whether it recovered")
+)
+
+// breakerWindow is the number of recent attempts the failure rate is taken
+// over
+const breakerWindow = 20
+
+// breaker tracks the health of a nameserver, once open new queries go
+// elsewhere and only an occasional probe is let through until one succeeds
+type breaker struct {
+	recent    []bool
+	open      bool
+	lastProbe time.Time
+	trips     int
+	diverted  int
+	openFor   time.Duration
+	openedAt  time.Time
+}
+
+// breakers of the primary and secondary nameserver
+var breakers [2]*breaker
+
+func serverIndex(socket int) int {
+	if onSecondary(socket) {
+		return 1
+	}
+	return 0
+}
+
+func (b *breaker) record(ok bool) {
+	if *breakerPct <= 0 {
+		return
+	}
+	if b.open {
+		if ok {
+			b.open = false
+			b.recent = b.recent[:0]
+			b.openFor += time.Since(b.openedAt)
+		}
+		return
+	}
+
+	b.recent = append(b.recent, ok)
+	if len(b.recent) > breakerWindow {
+		b.recent = b.recent[1:]
+	}
+	if len(b.recent) < breakerWindow {
+		return
+	}
+	failed := 0
+	for _, r := range b.recent {
+		if !r {
+			failed++
+		}
+	}
+	if float64(failed)/float64(len(b.recent))*100 >= *breakerPct {
+		b.open = true
+		b.trips++
+		b.openedAt = time.Now()
+		b.lastProbe = b.openedAt
+		if *verbose {
+			fmt.Fprintf(os.Stderr, "breaker open (%d of %d failed)\n", failed, len(b.recent))
+		}
+	}
+}
+
+// allow tells whether a new query may go to the nameserver, an open
+// breaker lets one through every probe interval
+func (b *breaker) allow() bool {
+	if !b.open {
+		return true
+	}
+	if time.Since(b.lastProbe) >= *breakerProbe {
+		b.lastProbe = time.Now()
+		return true
+	}
+	return false
+}
+
+// routeQuery picks the socket of a new query, moving it to the secondary
+// while the primary's breaker is open
+func routeQuery(socket int) int {
+	if *secondary == "" || *breakerPct <= 0 || breakers[0].allow() {
+		return socket
+	}
+	breakers[0].diverted++
+	return socket + querySockets()
+}
+
+func recordHealth(socket int, ok bool) {
+	breakers[serverIndex(socket)].record(ok)
+}
+
+func resetBreakers() {
+	for i := range breakers {
+		breakers[i] = &breaker{}
+	}
+}
+
+func breakerStats() {
+	b := breakers[0]
+	if *secondary == "" || *breakerPct <= 0 {
+		return
+	}
+	openFor := b.openFor
+	if b.open {
+		openFor += time.Since(b.openedAt)
+	}
+	fmt.Printf("[+] Circuit Breaker:  %v trips\n"+
+		"[+]   %-16s%v\n"+
+		"[+]   %-16s%v\n",
+		b.trips, "Diverted:", b.diverted, "Open For:", openFor.Round(time.Millisecond))
+}
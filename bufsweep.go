@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+var (
+	bufSize = flag.Int("bufsize", 0, "EDNS UDP buffer size advertised in queries, 0 sends EDNS only with a client subnet")
+)
+
+// sweepSizes are the buffer sizes bufsweep runs with by default, from the
+// classic limit over the DNS flag day value to the common maximum
+var sweepSizes = []int{512, 1232, 1472, 4096}
+
+type sweepRun struct {
+	size    int
+	summary runSummary
+	p50     string
+}
+
+// clean is the number of answers that arrived whole over UDP
+func (r sweepRun) clean() int {
+	return r.summary.Success - r.summary.Truncated
+}
+
+// recommendSize picks the run with the most complete answers, the smaller
+// buffer wins a tie as it is less likely to need fragmentation
+func recommendSize(runs []sweepRun) sweepRun {
+	best := runs[0]
+	for _, r := range runs[1:] {
+		if r.clean() > best.clean() || r.clean() == best.clean() && r.size < best.size {
+			best = r
+		}
+	}
+	return best
+}
+
+// runBufSweep repeats the benchmark with every buffer size and compares
+// success, truncation and latency between them
+func runBufSweep(args []string) error {
+	if *domainList == "" {
+		return fmt.Errorf("missing required domain list")
+	}
+	sizes := sweepSizes
+	if len(args) > 0 {
+		sizes = nil
+		for _, a := range args {
+			n, err := strconv.Atoi(a)
+			if err != nil || n < 512 || n > 65535 {
+				return fmt.Errorf("invalid buffer size %v", a)
+			}
+			sizes = append(sizes, n)
+		}
+	}
+
+	var runs []sweepRun
+	for _, size := range sizes {
+		*bufSize = size
+		findings = nil
+
+		getBanner(sendingDelay, retryDelay, clientLabel())
+		fmt.Printf("[+] Buffer Size:      %v\n\n", size)
+		completed, err := runBenchmark()
+		if err != nil {
+			return err
+		}
+		finalStats()
+		fmt.Println()
+
+		runs = append(runs, sweepRun{size, summarize(completed), formatMs(latencyPercentile(50))})
+	}
+
+	fmt.Printf("Buffer Size Sweep\n")
+	fmt.Printf("[+] %-10s%9v%9v%11v%11v%11v\n", "Size", "Success", "Fail", "Truncated", "p50", "p95")
+	for _, r := range runs {
+		fmt.Printf("[+] %-10v%9v%9v%11v%11v%11v\n", r.size, r.summary.Success, r.summary.Fail,
+			r.summary.Truncated, r.p50, formatMs(msDuration(r.summary.LatencyP95)))
+	}
+	best := recommendSize(runs)
+	fmt.Printf("\n[+] Recommended:      %v bytes (%v complete answers)\n", best.size, best.clean())
+	return nil
+}
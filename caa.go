@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+)
+
+type caaResult struct {
+	domain    string
+	at        string
+	issue     []string
+	issuewild []string
+	iodef     []string
+	err       error
+}
+
+// caaIssuer returns the issuer domain of an issue or issuewild value, an
+// empty one forbids issuance altogether
+func caaIssuer(value string) string {
+	v := strings.TrimSpace(strings.SplitN(value, ";", 2)[0])
+	if v == "" {
+		return "(none)"
+	}
+	return strings.ToLower(v)
+}
+
+func parentName(name string) string {
+	if i := strings.Index(name, "."); i >= 0 && i < len(name)-1 {
+		return name[i+1:]
+	}
+	return "."
+}
+
+// lookupCAA finds the CAA set relevant to domain, climbing towards the
+// registered domain like a CA does until a name has CAA records
+func lookupCAA(domain string) caaResult {
+	name := dns.Fqdn(strings.ToLower(domain))
+	res := caaResult{domain: name}
+
+	registered, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimSuffix(name, "."))
+	if err != nil {
+		registered = name
+	}
+	registered = dns.Fqdn(registered)
+
+	for {
+		r, _, err := exchange(name, dns.TypeCAA, dns.ClassINET)
+		if err != nil {
+			res.err = err
+			return res
+		}
+		for _, a := range r.Answer {
+			c, ok := a.(*dns.CAA)
+			if !ok {
+				continue
+			}
+			res.at = name
+			switch strings.ToLower(c.Tag) {
+			case "issue":
+				res.issue = append(res.issue, caaIssuer(c.Value))
+			case "issuewild":
+				res.issuewild = append(res.issuewild, caaIssuer(c.Value))
+			case "iodef":
+				res.iodef = append(res.iodef, c.Value)
+			}
+		}
+		if res.at != "" || name == registered || name == "." {
+			return res
+		}
+		name = parentName(name)
+	}
+}
+
+func printIssuers(title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	names := make([]string, 0, len(counts))
+	for n := range counts {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	fmt.Printf("[+] %v\n", title)
+	for _, n := range names {
+		fmt.Printf("[+]   %-24s%v\n", n+":", counts[n])
+	}
+}
+
+// runCAA audits the certificate issuance policy of every listed domain
+func runCAA(args []string) error {
+	in := args
+	if len(in) == 0 {
+		var err error
+		if in, err = GetDomains(*domainList); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("CAA Audit\n"+
+		"[+] Nameserver:       %v\n"+
+		"[+] Domains:          %v\n\n", *nameserver, len(in))
+
+	work := make(chan string)
+	results := make(chan caaResult)
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range work {
+				results <- lookupCAA(d)
+			}
+		}()
+	}
+	go func() {
+		for _, d := range in {
+			work <- d
+			time.Sleep(sendingDelay)
+		}
+		close(work)
+		wg.Wait()
+		close(results)
+	}()
+
+	f, err := createOutputFile("caa", "tsv")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	issuers := make(map[string]int)
+	wild := make(map[string]int)
+	with, inherited, failed, iodef := 0, 0, 0, 0
+	for r := range results {
+		switch {
+		case r.err != nil:
+			failed++
+			fmt.Fprintf(f, "%v\t\t%v\n", r.domain, r.err)
+			continue
+		case r.at == "":
+			fmt.Fprintf(f, "%v\t\t\t\t\n", r.domain)
+			continue
+		}
+		with++
+		if r.at != r.domain {
+			inherited++
+		}
+		if len(r.iodef) > 0 {
+			iodef++
+		}
+		for _, i := range r.issue {
+			issuers[i]++
+		}
+		for _, i := range r.issuewild {
+			wild[i]++
+		}
+		fmt.Fprintf(f, "%v\t%v\t%v\t%v\t%v\n", r.domain, r.at, strings.Join(r.issue, ","),
+			strings.Join(r.issuewild, ","), strings.Join(r.iodef, ","))
+	}
+
+	fmt.Printf("[+] With CAA:         %v (%.1f%%)\n"+
+		"[+] Inherited:        %v\n"+
+		"[+] Reporting:        %v\n"+
+		"[+] Errors:           %v\n",
+		with, float64(with)/float64(len(in))*100, inherited, iodef, failed)
+	printIssuers("Issuers:", issuers)
+	printIssuers("Wildcard Issuers:", wild)
+	fmt.Printf("[+] Results:          %v\n", f.Name())
+	return nil
+}
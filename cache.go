@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// negCacheNames is the number of random names queried by negcache
+const negCacheNames = 20
+
+// negativeTTL returns the TTL and MINIMUM field of the SOA in the
+// authority section, a negative answer may be cached for the lower of
+// the two (RFC 2308)
+func negativeTTL(r *dns.Msg) (uint32, uint32, bool) {
+	for _, a := range r.Ns {
+		if soa, ok := a.(*dns.SOA); ok {
+			return soa.Hdr.Ttl, soa.Minttl, true
+		}
+	}
+	return 0, 0, false
+}
+
+func avgDuration(d []time.Duration) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, v := range d {
+		sum += v
+	}
+	return sum / time.Duration(len(d))
+}
+
+func runNegCache(args []string) error {
+	zone := "com."
+	if len(args) > 0 {
+		zone = dns.Fqdn(args[0])
+	}
+
+	fmt.Printf("Negative Cache Test\n"+
+		"[+] Nameserver:       %v\n"+
+		"[+] Zone:             %v\n\n", *nameserver, zone)
+
+	var first, second []time.Duration
+	hits, noSOA, exceeded, reset := 0, 0, 0, 0
+	for i := 0; i < negCacheNames; i++ {
+		name := randomLabel(20) + "." + zone
+
+		r1, rtt1, err := exchange(name, dns.TypeA, dns.ClassINET)
+		if err != nil {
+			continue
+		}
+		r2, rtt2, err := exchange(name, dns.TypeA, dns.ClassINET)
+		if err != nil {
+			continue
+		}
+		if r1.Rcode != dns.RcodeNameError {
+			fmt.Printf("[-] %v returned %v\n", name, rcodeName(r1.Rcode))
+			continue
+		}
+		first = append(first, rtt1)
+		second = append(second, rtt2)
+		if rtt2 < rtt1/2 {
+			hits++
+		}
+
+		ttl1, min, ok1 := negativeTTL(r1)
+		ttl2, _, ok2 := negativeTTL(r2)
+		if !ok1 || !ok2 {
+			noSOA++
+			continue
+		}
+		// the SOA in a cached negative answer counts down from the
+		// negative TTL and must never exceed the zone's MINIMUM
+		if ttl1 > min || ttl2 > min {
+			exceeded++
+		}
+		if ttl2 > ttl1 {
+			reset++
+		}
+	}
+
+	if len(first) == 0 {
+		return fmt.Errorf("no NXDOMAIN responses received")
+	}
+
+	fmt.Printf("[+] Names:            %v\n"+
+		"[+] First Query:      %v avg\n"+
+		"[+] Second Query:     %v avg\n"+
+		"[+] Cache Hits:       %v (%.1f%%)\n"+
+		"[+] Missing SOA:      %v\n"+
+		"[+] TTL > Minimum:    %v\n"+
+		"[+] TTL Reset:        %v\n",
+		len(first), avgDuration(first), avgDuration(second),
+		hits, float64(hits)/float64(len(first))*100, noSOA, exceeded, reset)
+	return nil
+}
+
+func medianDuration(d []time.Duration) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	s := make([]time.Duration, len(d))
+	copy(s, d)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s[len(s)/2]
+}
+
+type warmResult struct {
+	cold, warm time.Duration
+}
+
+// runWarmCache queries every listed domain twice back to back, the first
+// query may have to recurse while the second should come from the cache
+func runWarmCache(args []string) error {
+	in, err := GetDomains(*domainList)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cold vs Warm Cache Test\n"+
+		"[+] Nameserver:       %v\n"+
+		"[+] Domains:          %v\n\n", *nameserver, len(in))
+
+	work := make(chan string)
+	results := make(chan warmResult)
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range work {
+				_, cold, err := exchange(d, dns.TypeA, dns.ClassINET)
+				if err != nil {
+					continue
+				}
+				_, warm, err := exchange(d, dns.TypeA, dns.ClassINET)
+				if err != nil {
+					continue
+				}
+				results <- warmResult{cold, warm}
+			}
+		}()
+	}
+	go func() {
+		for _, d := range in {
+			work <- d
+			time.Sleep(sendingDelay)
+		}
+		close(work)
+		wg.Wait()
+		close(results)
+	}()
+
+	var cold, warm, delta []time.Duration
+	misses := 0
+	for r := range results {
+		cold = append(cold, r.cold)
+		warm = append(warm, r.warm)
+		delta = append(delta, r.cold-r.warm)
+		if r.warm < r.cold/2 {
+			misses++
+		}
+	}
+	if len(cold) == 0 {
+		return fmt.Errorf("no responses received")
+	}
+
+	// a cold query that is about as fast as the warm one was answered
+	// from the cache already, only much slower cold queries are misses
+	fmt.Printf("[+] Answered:         %v\n"+
+		"[+] Cold:             %v avg / %v median\n"+
+		"[+] Warm:             %v avg / %v median\n"+
+		"[+] Delta:            %v avg / %v median\n"+
+		"[+] Cache Hit Ratio:  %.1f%%\n",
+		len(cold), avgDuration(cold), medianDuration(cold),
+		avgDuration(warm), medianDuration(warm),
+		avgDuration(delta), medianDuration(delta),
+		float64(len(cold)-misses)/float64(len(cold))*100)
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	graphURL = flag.String("graph-url", "", "Base URL the output directory is served at, used to link graphs in chat notifications")
+)
+
+// graphLink returns where the graph of the run can be viewed, the output
+// directory is exposed under /graphs/ by the API so -graph-url usually
+// points there
+func graphLink(s runSummary) string {
+	if *graphURL == "" || s.Graph == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(*outputDir, s.Graph)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(*graphURL, "/") + "/" + filepath.ToSlash(rel)
+}
+
+type chatFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func chatTitle(e webhookEvent) string {
+	if len(e.Breaches) > 0 {
+		return fmt.Sprintf("DNS benchmark of %v breached thresholds", e.Summary.Nameserver)
+	}
+	return fmt.Sprintf("DNS benchmark of %v finished", e.Summary.Nameserver)
+}
+
+func chatFacts(e webhookEvent) []chatFact {
+	s := e.Summary
+	client := s.Client
+	if client == "" {
+		client = "disabled"
+	}
+	facts := []chatFact{
+		{"Subnet Client", client},
+		{"Success", fmt.Sprintf("%v of %v", s.Success, s.Attempts)},
+		{"Fail", fmt.Sprint(s.Fail)},
+		{"Avg Rate", fmt.Sprintf("%.1f/s", s.AvgRate)},
+		{"Elapsed", fmt.Sprintf("%.1fs", s.Elapsed)},
+	}
+	if !s.Completed {
+		facts = append(facts, chatFact{"Completed", "no, requests were declined"})
+	}
+	for _, b := range e.Breaches {
+		facts = append(facts, chatFact{"Breach", b})
+	}
+	if s.Graph != "" && graphLink(s) == "" {
+		facts = append(facts, chatFact{"Graph", s.Graph})
+	}
+	return facts
+}
+
+// slackMessage formats the event for a Slack incoming webhook
+func slackMessage(e webhookEvent) interface{} {
+	lines := []string{"*" + chatTitle(e) + "*"}
+	for _, f := range chatFacts(e) {
+		lines = append(lines, fmt.Sprintf("%v: %v", f.Name, f.Value))
+	}
+	if l := graphLink(e.Summary); l != "" {
+		lines = append(lines, fmt.Sprintf("<%v|Rate graph>", l))
+	}
+	return map[string]string{"text": strings.Join(lines, "\n")}
+}
+
+type teamsAction struct {
+	Type    string              `json:"@type"`
+	Name    string              `json:"name"`
+	Targets []map[string]string `json:"targets"`
+}
+
+type teamsSection struct {
+	Facts []chatFact `json:"facts"`
+}
+
+type teamsCard struct {
+	Type     string         `json:"@type"`
+	Context  string         `json:"@context"`
+	Summary  string         `json:"summary"`
+	Title    string         `json:"title"`
+	Color    string         `json:"themeColor"`
+	Sections []teamsSection `json:"sections"`
+	Actions  []teamsAction  `json:"potentialAction,omitempty"`
+}
+
+// teamsMessage formats the event as a message card for a Teams incoming
+// webhook
+func teamsMessage(e webhookEvent) interface{} {
+	c := teamsCard{
+		Type:     "MessageCard",
+		Context:  "https://schema.org/extensions",
+		Title:    chatTitle(e),
+		Color:    "2EB67D",
+		Sections: []teamsSection{{chatFacts(e)}},
+	}
+	c.Summary = c.Title
+	if len(e.Breaches) > 0 {
+		c.Color = "E01E5A"
+	}
+	if l := graphLink(e.Summary); l != "" {
+		c.Actions = []teamsAction{{
+			Type:    "OpenUri",
+			Name:    "Rate graph",
+			Targets: []map[string]string{{"os": "default", "uri": l}},
+		}}
+	}
+	return c
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// cnameMaxChain guards against loops in a broken answer section
+	cnameMaxChain = 16
+	// cnameTopTargets limits how many terminal targets are listed
+	cnameTopTargets = 5
+)
+
+var (
+	cnameLengths = make(map[int]int)
+	cnameTargets = make(map[string]int)
+)
+
+// followCNAME walks the CNAME records of an answer section starting at the
+// query name, returning the chain of targets in order
+func followCNAME(qname string, answer []dns.RR) []string {
+	next := make(map[string]string)
+	for _, a := range answer {
+		if c, ok := a.(*dns.CNAME); ok {
+			next[strings.ToLower(c.Hdr.Name)] = strings.ToLower(c.Target)
+		}
+	}
+
+	var chain []string
+	name := strings.ToLower(qname)
+	for len(chain) < cnameMaxChain {
+		t, ok := next[name]
+		if !ok {
+			break
+		}
+		chain = append(chain, t)
+		name = t
+	}
+	return chain
+}
+
+func recordCNAME(domain string, msg *dns.Msg) {
+	chain := followCNAME(domain, msg.Answer)
+	cnameLengths[len(chain)]++
+	if len(chain) > 0 {
+		cnameTargets[chain[len(chain)-1]]++
+	}
+}
+
+func cnameStats() {
+	if len(cnameTargets) == 0 {
+		return
+	}
+
+	lengths := make([]int, 0, len(cnameLengths))
+	for n := range cnameLengths {
+		lengths = append(lengths, n)
+	}
+	sort.Ints(lengths)
+
+	fmt.Printf("[+] CNAME Chains:\n")
+	for _, n := range lengths {
+		fmt.Printf("[+]   %-16s%v\n", fmt.Sprintf("length %d:", n), cnameLengths[n])
+	}
+
+	targets := make([]string, 0, len(cnameTargets))
+	for t := range cnameTargets {
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if cnameTargets[targets[i]] != cnameTargets[targets[j]] {
+			return cnameTargets[targets[i]] > cnameTargets[targets[j]]
+		}
+		return targets[i] < targets[j]
+	})
+	if len(targets) > cnameTopTargets {
+		targets = targets[:cnameTopTargets]
+	}
+
+	fmt.Printf("[+] CNAME Targets:\n")
+	for _, t := range targets {
+		fmt.Printf("[+]   %v (%v)\n", t, cnameTargets[t])
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	args  string
+	usage string
+	run   func(args []string) error
+}
+
+// commands are alternatives to the benchmark selected by the first
+// positional argument, they share the global options
+var commands = map[string]command{
+	"agent":              {"{addr}", "Serve benchmark runs of domain shards sent by a coordinator", runAgent},
+	"axfr":               {"{zone} [server ...]", "Transfer a zone and dump it or benchmark the nameserver with its names", runAXFR},
+	"bufsweep":           {"[size ...]", "Benchmark with a series of EDNS buffer sizes and recommend one", runBufSweep},
+	"caa":                {"[domain ...]", "Audit the CAA issuers of every listed domain and its parents", runCAA},
+	"compare":            {"{nameserver ...}", "Benchmark several nameservers and subnets and compare their latency", runCompare},
+	"compare-transports": {"[transport ...]", "Benchmark the nameserver over UDP, TCP, DoT and the -doh endpoint and compare them", runCompareTransports},
+	"coordinate":         {"{agent-url ...}", "Shard the domain list across agents and aggregate their results", runCoordinate},
+	"dane":               {"[host:port ...]", "Report TLSA records of every endpoint and check them against its certificate", runDANE},
+	"delegation":         {"{zone}", "Check parent NS and glue against the zone's own NS records", runDelegation},
+	"dohmethods":         {"", "Benchmark the -doh endpoint with GET and POST and compare them", runDoHMethods},
+	"ixfr":               {"{zone} {serial} [server ...]", "Request the changes to a zone since serial and report how servers answer", runIXFR},
+	"monitor":            {"", "Run the benchmark on a schedule and keep a history of the results", runMonitor},
+	"negcache":           {"[zone]", "Measure negative caching of random names below zone (default com.)", runNegCache},
+	"pmtu":               {"[nameserver ...]", "Find the response size at which UDP answers stop arriving", runPMTU},
+	"propagation":        {"{zone}", "Compare answers of every authoritative server of a zone", runPropagation},
+	"soa":                {"{zone}", "Report SOA serial skew across the authoritative servers of a zone", runSOA},
+	"trace":              {"{name}", "Resolve name iteratively from the root showing every delegation", runTrace},
+	"ttldecay":           {"[name ...]", "Re-query names over time and check their TTLs count down", runTTLDecay},
+	"update":             {"{zone} [count]", "Send dynamic updates and measure how long until their records are visible", runUpdate},
+	"warmcache":          {"", "Query every listed domain twice and compare cold and warm latency", runWarmCache},
+}
+
+var subcommand *command
+
+func commandUsage() {
+	names := make([]string, 0, len(commands))
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	for _, n := range names {
+		c := commands[n]
+		fmt.Fprintf(os.Stderr, "  %v %v\n    \t%v\n", n, c.args, c.usage)
+	}
+}
+
+func runCommand(name string, args []string) {
+	if err := subcommand.run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rtmoranorg/dns-client-subnet-ext/graph"
+)
+
+var (
+	compareSubnets = flag.String("subnets", "", "Comma separated client subnets the compare command runs every nameserver with")
+)
+
+type compareRun struct {
+	label     string
+	subnet    string
+	summary   runSummary
+	latencies []float64
+	ech       map[string]string
+	sets      map[string]map[string]int
+	catchment map[string]map[string]int
+}
+
+func compareLabel(ns, subnet string) string {
+	if subnet == "" {
+		return ns
+	}
+	if c := subnetCountry[subnet]; c != "" {
+		return ns + " " + subnet + " " + c
+	}
+	return ns + " " + subnet
+}
+
+// runCompare benchmarks every nameserver with every subnet in turn and
+// sets their latency distributions side by side
+func runCompare(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected at least one nameserver")
+	}
+	if *domainList == "" {
+		return fmt.Errorf("missing required domain list")
+	}
+	subnets := []string{*client}
+	if *compareSubnets != "" {
+		subnets = strings.Split(*compareSubnets, ",")
+	}
+	if countrySubnets != nil {
+		if *compareSubnets == "" {
+			subnets = nil
+		}
+		subnets = append(subnets, countrySubnets...)
+	}
+
+	var runs []compareRun
+	for _, ns := range args {
+		for _, subnet := range subnets {
+			*nameserver, *client = ns, strings.TrimSpace(subnet)
+			findings = nil
+
+			getBanner(sendingDelay, retryDelay, clientLabel())
+			if err := chooseFamily(); err != nil {
+				fmt.Printf("[-] %v\n\n", err)
+				continue
+			}
+			if *preflight {
+				if err := checkHealth(); err != nil {
+					fmt.Printf("[-] %v\n\n", err)
+					continue
+				}
+			}
+			completed, err := runBenchmark()
+			if err != nil {
+				return err
+			}
+			finalStats()
+			fmt.Println()
+
+			runs = append(runs, compareRun{compareLabel(ns, *client), *client, summarize(completed), latencyMs(), echConfigs, answerSets, catchment})
+		}
+	}
+
+	// the comparison is an artifact of its own next to those of the runs
+	runID = newRunID()
+	fmt.Printf("Comparison\n")
+	fmt.Printf("[+] %-32s%9v%9v%11v%11v%11v%11v\n", "Target", "Success", "Fail", "Rate/s", "p50", "p95", "Jitter")
+	labels := make([]string, 0, len(runs))
+	samples := make([][]float64, 0, len(runs))
+	for _, r := range runs {
+		latencies = nil
+		for _, ms := range r.latencies {
+			latencies = append(latencies, msDuration(ms))
+		}
+		fmt.Printf("[+] %-32s%9v%9v%11.1f%11v%11v%11v\n", r.label, r.summary.Success, r.summary.Fail,
+			r.summary.AvgRate, formatMs(latencyPercentile(50)), formatMs(latencyPercentile(95)),
+			formatMs(latencyJitter()))
+
+		labels = append(labels, r.label)
+		samples = append(samples, r.latencies)
+	}
+	compareECH(runs)
+	sets := make(map[string]map[string]int)
+	sites := make(map[string]map[string]int)
+	for _, r := range runs {
+		mergeAnswerSets(sets, r.sets)
+		mergeCatchment(sites, r.catchment)
+	}
+	diversityStats(sets)
+	catchmentStats("compare", false, sites)
+	graph.BuildLatencyBoxPlot("compare", "latency by nameserver and subnet", labels, samples, *outputDir)
+	graph.BuildLatencyCDF("compare", false, labels, samples, *outputDir)
+	if len(subnets) > 1 {
+		compareSubnetLatency(runs)
+	}
+	return nil
+}
+
+// compareSubnetLatency pools the latencies of every nameserver by subnet,
+// a subnet answered slower everywhere stands out from nameserver noise
+func compareSubnetLatency(runs []compareRun) {
+	var order []string
+	bySubnet := make(map[string][]float64)
+	for _, r := range runs {
+		label := r.subnet
+		if label == "" {
+			label = "disabled"
+		} else if c := subnetCountry[label]; c != "" {
+			label += " " + c
+		}
+		if _, ok := bySubnet[label]; !ok {
+			order = append(order, label)
+		}
+		bySubnet[label] = append(bySubnet[label], r.latencies...)
+	}
+
+	samples := make([][]float64, 0, len(order))
+	for _, l := range order {
+		samples = append(samples, bySubnet[l])
+	}
+	graph.BuildSubnetLatency("compare", false, "latency by client subnet", order, samples, *outputDir)
+}
+
+func compareECH(runs []compareRun) {
+	if queryType != typeHTTPS && queryType != typeSVCB {
+		return
+	}
+	fmt.Printf("[+] ECH Configs:\n")
+	for _, r := range runs {
+		fmt.Printf("[+]   %-22s%v\n", r.label+":", len(r.ech))
+	}
+	differ := echDifferences(runs)
+	fmt.Printf("[+] ECH Differs:      %v domains\n", len(differ))
+	for i, n := range differ {
+		if i == *topN {
+			break
+		}
+		fmt.Printf("[+]   %v\n", n)
+	}
+}
+
+// useTransport points the following run at one transport, the DoH one
+// takes over the nameserver label the way -doh does
+func useTransport(t, doh string) error {
+	*transport, *dohURL = t, ""
+	*nameserver, *preflight = plainNameserver, plainPreflight
+	if t == "doh" {
+		*transport, *dohURL = "udp", doh
+		return setupDoH()
+	}
+	return setupTransport()
+}
+
+// runCompareTransports benchmarks the same domains over every transport in
+// turn, DoH is included when an endpoint was given with -doh. Connection
+// setup is reported apart from the query latency since it is what the
+// transports differ in most.
+func runCompareTransports(args []string) error {
+	if *domainList == "" {
+		return fmt.Errorf("missing required domain list")
+	}
+	doh := *dohURL
+	if doh == "" {
+		plainNameserver, plainPreflight = *nameserver, *preflight
+	}
+	transports := args
+	if len(transports) == 0 {
+		transports = []string{"udp", "tcp", "dot"}
+		if doh != "" {
+			transports = append(transports, "doh")
+		}
+	}
+
+	type transportRun struct {
+		compareRun
+		setup time.Duration
+		conns int
+	}
+	var runs []transportRun
+	for _, t := range transports {
+		if t == "doh" && doh == "" {
+			return fmt.Errorf("doh needs an endpoint given with -doh")
+		}
+		if err := useTransport(t, doh); err != nil {
+			return fmt.Errorf("%v: %v", t, err)
+		}
+		findings = nil
+
+		getBanner(sendingDelay, retryDelay, clientLabel())
+		fmt.Printf("[+] Transport:        %v\n\n", t)
+		if err := chooseFamily(); err != nil {
+			fmt.Printf("[-] %v\n\n", err)
+			continue
+		}
+		if *preflight {
+			if err := checkHealth(); err != nil {
+				fmt.Printf("[-] %v\n\n", err)
+				continue
+			}
+		}
+		completed, err := runBenchmark()
+		if err != nil {
+			return err
+		}
+		finalStats()
+		fmt.Println()
+
+		setupTimes.Lock()
+		conns := len(setupTimes.connect)
+		setupTimes.Unlock()
+		runs = append(runs, transportRun{compareRun{t, *client, summarize(completed), latencyMs(), nil, nil, nil},
+			setupPerQuery(), conns})
+	}
+
+	runID = newRunID()
+	fmt.Printf("Transport Comparison\n")
+	fmt.Printf("[+] %-10s%9v%9v%11v%11v%9v%11v\n", "Transport", "Success", "Fail", "p50", "p95", "Conns", "Setup/Q")
+	labels := make([]string, 0, len(runs))
+	samples := make([][]float64, 0, len(runs))
+	for _, r := range runs {
+		latencies = nil
+		for _, ms := range r.latencies {
+			latencies = append(latencies, msDuration(ms))
+		}
+		fmt.Printf("[+] %-10s%9v%9v%11v%11v%9v%11v\n", r.label, r.summary.Success, r.summary.Fail,
+			formatMs(latencyPercentile(50)), formatMs(latencyPercentile(95)), r.conns, formatMs(r.setup))
+
+		labels = append(labels, r.label)
+		samples = append(samples, r.latencies)
+	}
+	graph.BuildLatencyBoxPlot(plainNameserver, "latency by transport", labels, samples, *outputDir)
+	graph.BuildLatencyCDF(plainNameserver, false, labels, samples, *outputDir)
+	return nil
+}
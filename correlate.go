@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// unmatchedSamples limits how many unmatched responses are listed
+const unmatchedSamples = 10
+
+// idAttempts is how many random IDs are tried before looking for the oldest
+// released one, random picks stop finding free IDs as the socket fills up
+const idAttempts = 64
+
+// queryKey is what a response carries to find the query it answers, the
+// socket it arrived on, its ID and the question name
+type queryKey struct {
+	socket int
+	id     uint16
+	qname  string
+}
+
+func newQueryKey(socket int, id uint16, qname string) queryKey {
+	return queryKey{socket, id, strings.ToLower(qname)}
+}
+
+func (dr *domainRecord) key() queryKey {
+	return newQueryKey(dr.socket, dr.id, dr.domain)
+}
+
+type matchResult int
+
+const (
+	matched matchResult = iota
+	unknownID
+	wrongQuestion
+	// duplicate is a response beyond one per attempt sent for the query
+	duplicate
+	// late answers an attempt after the query was answered by another one
+	// or had timed out
+	late
+)
+
+// correlator tracks the outstanding queries of a run, it is only used from
+// the guard goroutine
+type correlator struct {
+	pending map[queryKey]*domainRecord
+	// ids are the outstanding IDs of every socket, they tell responses for
+	// an unknown ID apart from those whose question does not match
+	ids map[int]map[uint16]*domainRecord
+	// released holds the IDs freed recently, they are not handed out again
+	// while late answers to their previous query may still arrive
+	released map[int]map[uint16]*releasedID
+	// order holds the released IDs of every socket in the order they were
+	// released, trimmed to those still in quarantine
+	order map[int][]*releasedID
+	// exhausted is set once queries had to wait for a free ID
+	exhausted bool
+}
+
+type releasedID struct {
+	id       uint16
+	qname    string
+	at       time.Time
+	attempts int
+	answers  int
+}
+
+func newCorrelator() *correlator {
+	return &correlator{
+		pending:  make(map[queryKey]*domainRecord),
+		ids:      make(map[int]map[uint16]*domainRecord),
+		released: make(map[int]map[uint16]*releasedID),
+		order:    make(map[int][]*releasedID),
+	}
+}
+
+func (c *correlator) len() int {
+	return len(c.pending)
+}
+
+// quarantine is how long a released ID stays unused, as long as the
+// slowest answer to any attempt of its query could take
+func quarantine() time.Duration {
+	return retryDelay * time.Duration(*retryCount+1)
+}
+
+// usable reports whether id may be given to a new query on socket
+func (c *correlator) usable(socket int, id uint16) bool {
+	if id == 0 || c.ids[socket][id] != nil {
+		return false
+	}
+	if r, ok := c.released[socket][id]; ok {
+		if time.Since(r.at) < quarantine() {
+			return false
+		}
+		delete(c.released[socket], id)
+	}
+	return true
+}
+
+// nextID returns a usable ID for a new query on socket, or how long to wait
+// for one. Once the rate times the quarantine comes close to the 65535 IDs
+// of a socket only the oldest released ones are free again.
+func (c *correlator) nextID(socket int) (uint16, time.Duration) {
+	for i := 0; i < idAttempts; i++ {
+		if id := dns.Id(); c.usable(socket, id) {
+			return id, 0
+		}
+	}
+	for _, r := range c.order[socket] {
+		if c.released[socket][r.id] != r {
+			// handed out again since
+			continue
+		}
+		if c.usable(socket, r.id) {
+			return r.id, 0
+		}
+		return 0, quarantine() - time.Since(r.at)
+	}
+	// every ID waits for its answer, one is released at the latest when
+	// its query times out
+	return 0, retryDelay
+}
+
+func (c *correlator) add(dr *domainRecord) {
+	c.pending[dr.key()] = dr
+	if c.ids[dr.socket] == nil {
+		c.ids[dr.socket] = make(map[uint16]*domainRecord)
+		c.released[dr.socket] = make(map[uint16]*releasedID)
+	}
+	c.ids[dr.socket][dr.id] = dr
+}
+
+// remove releases the ID of dr, answered tells whether it got a response
+func (c *correlator) remove(dr *domainRecord, answered bool) {
+	delete(c.pending, dr.key())
+	delete(c.ids[dr.socket], dr.id)
+
+	rel := &releasedID{id: dr.id, qname: strings.ToLower(dr.domain), at: time.Now(), attempts: dr.resend + 1}
+	if answered {
+		rel.answers = 1
+	}
+	c.released[dr.socket][dr.id] = rel
+
+	order := append(c.order[dr.socket], rel)
+	for len(order) > 0 && (c.released[dr.socket][order[0].id] != order[0] ||
+		time.Since(order[0].at) >= quarantine()) {
+		order = order[1:]
+	}
+	c.order[dr.socket] = order
+}
+
+// outstanding reports whether dr still waits for its answer
+func (c *correlator) outstanding(dr *domainRecord) bool {
+	return c.pending[dr.key()] == dr
+}
+
+// match returns the query da answers, or why none matched. asked is the
+// question sent under the ID when the response does not repeat it.
+func (c *correlator) match(da *domainAnswer) (dr *domainRecord, r matchResult, asked string) {
+	if dr := c.pending[newQueryKey(da.socket, da.id, da.domain)]; dr != nil {
+		return dr, matched, ""
+	}
+	if dr := c.ids[da.socket][da.id]; dr != nil {
+		return nil, wrongQuestion, dr.domain
+	}
+	rel, ok := c.released[da.socket][da.id]
+	switch {
+	case !ok:
+		return nil, unknownID, ""
+	case rel.qname != strings.ToLower(da.domain):
+		return nil, wrongQuestion, rel.qname
+	}
+	rel.answers++
+	if rel.answers > rel.attempts {
+		return nil, duplicate, ""
+	}
+	return nil, late, ""
+}
+
+var (
+	unmatchedIDs       int
+	unmatchedQuestions int
+	duplicates         int
+	lateAnswers        int
+	unmatchedExamples  []string
+)
+
+// recordUnmatched counts a response no outstanding query was waiting for.
+// Duplicates and late answers are expected from anycast, middleboxes and
+// our own retransmissions, the others may be spoofed.
+func recordUnmatched(da *domainAnswer, r matchResult, asked string) {
+	switch r {
+	case duplicate:
+		duplicates++
+		return
+	case late:
+		lateAnswers++
+		return
+	}
+	countError(errMismatch)
+
+	var sample string
+	switch r {
+	case unknownID:
+		unmatchedIDs++
+		sample = fmt.Sprintf("0x%04x %v: unknown id", da.id, da.domain)
+	case wrongQuestion:
+		unmatchedQuestions++
+		sample = fmt.Sprintf("0x%04x %v: asked %v", da.id, da.domain, asked)
+	}
+	if len(unmatchedExamples) < unmatchedSamples {
+		unmatchedExamples = append(unmatchedExamples, sample)
+	}
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "error, unmatched response %s\n", sample)
+	}
+}
+
+func unmatchedStats() {
+	total := unmatchedIDs + unmatchedQuestions + duplicates + lateAnswers
+	if total == 0 {
+		return
+	}
+	fmt.Printf("[+] Unmatched:        %v responses\n", total)
+	fmt.Printf("[+]   %-16s%v\n", "Duplicate:", duplicates)
+	fmt.Printf("[+]   %-16s%v\n", "Late:", lateAnswers)
+	fmt.Printf("[+]   %-16s%v\n", "Unknown ID:", unmatchedIDs)
+	fmt.Printf("[+]   %-16s%v\n", "Wrong Question:", unmatchedQuestions)
+	for _, e := range unmatchedExamples {
+		fmt.Printf("[+]   %v\n", e)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	clientCountry = flag.String("client-country", "", "Comma separated country codes whose preset subnets are used as client subnets, e.g. DE,JP,BR")
+)
+
+// countryPrefixes holds a few /24s per country out of large access
+// networks of its incumbent carriers. They are meant to land in the right
+// country on any GeoIP database, not to stand for a particular city.
+var countryPrefixes = map[string][]string{
+	"AU": {"1.128.0.0/24", "58.160.0.0/24"},
+	"BR": {"177.0.0.0/24", "189.0.0.0/24"},
+	"CA": {"99.224.0.0/24", "70.24.0.0/24"},
+	"DE": {"80.128.0.0/24", "88.64.0.0/24"},
+	"ES": {"79.144.0.0/24", "83.32.0.0/24"},
+	"FR": {"90.0.0.0/24", "82.64.0.0/24"},
+	"GB": {"86.128.0.0/24", "81.96.0.0/24"},
+	"IN": {"49.32.0.0/24", "122.160.0.0/24"},
+	"IT": {"79.0.0.0/24", "87.0.0.0/24"},
+	"JP": {"126.0.0.0/24", "153.128.0.0/24"},
+	"KR": {"175.192.0.0/24", "211.104.0.0/24"},
+	"MX": {"187.128.0.0/24", "189.128.0.0/24"},
+	"NL": {"77.160.0.0/24", "84.24.0.0/24"},
+	"RU": {"95.24.0.0/24", "178.64.0.0/24"},
+	"SG": {"116.86.0.0/24", "121.6.0.0/24"},
+	"US": {"73.0.0.0/24", "99.0.0.0/24"},
+	"ZA": {"41.0.0.0/24", "105.0.0.0/24"},
+}
+
+// countrySubnets holds the subnets of every country from -client-country
+// in the order they were given, subnetCountry the country of each
+var (
+	countrySubnets []string
+	subnetCountry  map[string]string
+)
+
+func countryCodes(s string) []string {
+	var codes []string
+	for _, c := range strings.Split(s, ",") {
+		codes = append(codes, strings.ToUpper(strings.TrimSpace(c)))
+	}
+	return codes
+}
+
+func presetSubnets(codes []string) (map[string][]string, error) {
+	subnets := make(map[string][]string, len(codes))
+	for _, c := range codes {
+		p, ok := countryPrefixes[c]
+		if !ok {
+			return nil, fmt.Errorf("no preset for %q, known are %v", c, strings.Join(knownCountries(), ","))
+		}
+		subnets[c] = p
+	}
+	return subnets, nil
+}
+
+func knownCountries() []string {
+	codes := make([]string, 0, len(countryPrefixes))
+	for c := range countryPrefixes {
+		codes = append(codes, c)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// setupCountries replaces the client subnet with the first subnet of the
+// countries, every other one is only used by the compare command
+func setupCountries() error {
+	if *clientCountry == "" {
+		return nil
+	}
+	if *client != "" {
+		return fmt.Errorf("-c and -client-country are exclusive")
+	}
+	if *geoipSamples < 1 {
+		return fmt.Errorf("-geoip-samples must be at least 1")
+	}
+
+	codes := countryCodes(*clientCountry)
+	var byCountry map[string][]string
+	var err error
+	if *geoipDB != "" {
+		byCountry, err = geoipSubnets(codes)
+	} else {
+		byCountry, err = presetSubnets(codes)
+	}
+	if err != nil {
+		return err
+	}
+
+	countrySubnets, subnetCountry = nil, make(map[string]string)
+	for _, c := range codes {
+		for _, s := range byCountry[c] {
+			countrySubnets = append(countrySubnets, s)
+			subnetCountry[s] = c
+		}
+	}
+	*client = countrySubnets[0]
+	return nil
+}
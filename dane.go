@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	daneVerify = flag.Bool("dane-verify", false, "Connect to every endpoint of the dane command and match its certificate against the TLSA records")
+)
+
+// daneUsages are the certificate usages of RFC 7218
+var daneUsages = map[uint8]string{0: "PKIX-TA", 1: "PKIX-EE", 2: "DANE-TA", 3: "DANE-EE"}
+
+type daneResult struct {
+	endpoint string
+	tlsa     []*dns.TLSA
+	secure   bool
+	verified string
+	err      error
+}
+
+func splitEndpoint(s string) (string, string) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return s, "443"
+	}
+	return host, port
+}
+
+// matchTLSA checks the certificate chain of a live connection against the
+// records, trust anchor usages may match any certificate in the chain. The
+// PKIX usages also need the chain to validate for host, a PKIX-TA record
+// has to match a certificate of the validated path.
+func matchTLSA(records []*dns.TLSA, chain []*x509.Certificate, host string) (string, bool) {
+	if len(chain) == 0 {
+		return "no certificate", false
+	}
+
+	var pkix [][]*x509.Certificate
+	var pkixErr error
+	verified := false
+	verifyPKIX := func() error {
+		if !verified {
+			verified = true
+			inter := x509.NewCertPool()
+			for _, c := range chain[1:] {
+				inter.AddCert(c)
+			}
+			pkix, pkixErr = chain[0].Verify(x509.VerifyOptions{DNSName: host, Intermediates: inter})
+		}
+		return pkixErr
+	}
+
+	outcome := "no record matches"
+	for _, t := range records {
+		certs := chain[:1]
+		switch t.Usage {
+		case 0:
+			if err := verifyPKIX(); err != nil {
+				outcome = fmt.Sprintf("PKIX-TA chain not valid: %v", err)
+				continue
+			}
+			certs = nil
+			for _, p := range pkix {
+				certs = append(certs, p...)
+			}
+		case 2:
+			certs = chain
+		}
+		for _, c := range certs {
+			if t.Verify(c) != nil {
+				continue
+			}
+			if t.Usage == 1 {
+				if err := verifyPKIX(); err != nil {
+					outcome = fmt.Sprintf("PKIX-EE match, chain not valid: %v", err)
+					break
+				}
+			}
+			return fmt.Sprintf("%v match", daneUsages[t.Usage]), true
+		}
+	}
+	return outcome, false
+}
+
+func checkDANE(endpoint string) daneResult {
+	host, port := splitEndpoint(endpoint)
+	res := daneResult{endpoint: net.JoinHostPort(host, port)}
+
+	name, err := dns.TLSAName(dns.Fqdn(host), port, "tcp")
+	if err != nil {
+		res.err = err
+		return res
+	}
+	m := new(dns.Msg)
+	m.SetQuestion(name, dns.TypeTLSA)
+	m.SetEdns0(1232, true)
+	m.AuthenticatedData = true
+
+	r, _, err := exchangeMsg(m)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	res.secure = r.AuthenticatedData
+	for _, a := range r.Answer {
+		if t, ok := a.(*dns.TLSA); ok {
+			res.tlsa = append(res.tlsa, t)
+		}
+	}
+	if len(res.tlsa) == 0 || !*daneVerify {
+		return res
+	}
+
+	// DANE-EE and DANE-TA do not rely on the web PKI, the chain is only
+	// compared to the records and matchTLSA validates it for the PKIX ones
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: retryDelay}, "tcp", res.endpoint,
+		&tls.Config{ServerName: host, InsecureSkipVerify: true})
+	if err != nil {
+		res.verified = fmt.Sprintf("unreachable (%v)", err)
+		return res
+	}
+	defer conn.Close()
+
+	outcome, ok := matchTLSA(res.tlsa, conn.ConnectionState().PeerCertificates, host)
+	if ok {
+		res.verified = "valid (" + outcome + ")"
+	} else {
+		res.verified = "invalid (" + outcome + ")"
+	}
+	return res
+}
+
+// runDANE reports the TLSA deployment of every listed host:port and,
+// with -dane-verify, whether the live certificates match
+func runDANE(args []string) error {
+	in := args
+	if len(in) == 0 {
+		var err error
+		if in, err = GetDomains(*domainList); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("DANE Check\n"+
+		"[+] Nameserver:       %v\n"+
+		"[+] Endpoints:        %v\n\n", *nameserver, len(in))
+
+	deployed, secure, valid, failed := 0, 0, 0, 0
+	for _, e := range in {
+		r := checkDANE(strings.TrimSpace(e))
+		switch {
+		case r.err != nil:
+			failed++
+			fmt.Printf("[-] %-40v %v\n", r.endpoint, r.err)
+			continue
+		case len(r.tlsa) == 0:
+			continue
+		}
+		deployed++
+		if r.secure {
+			secure++
+		}
+		if strings.HasPrefix(r.verified, "valid") {
+			valid++
+		}
+
+		usages := make([]string, 0, len(r.tlsa))
+		for _, t := range r.tlsa {
+			usages = append(usages, fmt.Sprintf("%v %v %v", daneUsages[t.Usage], t.Selector, t.MatchingType))
+		}
+		line := fmt.Sprintf("%v TLSA (%v)", len(r.tlsa), strings.Join(usages, ", "))
+		if !r.secure {
+			line += " insecure"
+		}
+		if r.verified != "" {
+			line += " " + r.verified
+		}
+		fmt.Printf("[+] %-40v %v\n", r.endpoint, line)
+	}
+
+	fmt.Printf("\n[+] With TLSA:        %v of %v\n"+
+		"[+] DNSSEC Secure:    %v\n"+
+		"[+] Errors:           %v\n",
+		deployed, len(in), secure, failed)
+	if *daneVerify {
+		fmt.Printf("[+] Valid:            %v of %v\n", valid, deployed)
+	}
+	return nil
+}
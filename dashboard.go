@@ -0,0 +1,227 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+)
+
+type rateSeries struct {
+	Time []float64 `json:"time"`
+	Rate []float64 `json:"rate"`
+}
+
+// latencySeries holds the median and 95th percentile in milliseconds of
+// the answers that arrived in every second of the run
+type latencySeries struct {
+	Time []float64 `json:"time"`
+	P50  []float64 `json:"p50_ms"`
+	P95  []float64 `json:"p95_ms"`
+}
+
+// subnetSummary aggregates the recorded runs made with one client subnet
+type subnetSummary struct {
+	Client  string  `json:"client"`
+	Runs    int     `json:"runs"`
+	Success int     `json:"success"`
+	Fail    int     `json:"fail"`
+	AvgRate float64 `json:"avg_rate"`
+}
+
+// apiSeries returns the rate samples of the run in progress, or of the last
+// run once it finished
+func apiSeries(w http.ResponseWriter, r *http.Request) {
+	seriesMu.Lock()
+	s := rateSeries{
+		Time: append([]float64(nil), timeValues...),
+		Rate: append([]float64(nil), rateValues...),
+	}
+	seriesMu.Unlock()
+	writeJSON(w, http.StatusOK, s)
+}
+
+// apiLatency bins the latencies of the run by the second they arrived in,
+// the samples themselves are too many to poll
+func apiLatency(w http.ResponseWriter, r *http.Request) {
+	seriesMu.Lock()
+	bins := make(map[int][]time.Duration)
+	for i, l := range latencies {
+		sec := int(latencyTimes[i])
+		bins[sec] = append(bins[sec], l)
+	}
+	seriesMu.Unlock()
+
+	secs := make([]int, 0, len(bins))
+	for sec := range bins {
+		secs = append(secs, sec)
+	}
+	sort.Ints(secs)
+
+	s := latencySeries{Time: []float64{}, P50: []float64{}, P95: []float64{}}
+	for _, sec := range secs {
+		s.Time = append(s.Time, float64(sec)+0.5)
+		s.P50 = append(s.P50, durationMs(percentileOf(bins[sec], 50)))
+		s.P95 = append(s.P95, durationMs(percentileOf(bins[sec], 95)))
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+func apiSubnets(w http.ResponseWriter, r *http.Request) {
+	h, err := loadHistory()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	bySubnet := make(map[string]*subnetSummary)
+	for _, s := range h {
+		c := s.Client
+		if c == "" {
+			c = "disabled"
+		}
+		sum, ok := bySubnet[c]
+		if !ok {
+			sum = &subnetSummary{Client: c}
+			bySubnet[c] = sum
+		}
+		sum.Runs++
+		sum.Success += s.Success
+		sum.Fail += s.Fail
+		sum.AvgRate += s.AvgRate
+	}
+
+	subnets := make([]subnetSummary, 0, len(bySubnet))
+	for _, sum := range bySubnet {
+		sum.AvgRate /= float64(sum.Runs)
+		subnets = append(subnets, *sum)
+	}
+	sort.Slice(subnets, func(i, j int) bool { return subnets[i].Client < subnets[j].Client })
+	writeJSON(w, http.StatusOK, subnets)
+}
+
+func serveDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(dashboardPage))
+}
+
+// dashboardPage polls the REST API and draws its charts on canvases, it has
+// no dependencies so the daemon can serve it without network access
+const dashboardPage = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>dns-client-subnet-ext</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h2 { margin-top: 1.5em; }
+table { border-collapse: collapse; }
+td, th { padding: 4px 10px; border-bottom: 1px solid #ddd; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+canvas { border: 1px solid #ddd; }
+button { margin-right: 0.5em; }
+#status { font-family: monospace; white-space: pre; }
+</style>
+</head>
+<body>
+<h1>dns-client-subnet-ext</h1>
+<button onclick="post('/runs')">Start run</button>
+<button onclick="post('/stop')">Stop run</button>
+<div id="status"></div>
+
+<h2>Current Run</h2>
+<canvas id="rate" width="800" height="240"></canvas>
+<canvas id="latency" width="800" height="240"></canvas>
+
+<h2>Run History</h2>
+<canvas id="history" width="800" height="240"></canvas>
+<table id="runs"></table>
+
+<h2>Client Subnets</h2>
+<table id="subnets"></table>
+
+<script>
+function post(path) {
+  fetch(path, {method: 'POST'}).then(r => r.json()).then(j => {
+    if (j.error) alert(j.error);
+    refresh();
+  });
+}
+
+function plot(id, xs, series) {
+  const c = document.getElementById(id), g = c.getContext('2d');
+  g.clearRect(0, 0, c.width, c.height);
+  const pad = 40;
+  let maxX = Math.max(1, ...xs), maxY = 1;
+  series.forEach(s => { maxY = Math.max(maxY, ...s.ys); });
+  g.fillStyle = '#666';
+  g.fillText(maxY.toFixed(1), 2, pad);
+  g.fillText('0', 2, c.height - pad);
+  g.fillText(maxX.toFixed(1), c.width - pad, c.height - pad + 15);
+  series.forEach((s, k) => {
+    g.strokeStyle = s.color;
+    g.beginPath();
+    s.ys.forEach((y, i) => {
+      const px = pad + xs[i] / maxX * (c.width - 2 * pad);
+      const py = c.height - pad - y / maxY * (c.height - 2 * pad);
+      i ? g.lineTo(px, py) : g.moveTo(px, py);
+    });
+    g.stroke();
+    g.fillStyle = s.color;
+    g.fillText(s.name, pad + k * 120, 15);
+  });
+}
+
+function rows(id, head, data) {
+  const t = document.getElementById(id);
+  t.replaceChildren();
+  [head].concat(data).forEach((r, i) => {
+    const tr = t.insertRow();
+    r.forEach(v => {
+      const cell = document.createElement(i ? 'td' : 'th');
+      cell.textContent = v;
+      tr.appendChild(cell);
+    });
+  });
+}
+
+function refresh() {
+  fetch('/status').then(r => r.json()).then(s => {
+    let txt = s.running ? 'Running since ' + s.started + ' (' + s.attempts + ' attempts, ' +
+      s.success + ' success, ' + s.fail + ' fail)' : 'Idle, next run ' + s.next_run;
+    document.getElementById('status').textContent = txt + '\nRuns: ' + s.runs;
+  });
+  fetch('/series').then(r => r.json()).then(s => {
+    plot('rate', s.time, [{name: 'queries/s', color: '#1f77b4', ys: s.rate}]);
+  });
+  fetch('/latency').then(r => r.json()).then(s => {
+    plot('latency', s.time, [
+      {name: 'p50 ms', color: '#2ca02c', ys: s.p50_ms},
+      {name: 'p95 ms', color: '#ff7f0e', ys: s.p95_ms},
+    ]);
+  });
+  fetch('/results').then(r => r.json()).then(h => {
+    const recent = h.slice(-50);
+    plot('history', recent.map((_, i) => i), [
+      {name: 'avg rate', color: '#1f77b4', ys: recent.map(s => s.avg_rate)},
+      {name: 'fail', color: '#d62728', ys: recent.map(s => s.fail)},
+    ]);
+    rows('runs', ['Time', 'Client', 'Success', 'Fail', 'Avg Rate', 'Elapsed'],
+      recent.slice().reverse().slice(0, 10).map(s => [s.time, s.client || 'disabled',
+        s.success, s.fail, s.avg_rate.toFixed(1), s.elapsed_seconds.toFixed(1) + 's']));
+  });
+  fetch('/subnets').then(r => r.json()).then(sub => {
+    rows('subnets', ['Client', 'Runs', 'Success', 'Fail', 'Avg Rate'],
+      sub.map(s => [s.client, s.runs, s.success, s.fail, s.avg_rate.toFixed(1)]));
+  });
+}
+
+refresh();
+setInterval(refresh, 2000);
+</script>
+</body>
+</html>
+`
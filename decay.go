@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	decayInterval = flag.Duration("decay-interval", 5*time.Second, "Time between queries of the ttldecay command")
+	decayRounds   = flag.Int("decay-rounds", 6, "Number of queries per name made by the ttldecay command")
+)
+
+// decaySlack allows for the resolver and the client rounding differently
+const decaySlack = 2
+
+type decaySample struct {
+	at  time.Time
+	ttl uint32
+}
+
+func answerTTL(r *dns.Msg, qtype uint16) (uint32, bool) {
+	for _, a := range r.Answer {
+		if a.Header().Rrtype == qtype {
+			return a.Header().Ttl, true
+		}
+	}
+	return 0, false
+}
+
+// classifyDecay compares the TTLs a resolver returned over time with the
+// countdown expected from the authoritative TTL
+func classifyDecay(samples []decaySample, authTTL uint32) string {
+	var notes []string
+	static := len(samples) > 1
+	for i, s := range samples {
+		if authTTL > 0 && s.ttl > authTTL {
+			notes = append(notes, fmt.Sprintf("extended (%v > %v)", s.ttl, authTTL))
+			break
+		}
+		if i == 0 {
+			continue
+		}
+
+		prev := samples[i-1]
+		elapsed := uint32(s.at.Sub(prev.at) / time.Second)
+		if s.ttl != prev.ttl {
+			static = false
+		}
+		if s.ttl <= prev.ttl {
+			continue
+		}
+
+		// a refetch after expiry starts over from the full TTL, anything
+		// else going up means the cache entry was reset early
+		if prev.ttl > elapsed+decaySlack {
+			notes = append(notes, fmt.Sprintf("reset early (%v -> %v)", prev.ttl, s.ttl))
+		} else if authTTL > 0 && s.ttl+decaySlack < authTTL {
+			notes = append(notes, fmt.Sprintf("capped (%v < %v)", s.ttl, authTTL))
+		}
+	}
+	if static && samples[len(samples)-1].at.Sub(samples[0].at) > decaySlack*time.Second {
+		notes = append(notes, "static")
+	}
+	if len(notes) == 0 {
+		return "ok"
+	}
+	return strings.Join(notes, ", ")
+}
+
+func runTTLDecay(args []string) error {
+	names := args
+	if len(names) == 0 {
+		in, err := GetDomains(*domainList)
+		if err != nil {
+			return err
+		}
+		names = in
+	}
+
+	fmt.Printf("TTL Decay Test\n"+
+		"[+] Nameserver:       %v\n"+
+		"[+] Names:            %v\n"+
+		"[+] Rounds:           %v every %v\n\n",
+		*nameserver, len(names), *decayRounds, *decayInterval)
+
+	it := newCachingIterator()
+	authTTL := make([]uint32, len(names))
+	for i, n := range names {
+		if r, err := it.resolve(n, dns.TypeA, *client); err == nil {
+			authTTL[i], _ = answerTTL(r, dns.TypeA)
+		}
+	}
+
+	samples := make([][]decaySample, len(names))
+	for round := 0; round < *decayRounds; round++ {
+		if round > 0 {
+			time.Sleep(*decayInterval)
+		}
+		for i, n := range names {
+			r, _, err := exchange(n, dns.TypeA, dns.ClassINET)
+			if err != nil {
+				continue
+			}
+			if ttl, ok := answerTTL(r, dns.TypeA); ok {
+				samples[i] = append(samples[i], decaySample{time.Now(), ttl})
+			}
+		}
+	}
+
+	for i, n := range names {
+		if len(samples[i]) == 0 {
+			fmt.Printf("[-] %-40v no answers\n", dns.Fqdn(n))
+			continue
+		}
+
+		ttls := make([]string, 0, len(samples[i]))
+		for _, s := range samples[i] {
+			ttls = append(ttls, fmt.Sprint(s.ttl))
+		}
+		verdict := classifyDecay(samples[i], authTTL[i])
+		mark := "+"
+		if verdict != "ok" {
+			mark = "-"
+		}
+		auth := "?"
+		if authTTL[i] > 0 {
+			auth = fmt.Sprint(authTTL[i])
+		}
+		fmt.Printf("[%v] %-40v auth %-6v %v: %v\n", mark, dns.Fqdn(n), auth,
+			strings.Join(ttls, " "), verdict)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// parentReferral iterates towards zone and returns the referral the parent
+// zone's servers handed out for it
+func parentReferral(zone string) (*dns.Msg, string, error) {
+	var ref *dns.Msg
+	var parent string
+	it := &iterator{step: func(s delegationStep) {
+		if s.err == nil && s.referral == zone {
+			ref, parent = s.msg, s.zone
+		}
+	}}
+
+	if _, err := it.resolve(zone, dns.TypeNS, *client); err != nil && ref == nil {
+		return nil, "", err
+	}
+	if ref == nil {
+		return nil, "", fmt.Errorf("no referral found for %v", zone)
+	}
+	return ref, parent, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func runDelegation(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected a single zone")
+	}
+	zone := dns.Fqdn(strings.ToLower(args[0]))
+	if zone == "." {
+		return fmt.Errorf("the root zone has no parent")
+	}
+
+	ref, parent, err := parentReferral(zone)
+	if err != nil {
+		return err
+	}
+
+	parentNS := make(map[string]bool)
+	for _, ns := range nsRecords(ref, zone) {
+		parentNS[strings.ToLower(ns.Ns)] = true
+	}
+	glueAddrs := make(map[string][]string)
+	for _, g := range glue(ref, sortedKeys(parentNS)) {
+		glueAddrs[g.name] = append(glueAddrs[g.name], g.addr)
+	}
+
+	fmt.Printf("Delegation Check\n"+
+		"[+] Zone:             %v\n"+
+		"[+] Parent:           %v\n"+
+		"[+] Parent NS:        %v\n\n",
+		zone, parent, strings.Join(sortedKeys(parentNS), " "))
+
+	var servers []authServer
+	for _, n := range sortedKeys(parentNS) {
+		addrs := glueAddrs[n]
+		if len(addrs) == 0 {
+			addrs = lookupAddrs(n)
+		}
+		for _, a := range addrs {
+			servers = append(servers, authServer{n, a})
+		}
+	}
+
+	// every server answering with the wrong NS set is the same mismatch of
+	// the zone, the servers are collected under the names they disagree on
+	missingChild := make(map[string][]string)
+	missingParent := make(map[string][]string)
+	for _, s := range servers {
+		r, err := queryAuthoritative(s, zone, dns.TypeNS)
+		checkLame(zone, s, r, err)
+		if err != nil {
+			continue
+		}
+
+		childNS := make(map[string]bool)
+		for _, a := range r.Answer {
+			if ns, ok := a.(*dns.NS); ok {
+				childNS[strings.ToLower(ns.Ns)] = true
+			}
+		}
+		for _, n := range sortedKeys(parentNS) {
+			if !childNS[n] {
+				missingChild[n] = append(missingChild[n], fmt.Sprint(s))
+			}
+		}
+		for _, n := range sortedKeys(childNS) {
+			if !parentNS[n] {
+				missingParent[n] = append(missingParent[n], fmt.Sprint(s))
+			}
+		}
+	}
+
+	problems := 0
+	if len(missingChild) > 0 || len(missingParent) > 0 {
+		problems++
+		fmt.Printf("[-] child NS disagree with the parent NS\n")
+		for _, n := range sortedKeys(parentNS) {
+			if at, ok := missingChild[n]; ok {
+				fmt.Printf("[-]   %v missing from child NS at %v\n", n, strings.Join(at, ", "))
+			}
+		}
+		extra := make(map[string]bool)
+		for n := range missingParent {
+			extra[n] = true
+		}
+		for _, n := range sortedKeys(extra) {
+			fmt.Printf("[-]   %v missing from parent NS, listed by %v\n", n, strings.Join(missingParent[n], ", "))
+		}
+	}
+
+	// nameservers inside the zone cannot be found without glue, and the
+	// glue should agree with the address records served by the child
+	for _, n := range sortedKeys(parentNS) {
+		if !dns.IsSubDomain(zone, n) {
+			continue
+		}
+		if len(glueAddrs[n]) == 0 {
+			problems++
+			fmt.Printf("[-] %v is in-bailiwick but has no glue\n", n)
+			continue
+		}
+		if len(servers) == 0 {
+			continue
+		}
+
+		r, err := queryAuthoritative(servers[0], n, dns.TypeA)
+		if err != nil {
+			continue
+		}
+		child := make(map[string]bool)
+		for _, a := range r.Answer {
+			if t, ok := a.(*dns.A); ok {
+				child[t.A.String()] = true
+			}
+		}
+		for _, g := range glueAddrs[n] {
+			if !child[g] {
+				problems++
+				fmt.Printf("[-] glue %v %v not served by the child\n", n, g)
+			}
+		}
+	}
+
+	fmt.Printf("\n[+] Problems:         %v\n", problems)
+	lameReport()
+	return nil
+}
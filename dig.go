@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	digOutput = flag.String("dig", "", "Print responses in dig format after the run for failing queries (fail), every query (all) or a comma separated list of domains")
+)
+
+// digResponses holds the rendered responses until the run finished, so they
+// are not torn apart by the progress output
+var digResponses []string
+
+func digSelected(domain string, ok bool) bool {
+	switch *digOutput {
+	case "":
+		return false
+	case "all":
+		return true
+	case "fail":
+		return !ok
+	}
+	for _, d := range strings.Split(*digOutput, ",") {
+		if strings.EqualFold(dns.Fqdn(strings.TrimSpace(d)), domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordDig renders msg the way dig presents a response, a nil msg is a
+// query that was never answered
+func recordDig(domain string, qtype uint16, msg *dns.Msg, latency time.Duration, ok bool) {
+	if !digSelected(domain, ok) {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "; <<>> %v <<>> @%v %v %v\n", "dns-client-subnet-ext", *nameserver, domain, dns.TypeToString[qtype])
+	if msg == nil {
+		fmt.Fprintf(&b, ";; connection timed out; no servers could be reached\n")
+		digResponses = append(digResponses, b.String())
+		return
+	}
+	fmt.Fprintf(&b, ";; Got answer:\n%v\n", msg)
+	fmt.Fprintf(&b, ";; Query time: %d msec\n", latency.Milliseconds())
+	fmt.Fprintf(&b, ";; SERVER: %v\n", nameserverAddr())
+	fmt.Fprintf(&b, ";; WHEN: %v\n", time.Now().Format(time.UnixDate))
+	fmt.Fprintf(&b, ";; MSG SIZE  rcvd: %d\n", msg.Len())
+	digResponses = append(digResponses, b.String())
+}
+
+func digStats() {
+	for _, r := range digResponses {
+		fmt.Printf("\n%v", r)
+	}
+	if len(digResponses) > 0 {
+		fmt.Println()
+	}
+}
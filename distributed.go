@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	shardTimeout = flag.Duration("shard-timeout", 30*time.Minute, "Longest the coordinator waits for an agent to run its shard")
+)
+
+// shardDomains replaces the domain list file while an agent runs a shard
+var shardDomains []string
+
+// shardClient posts the shards, its timeout is set from -shard-timeout so
+// an agent that never answers cannot hold up the coordinator
+var shardClient = &http.Client{}
+
+type shardRequest struct {
+	Domains []string `json:"domains"`
+}
+
+type shardResult struct {
+	Agent   string       `json:"agent"`
+	Summary runSummary   `json:"summary"`
+	Results []sinkRecord `json:"results"`
+}
+
+// shardSink collects the outcome of every domain of a shard, unlike the
+// result stream it never drops any so the coordinator gets the whole shard
+type shardSink struct {
+	results []sinkRecord
+}
+
+func (s *shardSink) Name() string { return "shard" }
+
+func (s *shardSink) Open() error {
+	s.results = []sinkRecord{}
+	return nil
+}
+
+func (s *shardSink) Record(r sinkRecord) error {
+	s.results = append(s.results, r)
+	return nil
+}
+
+func (s *shardSink) Summary(sum runSummary) error { return nil }
+
+func (s *shardSink) Close() error { return nil }
+
+// agentBusy holds a token while a shard is running, an agent benchmarks one
+// shard at a time so its rate limits hold
+var agentBusy = make(chan struct{}, 1)
+
+func runAgent(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected a listen address")
+	}
+
+	getBanner(sendingDelay, retryDelay, clientLabel())
+	if *preflight && !*iterative {
+		if err := checkHealth(); err != nil {
+			return err
+		}
+	}
+	runProbes()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/shard", serveShard)
+	mux.HandleFunc("/status", apiStatus)
+	fmt.Printf("[+] Listening:        %v\n", args[0])
+	return http.ListenAndServe(args[0], mux)
+}
+
+func agentName() string {
+	h, err := os.Hostname()
+	if err != nil {
+		h = "unknown"
+	}
+	return fmt.Sprintf("%v/%v/%v", h, *nameserver, clientLabel())
+}
+
+// serveShard benchmarks the posted domains and answers with the run summary
+// and the outcome of every domain
+func serveShard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var req shardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	select {
+	case agentBusy <- struct{}{}:
+	default:
+		writeError(w, http.StatusConflict, errRunActive.Error())
+		return
+	}
+	defer func() { <-agentBusy }()
+
+	collect := &shardSink{}
+	sinks = append(sinks, collect)
+	defer func() { sinks = sinks[:len(sinks)-1] }()
+
+	shardDomains = req.Domains
+	completed, err := runBenchmark()
+	if err != nil {
+		shardDomains = nil
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	finalStats()
+	shardDomains = nil
+
+	writeJSON(w, http.StatusOK, shardResult{Agent: agentName(),
+		Summary: summarize(completed), Results: collect.results})
+}
+
+// shard deals the domains out round robin so every agent gets a similar
+// mix of popular and obscure names
+func shard(domains []string, n int) [][]string {
+	shards := make([][]string, n)
+	for i, d := range domains {
+		shards[i%n] = append(shards[i%n], d)
+	}
+	return shards
+}
+
+func postShard(agent string, domains []string) (*shardResult, error) {
+	body, err := json.Marshal(shardRequest{domains})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := shardClient.Post(strings.TrimRight(agent, "/")+"/shard", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e map[string]string
+		json.NewDecoder(resp.Body).Decode(&e)
+		return nil, fmt.Errorf("%v: %v", resp.Status, e["error"])
+	}
+	res := new(shardResult)
+	return res, json.NewDecoder(resp.Body).Decode(res)
+}
+
+func runCoordinate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected at least one agent url")
+	}
+	in, err := GetDomains(*domainList)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Distributed Run\n")
+	fmt.Printf("[+] Agents:           %v\n", len(args))
+	fmt.Printf("[+] Domains:          %v\n", len(in))
+
+	shardClient.Timeout = *shardTimeout
+	shards := shard(in, len(args))
+	results := make([]*shardResult, len(args))
+	errs := make([]error, len(args))
+	var wg sync.WaitGroup
+	for i, agent := range args {
+		wg.Add(1)
+		go func(i int, agent string) {
+			defer wg.Done()
+			results[i], errs[i] = postShard(agent, shards[i])
+		}(i, agent)
+	}
+	wg.Wait()
+
+	var total runSummary
+	total.Rcodes = make(map[string]int)
+	total.Errors = make(map[string]int64)
+
+	f, err := createOutputFile("distributed", "tsv")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "agent\tclient\tdomain\trcode\ttries\tips\n")
+
+	for i, agent := range args {
+		if errs[i] != nil {
+			fmt.Printf("[-] %v: %v\n", agent, errs[i])
+			continue
+		}
+		r := results[i]
+		s := r.Summary
+		fmt.Printf("[+] %v\n", r.Agent)
+		fmt.Printf("[+]   %-16s%v of %v (%.1f/s, completed %v)\n", "Success:",
+			s.Success, s.Attempts, s.AvgRate, s.Completed)
+
+		total.Attempts += s.Attempts
+		total.Success += s.Success
+		total.Fail += s.Fail
+		for k, n := range s.Rcodes {
+			total.Rcodes[k] += n
+		}
+		for k, n := range s.Errors {
+			total.Errors[k] += n
+		}
+		for _, q := range r.Results {
+			fmt.Fprintf(f, "%v\t%v\t%v\t%v\t%v\t%v\n", r.Agent, s.Client, q.Domain,
+				q.Rcode, q.Retries, strings.Join(q.Answers, ","))
+		}
+	}
+
+	fmt.Printf("[+] %-18s%v\n", "Attempts:", total.Attempts)
+	fmt.Printf("[+] %-18s%v\n", "Success:", total.Success)
+	fmt.Printf("[+] %-18s%v\n", "Fail:", total.Fail)
+	fmt.Printf("[+] Response Codes:\n")
+	for _, k := range sortedCounts(total.Rcodes) {
+		fmt.Printf("[+]   %-16s%v\n", k+":", total.Rcodes[k])
+	}
+	classes := make([]string, 0, len(total.Errors))
+	for k, n := range total.Errors {
+		if n > 0 {
+			classes = append(classes, k)
+		}
+	}
+	sort.Strings(classes)
+	if len(classes) > 0 {
+		fmt.Printf("[+] Errors:\n")
+		for _, k := range classes {
+			fmt.Printf("[+]   %-16s%v\n", k+":", total.Errors[k])
+		}
+	}
+	fmt.Printf("[+] %-18s%v\n", "Results:", f.Name())
+	return nil
+}
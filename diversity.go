@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+)
+
+// answerSets counts the responses of every domain by their sorted answer
+// addresses
+var answerSets map[string]map[string]int
+
+func recordDiversity(domain string, ips []net.IP) {
+	s := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		s = append(s, ip.String())
+	}
+	sort.Strings(s)
+
+	domain = strings.ToLower(domain)
+	if answerSets[domain] == nil {
+		answerSets[domain] = make(map[string]int)
+	}
+	answerSets[domain][strings.Join(s, ",")]++
+}
+
+// diversityScore is the entropy of the answer sets normalized by that of
+// every response getting a set of its own, 0 for a domain answered the
+// same way throughout and 1 when no two answers were the same
+func diversityScore(sets map[string]int) float64 {
+	n := 0
+	for _, c := range sets {
+		n += c
+	}
+	if n < 2 {
+		return 0
+	}
+	var h float64
+	for _, c := range sets {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h / math.Log2(float64(n))
+}
+
+// mergeAnswerSets adds the answer sets of another run to into
+func mergeAnswerSets(into, from map[string]map[string]int) {
+	for d, sets := range from {
+		if into[d] == nil {
+			into[d] = make(map[string]int)
+		}
+		for s, c := range sets {
+			into[d][s] += c
+		}
+	}
+}
+
+// diversityStats lists the domains with the most diverse answers, those
+// are the names a GeoDNS tailors to the client subnet
+func diversityStats(sets map[string]map[string]int) {
+	var varying []string
+	for d, s := range sets {
+		if len(s) > 1 {
+			varying = append(varying, d)
+		}
+	}
+	if len(varying) == 0 {
+		return
+	}
+	score := make(map[string]float64, len(varying))
+	for _, d := range varying {
+		score[d] = diversityScore(sets[d])
+	}
+	sort.Slice(varying, func(i, j int) bool {
+		if score[varying[i]] != score[varying[j]] {
+			return score[varying[i]] > score[varying[j]]
+		}
+		return varying[i] < varying[j]
+	})
+
+	fmt.Printf("[+] Answer Diversity: %v of %v domains vary\n", len(varying), len(sets))
+	for i, d := range varying {
+		if i == *topN {
+			break
+		}
+		fmt.Printf("[+]   %v: %v sets, score %.2f\n", d, len(sets[d]), score[d])
+	}
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+var (
+	dohURL    = flag.String("doh", "", "Query the DNS over HTTPS endpoint at URL instead of the nameserver over UDP")
+	dohMethod = flag.String("doh-method", "POST", "HTTP method of DoH queries (GET|POST)")
+	dohProto  = flag.String("doh-proto", "auto", "HTTP version of DoH queries, auto negotiates HTTP/2 where offered (auto|h1|h2|h3)")
+	userAgent = flag.String("user-agent", "", "User-Agent of DoH queries instead of the Go default")
+	dohProxy  = flag.String("doh-proxy", "", "HTTP proxy URL for DoH queries, by default HTTPS_PROXY and the like are honoured unless this is direct")
+)
+
+// headerList collects repeated -doh-header flags, values may contain
+// commas so they cannot share a single flag
+type headerList []string
+
+func (h *headerList) String() string { return strings.Join(*h, ", ") }
+
+func (h *headerList) Set(s string) error {
+	i := strings.Index(s, ":")
+	if i <= 0 {
+		return fmt.Errorf("header %q is not Name: value", s)
+	}
+	*h = append(*h, s)
+	return nil
+}
+
+var dohHeaders headerList
+
+func init() {
+	flag.Var(&dohHeaders, "doh-header", "Extra `Name: value` header of DoH queries, may be repeated")
+}
+
+// setHeaders applies -doh-header and -user-agent, a Host header changes
+// the request's host rather than the header map as net/http ignores it there
+func setHeaders(req *http.Request) {
+	for _, h := range dohHeaders {
+		i := strings.Index(h, ":")
+		name, value := strings.TrimSpace(h[:i]), strings.TrimSpace(h[i+1:])
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Add(name, value)
+	}
+	if *userAgent != "" {
+		req.Header.Set("User-Agent", *userAgent)
+	}
+}
+
+const dohMediaType = "application/dns-message"
+
+var dohClient *http.Client
+
+// plainNameserver and plainPreflight keep what -ns and -preflight were
+// before setupDoH took over, for commands that also query the nameserver
+var (
+	plainNameserver string
+	plainPreflight  bool
+)
+
+// dohStats counts how the endpoint let its answers be cached, GET answers
+// can be cached by any HTTP cache on the way while POST ones cannot
+var dohStats struct {
+	sync.Mutex
+	responses int
+	cacheable int
+	hits      int
+	status    map[int]int
+	protos    map[string]int
+	conns     map[string]int
+	altSvc    string
+}
+
+// newDoHClient pins the transport to the -doh-proto version, h1 leaves
+// TLSNextProto empty so HTTP/2 is never negotiated. net/http still offers
+// HTTP/1.1 next to h2 in ALPN, so h2 answers are checked by dohQuery.
+func newDoHClient() *http.Client {
+	if *dohProto == "h3" {
+		return &http.Client{Timeout: retryDelay, Transport: newH3Transport()}
+	}
+	t := &http.Transport{
+		ForceAttemptHTTP2:   true,
+		MaxIdleConnsPerHost: *concurrency,
+		IdleConnTimeout:     90 * time.Second,
+		TLSClientConfig: &tls.Config{VerifyPeerCertificate: verifyPins,
+			ClientSessionCache: tlsSessions},
+	}
+	t.Proxy = dohProxyFunc()
+	if socksDialer != nil {
+		t.DialContext = socksDialer.DialContext
+	} else {
+		t.DialContext = markedDialer{&net.Dialer{}}.DialContext
+	}
+	switch *dohProto {
+	case "h1":
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case "h2":
+		t.TLSClientConfig.NextProtos = []string{"h2"}
+	}
+	return &http.Client{Timeout: retryDelay, Transport: t}
+}
+
+// newH3Transport speaks HTTP/3 over QUIC. Its connections bypass httptrace,
+// so they are counted and their handshakes timed as they are dialed.
+func newH3Transport() *http3.RoundTripper {
+	return &http3.RoundTripper{
+		TLSClientConfig: &tls.Config{VerifyPeerCertificate: verifyPins,
+			ClientSessionCache: tlsSessions},
+		Dial: func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+			start := time.Now()
+			conn, err := quic.DialAddrEarly(ctx, addr, tlsCfg, cfg)
+			if err != nil {
+				return nil, err
+			}
+			dohStats.Lock()
+			dohStats.conns["HTTP/3.0"]++
+			dohStats.Unlock()
+			go func() {
+				select {
+				case <-conn.HandshakeComplete():
+					recordHandshake(addr, time.Since(start), conn.ConnectionState().TLS.DidResume)
+				case <-conn.Context().Done():
+				}
+			}()
+			return conn, nil
+		},
+	}
+}
+
+// dohProxyFunc picks the proxy of DoH requests, the environment is not
+// consulted once -socks5 is given so requests are not tunneled twice
+func dohProxyFunc() func(*http.Request) (*url.URL, error) {
+	switch {
+	case *dohProxy == "direct":
+		return nil
+	case *dohProxy != "":
+		u, _ := url.Parse(*dohProxy)
+		return http.ProxyURL(u)
+	case socksDialer != nil:
+		return nil
+	}
+	return http.ProxyFromEnvironment
+}
+
+// dohProxyLabel is the proxy requests to the endpoint go through, if any
+func dohProxyLabel() string {
+	f := dohProxyFunc()
+	if f == nil {
+		return ""
+	}
+	req, err := http.NewRequest("GET", *dohURL, nil)
+	if err != nil {
+		return ""
+	}
+	u, err := f(req)
+	if err != nil || u == nil {
+		return ""
+	}
+	return u.Host
+}
+
+// recordProto counts the negotiated version per response and per new
+// connection, the Alt-Svc header is kept to show endpoints offering HTTP/3
+func recordProto(resp *http.Response, fresh bool) {
+	dohStats.Lock()
+	defer dohStats.Unlock()
+	dohStats.protos[resp.Proto]++
+	if fresh {
+		dohStats.conns[resp.Proto]++
+	}
+	if alt := resp.Header.Get("Alt-Svc"); alt != "" {
+		dohStats.altSvc = alt
+	}
+}
+
+// dohRequest builds the request for a query, GET requests carry it with
+// a zero ID so identical questions share a cache entry (RFC 8484 4.1)
+func dohRequest(msg []byte) (*http.Request, error) {
+	if *dohMethod == "GET" {
+		q := append([]byte{0, 0}, msg[2:]...)
+		u := *dohURL + "?dns=" + base64.RawURLEncoding.EncodeToString(q)
+		if strings.Contains(*dohURL, "?") {
+			u = *dohURL + "&dns=" + base64.RawURLEncoding.EncodeToString(q)
+		}
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", dohMediaType)
+		return req, nil
+	}
+
+	req, err := http.NewRequest("POST", *dohURL, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dohMediaType)
+	req.Header.Set("Accept", dohMediaType)
+	return req, nil
+}
+
+// recordCaching notes whether a response may be cached and whether it came
+// out of a cache, judged by max-age and by Age or the X-Cache style
+// headers CDNs add
+func recordCaching(resp *http.Response) {
+	dohStats.Lock()
+	defer dohStats.Unlock()
+	dohStats.responses++
+	dohStats.status[resp.StatusCode]++
+
+	cc := strings.ToLower(resp.Header.Get("Cache-Control"))
+	if strings.Contains(cc, "max-age") && !strings.Contains(cc, "no-store") &&
+		!strings.Contains(cc, "max-age=0") {
+		dohStats.cacheable++
+	}
+	age, _ := strconv.Atoi(resp.Header.Get("Age"))
+	hit := age > 0
+	for _, h := range []string{"X-Cache", "CF-Cache-Status", "X-Cache-Status"} {
+		if strings.Contains(strings.ToUpper(resp.Header.Get(h)), "HIT") {
+			hit = true
+		}
+	}
+	if hit {
+		dohStats.hits++
+	}
+}
+
+func dohQuery(dr *domainRecord, resolved chan<- *domainAnswer, stop <-chan struct{}) {
+	var req *http.Request
+	var err error
+	if *dohFormat == "json" {
+		req, err = dohJSONRequest(dr)
+	} else {
+		msg, _ := buildQuery(dr.id, dr.domain, dr.qtype, dns.ClassINET, dr.subnet)
+		req, err = dohRequest(msg)
+	}
+	if err != nil {
+		countError(errNetwork)
+		return
+	}
+	setHeaders(req)
+
+	// the query is timed from when the request was written so a new
+	// connection's setup is only accounted for in the setup phases
+	fresh := false
+	var connectStart, tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				recordConnect(time.Since(connectStart))
+			}
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(cs tls.ConnectionState, err error) {
+			if err == nil {
+				recordHandshake(req.URL.Host, time.Since(tlsStart), cs.DidResume)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) { fresh = !info.Reused },
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			atomic.StoreInt64(&dr.sent, time.Now().UnixNano())
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	atomic.StoreInt64(&dr.sent, time.Now().UnixNano())
+	resp, err := dohClient.Do(req)
+	if err != nil {
+		countError(dialError(err))
+		if *verbose {
+			fmt.Fprintf(os.Stderr, "0x%04x doh: %s\n", dr.id, err)
+		}
+		return
+	}
+	defer resp.Body.Close()
+	recordCaching(resp)
+	recordProto(resp, fresh)
+	if *dohProto == "h2" && resp.ProtoMajor != 2 {
+		countError(errNetwork)
+		if *verbose {
+			fmt.Fprintf(os.Stderr, "0x%04x doh: endpoint answered with %v instead of HTTP/2\n", dr.id, resp.Proto)
+		}
+		return
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		countError(errNetwork)
+		if *verbose {
+			fmt.Fprintf(os.Stderr, "0x%04x doh: %s %v\n", dr.id, resp.Status, err)
+		}
+		return
+	}
+	r := new(dns.Msg)
+	if *dohFormat == "json" {
+		r, err = decodeDoHJSON(body, dr)
+	} else {
+		err = r.Unpack(body)
+	}
+	if err != nil || len(r.Question) == 0 {
+		countError(errMalformed)
+		return
+	}
+
+	// the HTTP exchange already pairs the answer with its query, the ID
+	// is restored for the correlator since GET queries went out as zero
+	r.Id = dr.id
+	da := newAnswer(r)
+	da.socket = dr.socket
+	select {
+	case resolved <- da:
+	case <-stop:
+	}
+}
+
+func writeDoH(tryResolving <-chan *domainRecord, resolved chan<- *domainAnswer, stop <-chan struct{}) {
+	for dr := range tryResolving {
+		go dohQuery(dr, resolved, stop)
+		atomic.AddInt64(&stats.sent, 1)
+		time.Sleep(sendingDelay)
+	}
+}
+
+// setupDoH replaces the nameserver by the endpoint's host for labelling
+// the output, the UDP preflight does not apply
+func setupDoH() error {
+	u, err := url.Parse(*dohURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("unsupported scheme %v", u.Scheme)
+	}
+	if *dohMethod != "GET" && *dohMethod != "POST" {
+		return fmt.Errorf("unknown method %v", *dohMethod)
+	}
+	if *dohFormat != "wire" && *dohFormat != "json" {
+		return fmt.Errorf("unknown format %v", *dohFormat)
+	}
+	if *dohProxy != "" && *dohProxy != "direct" {
+		p, err := url.Parse(*dohProxy)
+		if err != nil || (p.Scheme != "http" && p.Scheme != "https") || p.Host == "" {
+			return fmt.Errorf("proxy %v is not an http or https URL", *dohProxy)
+		}
+	}
+	switch *dohProto {
+	case "auto", "h1":
+	case "h2":
+		if u.Scheme != "https" {
+			return fmt.Errorf("h2 needs an https endpoint, cleartext HTTP/2 is not supported")
+		}
+	case "h3":
+		if u.Scheme != "https" {
+			return fmt.Errorf("h3 needs an https endpoint")
+		}
+		if *socksProxy != "" || (*dohProxy != "" && *dohProxy != "direct") {
+			return fmt.Errorf("h3 runs over UDP and cannot use -socks5 or -doh-proxy")
+		}
+		if *dscp != 0 {
+			return fmt.Errorf("h3 connections cannot be marked with -dscp")
+		}
+	default:
+		return fmt.Errorf("unknown protocol %v", *dohProto)
+	}
+	plainNameserver, plainPreflight = *nameserver, *preflight
+	*nameserver = u.Host
+	*preflight = false
+	return nil
+}
+
+func resetDoH() {
+	dohStats.Lock()
+	dohStats.responses, dohStats.cacheable, dohStats.hits = 0, 0, 0
+	dohStats.status = make(map[int]int)
+	dohStats.protos = make(map[string]int)
+	dohStats.conns = make(map[string]int)
+	dohStats.altSvc = ""
+	dohStats.Unlock()
+	if *dohURL != "" {
+		// the QUIC transport holds a UDP socket until it is closed
+		if dohClient != nil {
+			if c, ok := dohClient.Transport.(io.Closer); ok {
+				c.Close()
+			}
+		}
+		dohClient = newDoHClient()
+	}
+}
+
+func dohReport() {
+	dohStats.Lock()
+	defer dohStats.Unlock()
+	if dohStats.responses == 0 {
+		return
+	}
+	method := *dohMethod
+	if *dohFormat == "json" {
+		method = "GET (json)"
+	}
+	fmt.Printf("[+] DoH Method:       %v\n"+
+		"[+]   %-16s%v of %v\n"+
+		"[+]   %-16s%v\n",
+		method, "Cacheable:", dohStats.cacheable, dohStats.responses, "Cache Hits:", dohStats.hits)
+	if p := dohProxyLabel(); p != "" {
+		fmt.Printf("[+]   %-16s%v\n", "Proxy:", p)
+	}
+	for _, proto := range sortedCounts(dohStats.protos) {
+		fmt.Printf("[+]   %-16s%v (%v connections)\n", proto+":", dohStats.protos[proto], dohStats.conns[proto])
+	}
+	if strings.Contains(dohStats.altSvc, "h3") {
+		fmt.Printf("[+]   %-16s%v\n", "HTTP/3 Offered:", dohStats.altSvc)
+	}
+	codes := make([]int, 0, len(dohStats.status))
+	for code := range dohStats.status {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		if code != http.StatusOK {
+			fmt.Printf("[-]   %-16s%v\n", fmt.Sprintf("HTTP %d:", code), dohStats.status[code])
+		}
+	}
+}
+
+// runDoHMethods benchmarks the endpoint with GET and then POST and sets
+// their latency and cacheability side by side
+func runDoHMethods(args []string) error {
+	if *dohURL == "" {
+		return fmt.Errorf("missing required -doh endpoint")
+	}
+	if *domainList == "" {
+		return fmt.Errorf("missing required domain list")
+	}
+
+	type methodRun struct {
+		method    string
+		summary   runSummary
+		cacheable int
+		hits      int
+	}
+	var runs []methodRun
+	for _, m := range []string{"GET", "POST"} {
+		*dohMethod = m
+		findings = nil
+
+		getBanner(sendingDelay, retryDelay, clientLabel())
+		fmt.Printf("[+] DoH Method:       %v\n\n", m)
+		completed, err := runBenchmark()
+		if err != nil {
+			return err
+		}
+		finalStats()
+		fmt.Println()
+
+		runs = append(runs, methodRun{m, summarize(completed), dohStats.cacheable, dohStats.hits})
+	}
+
+	fmt.Printf("DoH Method Comparison\n")
+	fmt.Printf("[+] %-8s%9v%9v%11v%11v%11v\n", "Method", "Success", "Fail", "p95", "Cacheable", "Hits")
+	for _, r := range runs {
+		fmt.Printf("[+] %-8s%9v%9v%11v%11v%11v\n", r.method, r.summary.Success, r.summary.Fail,
+			formatMs(msDuration(r.summary.LatencyP95)), r.cacheable, r.hits)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	dohFormat = flag.String("doh-format", "wire", "Format of DoH queries, RFC 8484 messages or the JSON API of Google and Cloudflare (wire|json)")
+)
+
+const dohJSONType = "application/dns-json"
+
+type dohJSONRecord struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+	TTL  uint32 `json:"TTL"`
+	Data string `json:"data"`
+}
+
+type dohJSONResponse struct {
+	Status    int             `json:"Status"`
+	TC        bool            `json:"TC"`
+	RD        bool            `json:"RD"`
+	RA        bool            `json:"RA"`
+	AD        bool            `json:"AD"`
+	CD        bool            `json:"CD"`
+	Answer    []dohJSONRecord `json:"Answer"`
+	Authority []dohJSONRecord `json:"Authority"`
+}
+
+// dohJSONRequest asks for name and type as query parameters, the client
+// subnet goes along as edns_client_subnet which both APIs understand
+func dohJSONRequest(dr *domainRecord) (*http.Request, error) {
+	v := url.Values{}
+	v.Set("name", dr.domain)
+	v.Set("type", typeName(dr.qtype))
+	if dr.subnet != "" {
+		v.Set("edns_client_subnet", dr.subnet)
+	}
+	sep := "?"
+	if strings.Contains(*dohURL, "?") {
+		sep = "&"
+	}
+	req, err := http.NewRequest("GET", *dohURL+sep+v.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", dohJSONType)
+	return req, nil
+}
+
+func jsonRecords(records []dohJSONRecord) []dns.RR {
+	var rrs []dns.RR
+	for _, a := range records {
+		rr, err := dns.NewRR(fmt.Sprintf("%v %d IN %v %v", dns.Fqdn(a.Name), a.TTL, typeName(a.Type), a.Data))
+		if err != nil || rr == nil {
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs
+}
+
+// decodeDoHJSON maps a JSON answer onto a DNS message so it passes through
+// the same accounting as wire format answers, records the dns package
+// cannot parse from their presentation format are dropped
+func decodeDoHJSON(body []byte, dr *domainRecord) (*dns.Msg, error) {
+	var j dohJSONResponse
+	if err := json.Unmarshal(body, &j); err != nil {
+		return nil, err
+	}
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(dr.domain), dr.qtype)
+	m.Response = true
+	m.Rcode = j.Status
+	m.Truncated = j.TC
+	m.RecursionDesired = j.RD
+	m.RecursionAvailable = j.RA
+	m.AuthenticatedData = j.AD
+	m.CheckingDisabled = j.CD
+	m.Answer = jsonRecords(j.Answer)
+	m.Ns = jsonRecords(j.Authority)
+	return m, nil
+}
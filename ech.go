@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+var (
+	// echConfigs maps the domains of the run that publish an ECH config
+	// in their HTTPS or SVCB records to the config
+	echConfigs map[string]string
+	svcAnswers int
+)
+
+func recordECH(domain string, svc []*svcRecord) {
+	if len(svc) == 0 {
+		return
+	}
+	svcAnswers++
+	for _, s := range svc {
+		if s.ECH != "" {
+			echConfigs[domain] = s.ECH
+			return
+		}
+	}
+}
+
+func svcRecords(da *domainAnswer) []*svcRecord {
+	var svc []*svcRecord
+	for _, a := range da.msg.Answer {
+		if s, ok := parseSVCB(a); ok {
+			svc = append(svc, s)
+		}
+	}
+	return svc
+}
+
+func echDomains() []string {
+	names := make([]string, 0, len(echConfigs))
+	for n := range echConfigs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// echStats reports how many domains offer Encrypted Client Hello to this
+// client subnet and writes them along with their configs
+func echStats() {
+	if svcAnswers == 0 {
+		return
+	}
+	fmt.Printf("[+] ECH Configs:      %v of %v domains\n", len(echConfigs), svcAnswers)
+	if len(echConfigs) == 0 {
+		return
+	}
+
+	f, err := createOutputFile("ech", "txt")
+	if err != nil {
+		fmt.Printf("Error writing ECH file\n%v\n", err)
+		return
+	}
+	defer f.Close()
+
+	for _, n := range echDomains() {
+		fmt.Fprintf(f, "%v\t%v\n", n, echConfigs[n])
+	}
+}
+
+// echDifferences lists the domains that publish ECH to some of the
+// compared targets but not to all of them
+func echDifferences(runs []compareRun) []string {
+	seen := make(map[string]int)
+	for _, r := range runs {
+		for n := range r.ech {
+			seen[n]++
+		}
+	}
+	var differ []string
+	for n, c := range seen {
+		if c < len(runs) {
+			differ = append(differ, n)
+		}
+	}
+	sort.Strings(differ)
+	return differ
+}
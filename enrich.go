@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+var (
+	enrichList = flag.String("enrich", "", "Comma separated enrichers annotating every distinct answer record: geoip, asn, cdn, rdap or exec:COMMAND")
+	asnDB      = flag.String("asn-db", "", "MaxMind ASN database of the asn and cdn enrichers")
+)
+
+// Enricher annotates an answer record, annotations are keyed by what they
+// describe such as "country" or "asn". Records an enricher knows nothing
+// about get no annotations and no error.
+type Enricher interface {
+	Name() string
+	Enrich(rr dns.RR) (map[string]string, error)
+}
+
+// enricherFactories builds the enrichers named by -enrich from what follows
+// the colon of their entry, a new kind of enrichment only needs an entry
+var enricherFactories = map[string]func(arg string) (Enricher, error){
+	"asn":   newASNEnricher,
+	"cdn":   newCDNEnricher,
+	"exec":  newExecEnricher,
+	"geoip": newGeoIPEnricher,
+	"rdap":  newRDAPEnricher,
+}
+
+var (
+	enrichers []Enricher
+	// enrichRecords holds the distinct answer records of the run by type
+	// and data
+	enrichRecords map[string]dns.RR
+)
+
+func setupEnrichers() error {
+	enrichers = nil
+	if *enrichList == "" {
+		return nil
+	}
+	for _, e := range strings.Split(*enrichList, ",") {
+		kv := strings.SplitN(strings.TrimSpace(e), ":", 2)
+		f, ok := enricherFactories[kv[0]]
+		if !ok {
+			return fmt.Errorf("unknown enricher %q", kv[0])
+		}
+		arg := ""
+		if len(kv) == 2 {
+			arg = kv[1]
+		}
+		en, err := f(arg)
+		if err != nil {
+			return fmt.Errorf("%v: %v", kv[0], err)
+		}
+		enrichers = append(enrichers, en)
+	}
+	return nil
+}
+
+func recordEnrich(msg *dns.Msg) {
+	if enrichers == nil {
+		return
+	}
+	for _, a := range msg.Answer {
+		r := normalizeRR(a)
+		enrichRecords[r.Type+"\t"+r.Data] = a
+	}
+}
+
+func resetEnrich() {
+	enrichRecords = make(map[string]dns.RR)
+}
+
+type enrichEntry struct {
+	answerRecord
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// enrichStats runs every enricher over the distinct answer records once
+// the run is over so lookups never hold up queries. The annotated records
+// are written next to the other results and the most common value of
+// every annotation is reported.
+func enrichStats() {
+	if enrichers == nil || len(enrichRecords) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(enrichRecords))
+	for k := range enrichRecords {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	f, err := createOutputFile("enrichment", "jsonl")
+	if err != nil {
+		fmt.Printf("Error writing enrichment file\n%v\n", err)
+	}
+	var w *bufio.Writer
+	if f != nil {
+		defer f.Close()
+		w = bufio.NewWriter(f)
+		defer w.Flush()
+	}
+
+	values := make(map[string]map[string]int)
+	failed := make(map[string]int)
+	for _, k := range keys {
+		rr := enrichRecords[k]
+		e := enrichEntry{answerRecord: normalizeRR(rr)}
+		for _, en := range enrichers {
+			a, err := en.Enrich(rr)
+			if err != nil {
+				failed[en.Name()]++
+				if *verbose {
+					fmt.Fprintf(os.Stderr, "enrich(%s): %s\n", en.Name(), err)
+				}
+				continue
+			}
+			for key, v := range a {
+				if e.Annotations == nil {
+					e.Annotations = make(map[string]string)
+				}
+				e.Annotations[key] = v
+				if values[key] == nil {
+					values[key] = make(map[string]int)
+				}
+				values[key][v]++
+			}
+		}
+		if w != nil {
+			b, _ := json.Marshal(e)
+			w.Write(b)
+			w.WriteByte('\n')
+		}
+	}
+
+	fmt.Printf("[+] Enrichment:       %v records\n", len(keys))
+	names := make([]string, 0, len(values))
+	for key := range values {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		top := sortedSites(values[key])
+		if *topN > 0 && len(top) > *topN {
+			top = top[:*topN]
+		}
+		counts := make([]string, 0, len(top))
+		for _, v := range top {
+			counts = append(counts, fmt.Sprintf("%v (%v)", v, values[key][v]))
+		}
+		fmt.Printf("[+]   %-16s%v\n", key+":", strings.Join(counts, ", "))
+	}
+	for _, en := range enrichers {
+		if n := failed[en.Name()]; n > 0 {
+			fmt.Printf("[+]   %-16s%v\n", en.Name()+" errors:", n)
+		}
+	}
+	if rdapChanged {
+		saveRDAPCache()
+	}
+}
+
+// answerIP returns the address of A and AAAA records
+func answerIP(rr dns.RR) net.IP {
+	switch t := rr.(type) {
+	case *dns.A:
+		return t.A
+	case *dns.AAAA:
+		return t.AAAA
+	}
+	return nil
+}
+
+type geoipEnricher struct{}
+
+func newGeoIPEnricher(string) (Enricher, error) {
+	if *geoipDB == "" {
+		return nil, fmt.Errorf("needs -geoip")
+	}
+	_, err := openGeoIP()
+	return geoipEnricher{}, err
+}
+
+func (geoipEnricher) Name() string { return "geoip" }
+
+func (geoipEnricher) Enrich(rr dns.RR) (map[string]string, error) {
+	ip := answerIP(rr)
+	if ip == nil {
+		return nil, nil
+	}
+	if c := countryOf(ip); c != "" {
+		return map[string]string{"country": c}, nil
+	}
+	return nil, nil
+}
+
+type asnRecord struct {
+	Number uint   `maxminddb:"autonomous_system_number"`
+	Org    string `maxminddb:"autonomous_system_organization"`
+}
+
+// asnReader is shared by the asn and cdn enrichers
+var asnReader *maxminddb.Reader
+
+func openASN() (*maxminddb.Reader, error) {
+	if asnReader != nil {
+		return asnReader, nil
+	}
+	if *asnDB == "" {
+		return nil, fmt.Errorf("needs -asn-db")
+	}
+	db, err := maxminddb.Open(*asnDB)
+	if err != nil {
+		return nil, err
+	}
+	asnReader = db
+	return db, nil
+}
+
+func lookupASN(ip net.IP) (asnRecord, error) {
+	var r asnRecord
+	if asnReader == nil || ip == nil {
+		return r, nil
+	}
+	err := asnReader.Lookup(ip, &r)
+	return r, err
+}
+
+type asnEnricher struct{}
+
+func newASNEnricher(string) (Enricher, error) {
+	_, err := openASN()
+	return asnEnricher{}, err
+}
+
+func (asnEnricher) Name() string { return "asn" }
+
+func (asnEnricher) Enrich(rr dns.RR) (map[string]string, error) {
+	r, err := lookupASN(answerIP(rr))
+	if err != nil || r.Number == 0 {
+		return nil, err
+	}
+	return map[string]string{"asn": fmt.Sprintf("AS%d", r.Number), "as_org": r.Org}, nil
+}
+
+// cdnSuffixes are the names CDNs point their customers' aliases at
+var cdnSuffixes = []struct {
+	suffix string
+	cdn    string
+}{
+	{".akamaiedge.net.", "Akamai"},
+	{".akamai.net.", "Akamai"},
+	{".edgekey.net.", "Akamai"},
+	{".edgesuite.net.", "Akamai"},
+	{".cloudfront.net.", "CloudFront"},
+	{".fastly.net.", "Fastly"},
+	{".fastlylb.net.", "Fastly"},
+	{".cdn.cloudflare.net.", "Cloudflare"},
+	{".azureedge.net.", "Azure CDN"},
+	{".azurefd.net.", "Azure Front Door"},
+	{".googlehosted.com.", "Google"},
+	{".cdn77.org.", "CDN77"},
+	{".b-cdn.net.", "Bunny"},
+	{".llnwd.net.", "Limelight"},
+}
+
+// cdnNetworks match the organization of the autonomous system announcing
+// an address
+var cdnNetworks = []struct {
+	keyword string
+	cdn     string
+}{
+	{"akamai", "Akamai"},
+	{"cloudflare", "Cloudflare"},
+	{"fastly", "Fastly"},
+	{"amazon", "CloudFront"},
+	{"google", "Google"},
+	{"microsoft", "Azure CDN"},
+	{"edgecast", "Edgecast"},
+	{"limelight", "Limelight"},
+	{"cdn77", "CDN77"},
+}
+
+type cdnEnricher struct{}
+
+// newCDNEnricher recognizes aliases by name and, given -asn-db, addresses
+// by the network announcing them
+func newCDNEnricher(string) (Enricher, error) {
+	if *asnDB != "" {
+		if _, err := openASN(); err != nil {
+			return nil, err
+		}
+	}
+	return cdnEnricher{}, nil
+}
+
+func (cdnEnricher) Name() string { return "cdn" }
+
+func (cdnEnricher) Enrich(rr dns.RR) (map[string]string, error) {
+	var target string
+	switch t := rr.(type) {
+	case *dns.CNAME:
+		target = t.Target
+	case *dns.DNAME:
+		target = t.Target
+	}
+	if target != "" {
+		target = strings.ToLower("." + target)
+		for _, c := range cdnSuffixes {
+			if strings.HasSuffix(target, c.suffix) {
+				return map[string]string{"cdn": c.cdn}, nil
+			}
+		}
+		return nil, nil
+	}
+
+	r, err := lookupASN(answerIP(rr))
+	if err != nil || r.Org == "" {
+		return nil, err
+	}
+	org := strings.ToLower(r.Org)
+	for _, c := range cdnNetworks {
+		if strings.Contains(org, c.keyword) {
+			return map[string]string{"cdn": c.cdn}, nil
+		}
+	}
+	return nil, nil
+}
+
+// rdapEnricher annotates addresses with their RDAP network, sharing the
+// cache and lookup limit of -rdap
+type rdapEnricher struct {
+	lookups int
+}
+
+func newRDAPEnricher(string) (Enricher, error) {
+	return &rdapEnricher{}, nil
+}
+
+func (*rdapEnricher) Name() string { return "rdap" }
+
+func (e *rdapEnricher) Enrich(rr dns.RR) (map[string]string, error) {
+	ip := answerIP(rr)
+	if ip == nil {
+		return nil, nil
+	}
+	if !rdapLoaded {
+		loadRDAPCache()
+	}
+	n := cachedNetwork(ip)
+	if n == nil {
+		if e.lookups == rdapMaxLookups {
+			return nil, nil
+		}
+		e.lookups++
+		var err error
+		if n, err = fetchRDAP(ip); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]string{"org": n.String(), "network": n.Handle}, nil
+}
+
+// execEnricher hands every record to a long running command as a JSON
+// line and reads its annotations back as a JSON object line, enrichment
+// lives outside the tool that way, an IPAM lookup for example
+type execEnricher struct {
+	command string
+	cmd     *exec.Cmd
+	in      *bufio.Writer
+	out     *bufio.Scanner
+}
+
+func newExecEnricher(arg string) (Enricher, error) {
+	args := strings.Fields(arg)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected exec:COMMAND")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &execEnricher{arg, cmd, bufio.NewWriter(in), bufio.NewScanner(out)}, nil
+}
+
+func (e *execEnricher) Name() string { return "exec" }
+
+func (e *execEnricher) Enrich(rr dns.RR) (map[string]string, error) {
+	b, _ := json.Marshal(normalizeRR(rr))
+	e.in.Write(b)
+	e.in.WriteByte('\n')
+	if err := e.in.Flush(); err != nil {
+		return nil, fmt.Errorf("%v: %v", e.command, err)
+	}
+	if !e.out.Scan() {
+		if err := e.out.Err(); err != nil {
+			return nil, fmt.Errorf("%v: %v", e.command, err)
+		}
+		return nil, fmt.Errorf("%v exited", e.command)
+	}
+	var a map[string]string
+	if err := json.Unmarshal(e.out.Bytes(), &a); err != nil {
+		return nil, fmt.Errorf("%v: %v", e.command, err)
+	}
+	return a, nil
+}
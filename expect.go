@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// expectSamples limits how many failed assertions are listed
+const expectSamples = 10
+
+var (
+	expectFile = flag.String("expect", "", "Location of expectations file (domain followed by required IPs or rcode)")
+)
+
+type expectation struct {
+	rcode int
+	ips   []net.IP
+}
+
+var (
+	expectations   map[string]*expectation
+	expectFailures []string
+	// expectChecked counts the answers held against an expectation, domains
+	// without one or lost before an answer are not checked
+	expectChecked int
+)
+
+// loadExpectations reads lines of the form
+//
+//	example.com 192.0.2.1 2001:db8::1
+//	missing.example NXDOMAIN
+//
+// where every listed address must appear in the answer, and an rcode
+// replaces the default expectation of NOERROR
+func loadExpectations(n string) error {
+	f, err := os.Open(n)
+	if err != nil {
+		return fmt.Errorf("Failed to open expectations file")
+	}
+	defer f.Close()
+
+	expectations = make(map[string]*expectation)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		e := &expectation{rcode: dns.RcodeSuccess}
+		for _, v := range fields[1:] {
+			if ip := net.ParseIP(v); ip != nil {
+				e.ips = append(e.ips, ip)
+			} else if rc, ok := dns.StringToRcode[strings.ToUpper(v)]; ok {
+				e.rcode = rc
+			} else {
+				return fmt.Errorf("line %d: %q is neither an address nor an rcode", line, v)
+			}
+		}
+		expectations[strings.ToLower(dns.Fqdn(fields[0]))] = e
+	}
+	return scanner.Err()
+}
+
+// checkExpectation returns a description of how the answer deviates from
+// the expectation for domain, or an empty string when it matches
+func checkExpectation(domain string, da *domainAnswer) string {
+	e := expectations[strings.ToLower(domain)]
+
+	if da.rcode != e.rcode {
+		return fmt.Sprintf("rcode %v, want %v", rcodeName(da.rcode), rcodeName(e.rcode))
+	}
+	for _, want := range e.ips {
+		found := false
+		for _, ip := range da.ips {
+			if ip.Equal(want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("missing %v", want)
+		}
+	}
+	return ""
+}
+
+func recordExpectation(domain string, da *domainAnswer) bool {
+	expectChecked++
+	msg := checkExpectation(domain, da)
+	if msg == "" {
+		return true
+	}
+
+	countError(errAssertion)
+	expectFailures = append(expectFailures, fmt.Sprintf("%v: %v", domain, msg))
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "0x%04x assertion failed %s: %s\n", da.id, domain, msg)
+	}
+	return false
+}
+
+func expectStats() {
+	if expectations == nil {
+		return
+	}
+	fmt.Printf("[+] Assertions:       %v checked, %v failed\n",
+		expectChecked, len(expectFailures))
+
+	for i, f := range expectFailures {
+		if i == expectSamples {
+			fmt.Printf("[+]   ... %v more\n", len(expectFailures)-expectSamples)
+			break
+		}
+		fmt.Printf("[+]   %v\n", f)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// eyeballsDelay is the head start IPv6 gets before IPv4 joins the race
+// (RFC 8305 section 3)
+const eyeballsDelay = 50 * time.Millisecond
+
+// nsAddr is the address a nameserver given by name was raced to
+var nsAddr string
+
+type familyResult struct {
+	family string
+	addr   string
+	rtt    time.Duration
+	err    error
+}
+
+func splitFamilies(ips []net.IP) (v6, v4 []net.IP) {
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	return v6, v4
+}
+
+func raceFamily(family string, ip net.IP, delay time.Duration, out chan<- familyResult) {
+	time.Sleep(delay)
+	m := new(dns.Msg)
+	m.SetQuestion(".", dns.TypeNS)
+
+	addr := net.JoinHostPort(ip.String(), "53")
+	c := &dns.Client{Timeout: retryDelay}
+	_, rtt, err := c.Exchange(m, addr)
+	out <- familyResult{family, addr, rtt, err}
+}
+
+// chooseFamily resolves a nameserver given by name and, when it has both
+// IPv6 and IPv4 addresses, queries both Happy Eyeballs style. The first
+// family to answer is used for the run, the other is still waited for so
+// their round trips can be compared.
+func chooseFamily() error {
+	if *localMode != "" || *dohURL != "" || *transport != "udp" {
+		return nil
+	}
+	nsAddr = ""
+	if *iterative || net.ParseIP(*nameserver) != nil {
+		return nil
+	}
+	ips, err := net.LookupIP(*nameserver)
+	if err != nil {
+		return fmt.Errorf("resolving nameserver %v: %v", *nameserver, err)
+	}
+	v6, v4 := splitFamilies(ips)
+	switch {
+	case len(v6) == 0:
+		nsAddr = net.JoinHostPort(v4[0].String(), "53")
+		addFinding("Address Family:", "IPv4 (no AAAA)")
+		return nil
+	case len(v4) == 0:
+		nsAddr = net.JoinHostPort(v6[0].String(), "53")
+		addFinding("Address Family:", "IPv6 (no A)")
+		return nil
+	}
+
+	out := make(chan familyResult, 2)
+	go raceFamily("IPv6", v6[0], 0, out)
+	go raceFamily("IPv4", v4[0], eyeballsDelay, out)
+
+	var results []familyResult
+	for i := 0; i < 2; i++ {
+		r := <-out
+		if r.err == nil && nsAddr == "" {
+			nsAddr = r.addr
+			addFinding("Address Family:", "%v (%v)", r.family, r.addr)
+		}
+		results = append(results, r)
+	}
+	for _, r := range results {
+		if r.err != nil {
+			addFinding(r.family+" RTT:", "failed (%v)", r.err)
+		} else {
+			addFinding(r.family+" RTT:", "%v", r.rtt)
+		}
+	}
+	if nsAddr == "" {
+		return fmt.Errorf("nameserver %v not reachable over IPv6 or IPv4", *nameserver)
+	}
+	return nil
+}
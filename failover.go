@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+)
+
+var (
+	secondary = flag.String("secondary", "", "Nameserver that queries timing out or failing on the primary are retried on")
+)
+
+var (
+	timeoutFailovers int
+	rcodeFailovers   int
+	secondaryAnswers int
+)
+
+// onSecondary tells whether socket belongs to the secondary nameserver,
+// its sockets follow those of the primary
+func onSecondary(socket int) bool {
+	return socket >= querySockets()
+}
+
+func socketAddr(socket int) string {
+	if onSecondary(socket) {
+		return net.JoinHostPort(*secondary, "53")
+	}
+	return nameserverAddr()
+}
+
+// failover moves a query that is still on the primary to the secondary
+// nameserver under a fresh ID, the way stub resolvers move down their list
+// of servers. It returns false when there is nowhere to move to, or no ID
+// of the secondary's socket is free and the query stays on the primary.
+func (c *correlator) failover(dr *domainRecord, answered bool) bool {
+	if *secondary == "" || onSecondary(dr.socket) {
+		return false
+	}
+	socket := dr.socket + querySockets()
+	id, wait := c.nextID(socket)
+	if wait > 0 {
+		return false
+	}
+	c.remove(dr, answered)
+
+	dr.socket, dr.id = socket, id
+	c.add(dr)
+	return true
+}
+
+// failoverAnswer feeds an answer to the breaker of the server it came
+// from and moves its query to the secondary when the answer is an error
+// and retries are left
+func (c *correlator) failoverAnswer(dr *domainRecord, da *domainAnswer) bool {
+	failed := isRcodeError(da.rcode)
+	recordHealth(dr.socket, !failed)
+	if !failed || dr.resend == *retryCount || !c.failover(dr, true) {
+		return false
+	}
+	rcodeFailovers++
+	return true
+}
+
+func failoverStats() {
+	if *secondary == "" {
+		return
+	}
+	fmt.Printf("[+] Failovers:        %v\n"+
+		"[+]   %-16s%v\n"+
+		"[+]   %-16s%v\n"+
+		"[+]   %-16s%v\n",
+		timeoutFailovers+rcodeFailovers,
+		"Timeout:", timeoutFailovers,
+		"Rcode:", rcodeFailovers,
+		"Answered:", secondaryAnswers)
+}
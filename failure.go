@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+type errorClass int
+
+const (
+	errTimeout errorClass = iota
+	errNetwork
+	errMalformed
+	errMismatch
+	errRcode
+	errAssertion
+	errTSIG
+	errPin
+	numErrorClasses
+)
+
+var errorClassNames = [numErrorClasses]string{
+	"Timeout",
+	"Network Error",
+	"Malformed",
+	"ID Mismatch",
+	"Server Rcode",
+	"Assertion",
+	"Bad TSIG",
+	"Pin Mismatch",
+}
+
+func (c errorClass) String() string {
+	return errorClassNames[c]
+}
+
+// errorCounts is updated from the reader and writer goroutines as well as
+// the map guard, so it is only accessed atomically
+var errorCounts [numErrorClasses]int64
+
+func countError(c errorClass) {
+	atomic.AddInt64(&errorCounts[c], 1)
+}
+
+func errorCount(c errorClass) int64 {
+	return atomic.LoadInt64(&errorCounts[c])
+}
+
+// isRcodeError reports whether the server answered but refused to give a
+// usable result, NXDOMAIN is an answer and not counted as an error
+func isRcodeError(rcode int) bool {
+	return rcode != dns.RcodeSuccess && rcode != dns.RcodeNameError
+}
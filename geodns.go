@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	geoExpectFile = flag.String("geo-expect", "", "File of client subnet, domain and the answers expected for them as addresses, prefixes or -geoip countries")
+)
+
+// geoRule holds the answers expected for the queries of a domain sent with
+// a subnet out of subnet, a domain of "*" stands for every domain
+type geoRule struct {
+	subnet    *net.IPNet
+	domain    string
+	nets      []*net.IPNet
+	countries []string
+}
+
+var (
+	geoRules []geoRule
+	// geoChecked and geoMismatches count the answers compared to a rule
+	geoChecked    int
+	geoMismatches []string
+)
+
+// loadGeoExpectations reads lines of the form
+//
+//	198.51.100.0/24 www.example.com 192.0.2.0/24 192.0.2.80
+//	203.0.113.0/24  *               JP
+//
+// where every address in the answer must fall into one of the expected
+// prefixes or be located in one of the countries
+func loadGeoExpectations(n string) error {
+	f, err := os.Open(n)
+	if err != nil {
+		return fmt.Errorf("Failed to open GeoDNS expectations file")
+	}
+	defer f.Close()
+
+	geoRules = nil
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 3 {
+			return fmt.Errorf("line %d: expected subnet, domain and answers", line)
+		}
+
+		subnet, err := parsePrefix(fields[0])
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		r := geoRule{subnet: subnet, domain: strings.ToLower(dns.Fqdn(fields[1]))}
+		if fields[1] == "*" {
+			r.domain = "*"
+		}
+		for _, v := range fields[2:] {
+			if n, err := parsePrefix(v); err == nil {
+				r.nets = append(r.nets, n)
+			} else if len(v) == 2 {
+				if *geoipDB == "" {
+					return fmt.Errorf("line %d: country %v needs -geoip", line, v)
+				}
+				r.countries = append(r.countries, strings.ToUpper(v))
+			} else {
+				return fmt.Errorf("line %d: %q is neither an address, a prefix nor a country", line, v)
+			}
+		}
+		geoRules = append(geoRules, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if *geoipDB != "" {
+		_, err = openGeoIP()
+	}
+	return err
+}
+
+// parsePrefix accepts a prefix or an address standing for itself
+func parsePrefix(s string) (*net.IPNet, error) {
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		return n, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("%q is neither an address nor a prefix", s)
+	}
+	bits := 8 * net.IPv6len
+	if v4 := ip.To4(); v4 != nil {
+		ip, bits = v4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// geoRuleFor returns the rule of the most specific prefix containing the
+// subnet, one naming the domain wins over a "*" one
+func geoRuleFor(subnet, domain string) *geoRule {
+	ip := net.ParseIP(strings.SplitN(subnet, "/", 2)[0])
+	if ip == nil {
+		return nil
+	}
+	domain = strings.ToLower(domain)
+
+	var best *geoRule
+	bestOnes := -1
+	for i := range geoRules {
+		r := &geoRules[i]
+		if (r.domain != domain && r.domain != "*") || !r.subnet.Contains(ip) {
+			continue
+		}
+		ones, _ := r.subnet.Mask.Size()
+		switch {
+		case best == nil,
+			best.domain == "*" && r.domain != "*",
+			best.domain == r.domain && ones > bestOnes:
+			best, bestOnes = r, ones
+		}
+	}
+	return best
+}
+
+func (r *geoRule) matches(ip net.IP) bool {
+	for _, n := range r.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	if len(r.countries) == 0 {
+		return false
+	}
+	c := countryOf(ip)
+	for _, want := range r.countries {
+		if c == want {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *geoRule) String() string {
+	want := make([]string, 0, len(r.nets)+len(r.countries))
+	for _, n := range r.nets {
+		want = append(want, n.String())
+	}
+	return strings.Join(append(want, r.countries...), " ")
+}
+
+// checkGeo returns a description of the answer addresses outside of what
+// the rule expects, or an empty string when all of them match
+func checkGeo(r *geoRule, da *domainAnswer) string {
+	if len(da.ips) == 0 {
+		return fmt.Sprintf("no addresses (%v), want %v", rcodeName(da.rcode), r)
+	}
+	var wrong []string
+	for _, ip := range da.ips {
+		if r.matches(ip) {
+			continue
+		}
+		if c := countryOf(ip); c != "" {
+			wrong = append(wrong, fmt.Sprintf("%v (%v)", ip, c))
+		} else {
+			wrong = append(wrong, ip.String())
+		}
+	}
+	if len(wrong) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%v, want %v", strings.Join(wrong, ", "), r)
+}
+
+// recordGeo compares the answer to the rule for the subnet the query was
+// sent with, queries without a rule pass
+func recordGeo(dr *domainRecord, da *domainAnswer) bool {
+	r := geoRuleFor(dr.subnet, dr.domain)
+	if r == nil {
+		return true
+	}
+	geoChecked++
+	msg := checkGeo(r, da)
+	if msg == "" {
+		return true
+	}
+
+	countError(errAssertion)
+	geoMismatches = append(geoMismatches, fmt.Sprintf("%v %v: %v", dr.subnet, dr.domain, msg))
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "0x%04x geo mismatch %s %s: %s\n", da.id, dr.subnet, dr.domain, msg)
+	}
+	return false
+}
+
+func resetGeo() {
+	geoChecked, geoMismatches = 0, nil
+}
+
+func geoStats() {
+	if geoRules == nil {
+		return
+	}
+	fmt.Printf("[+] GeoDNS Checks:    %v checked, %v mismatched\n", geoChecked, len(geoMismatches))
+
+	for i, m := range geoMismatches {
+		if i == expectSamples {
+			fmt.Printf("[+]   ... %v more\n", len(geoMismatches)-expectSamples)
+			break
+		}
+		fmt.Printf("[+]   %v\n", m)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+var (
+	geoipDB      = flag.String("geoip", "", "MaxMind country or city database the -client-country subnets are sampled from instead of the presets")
+	geoipSamples = flag.Int("geoip-samples", 5, "Number of networks sampled per country from the -geoip database")
+)
+
+// geoipReader stays open for the lookups of answer addresses
+var geoipReader *maxminddb.Reader
+
+type geoipRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// geoipSubnets walks every IPv4 network of the database once and keeps a
+// uniform sample of those located in each of the countries
+func geoipSubnets(codes []string) (map[string][]string, error) {
+	db, err := openGeoIP()
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make(map[string][]string, len(codes))
+	seen := make(map[string]int, len(codes))
+	for _, c := range codes {
+		samples[c] = nil
+	}
+
+	all := &net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}
+	networks := db.NetworksWithin(all, maxminddb.SkipAliasedNetworks)
+	for networks.Next() {
+		var r geoipRecord
+		n, err := networks.Network(&r)
+		if err != nil {
+			return nil, err
+		}
+		c := r.Country.ISOCode
+		if _, ok := samples[c]; !ok {
+			continue
+		}
+
+		seen[c]++
+		switch {
+		case len(samples[c]) < *geoipSamples:
+			samples[c] = append(samples[c], sampleSubnet(n))
+		default:
+			if i := rand.Intn(seen[c]); i < *geoipSamples {
+				samples[c][i] = sampleSubnet(n)
+			}
+		}
+	}
+	if err := networks.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, c := range codes {
+		if len(samples[c]) == 0 {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) != 0 {
+		return nil, fmt.Errorf("no networks located in %v", strings.Join(missing, ","))
+	}
+	return samples, nil
+}
+
+// sampleSubnet returns the first /24 of n, longer prefixes are kept as
+// they are
+func sampleSubnet(n *net.IPNet) string {
+	if ones, _ := n.Mask.Size(); ones < 24 {
+		n = &net.IPNet{IP: n.IP.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}
+	}
+	return n.String()
+}
+
+func openGeoIP() (*maxminddb.Reader, error) {
+	if geoipReader != nil {
+		return geoipReader, nil
+	}
+	db, err := maxminddb.Open(*geoipDB)
+	if err != nil {
+		return nil, err
+	}
+	geoipReader = db
+	return db, nil
+}
+
+// countryOf returns the country code of ip, or an empty string when it is
+// not located or no database is open
+func countryOf(ip net.IP) string {
+	if geoipReader == nil {
+		return ""
+	}
+	var r geoipRecord
+	if err := geoipReader.Lookup(ip, &r); err != nil {
+		return ""
+	}
+	return r.Country.ISOCode
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strings"
+)
+
+// goldenSamples limits how many regressions are listed
+const goldenSamples = 10
+
+var (
+	goldenSave = flag.String("golden-save", "", "Record this run's answers as a baseline to the given file")
+	goldenFile = flag.String("golden", "", "Compare answers against a baseline recorded with -golden-save")
+)
+
+type goldenEntry struct {
+	Rcode string   `json:"rcode"`
+	IPs   []string `json:"ips,omitempty"`
+}
+
+var (
+	goldenRun      = make(map[string]goldenEntry)
+	goldenBaseline map[string]goldenEntry
+)
+
+func loadGolden(n string) error {
+	b, err := ioutil.ReadFile(n)
+	if err != nil {
+		return fmt.Errorf("Failed to open baseline file")
+	}
+	return json.Unmarshal(b, &goldenBaseline)
+}
+
+// recordOutcome keeps the final result of domain, rcode is TIMEOUT when
+// the retries were exhausted without an answer
+func recordOutcome(domain, rcode string, ips []net.IP) {
+	if *goldenSave == "" && goldenBaseline == nil {
+		return
+	}
+
+	s := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		s = append(s, ip.String())
+	}
+	sort.Strings(s)
+	goldenRun[strings.ToLower(domain)] = goldenEntry{rcode, s}
+}
+
+func (g goldenEntry) String() string {
+	if len(g.IPs) == 0 {
+		return g.Rcode
+	}
+	return fmt.Sprintf("%v %v", g.Rcode, strings.Join(g.IPs, ","))
+}
+
+func (g goldenEntry) equal(o goldenEntry) bool {
+	return g.String() == o.String()
+}
+
+func goldenStats() {
+	if *goldenSave != "" {
+		b, _ := json.MarshalIndent(goldenRun, "", "  ")
+		if err := ioutil.WriteFile(*goldenSave, b, 0644); err != nil {
+			fmt.Printf("Error writing baseline file\n%v\n", err)
+		}
+	}
+	if goldenBaseline == nil {
+		return
+	}
+
+	domains := make([]string, 0, len(goldenRun))
+	for d := range goldenRun {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+
+	var changed, nxdomain []string
+	for _, d := range domains {
+		before, ok := goldenBaseline[d]
+		after := goldenRun[d]
+		if !ok || before.equal(after) {
+			continue
+		}
+		if after.Rcode == "NXDOMAIN" && before.Rcode != "NXDOMAIN" {
+			nxdomain = append(nxdomain, d)
+		}
+		changed = append(changed, fmt.Sprintf("%v: %v -> %v", d, before, after))
+	}
+
+	fmt.Printf("[+] Regressions:      %v changed, %v new NXDOMAIN\n",
+		len(changed), len(nxdomain))
+	for i, c := range changed {
+		if i == goldenSamples {
+			fmt.Printf("[+]   ... %v more\n", len(changed)-goldenSamples)
+			break
+		}
+		fmt.Printf("[+]   %v\n", c)
+	}
+}
@@ -0,0 +1,158 @@
+package graph
+
+import (
+	"log"
+	"sort"
+
+	"github.com/wcharczuk/go-chart"
+)
+
+// boxStats are the five numbers drawn for one box, the whiskers end at the
+// furthest samples within 1.5 IQR of the box
+type boxStats struct {
+	low, q1, median, q3, high float64
+}
+
+func quantile(s []float64, q float64) float64 {
+	i := int(q*float64(len(s)-1) + 0.5)
+	return s[i]
+}
+
+func newBoxStats(samples []float64) boxStats {
+	s := append([]float64(nil), samples...)
+	sort.Float64s(s)
+
+	b := boxStats{q1: quantile(s, 0.25), median: quantile(s, 0.5), q3: quantile(s, 0.75)}
+	fence := 1.5 * (b.q3 - b.q1)
+	b.low, b.high = b.q1, b.q3
+	for _, v := range s {
+		if v >= b.q1-fence && v < b.low {
+			b.low = v
+		}
+		if v <= b.q3+fence && v > b.high {
+			b.high = v
+		}
+	}
+	return b
+}
+
+// boxSeries draws one box per entry at x = 1, 2, ...
+type boxSeries struct {
+	boxes []boxStats
+}
+
+func (bs boxSeries) GetName() string           { return "Latency" }
+func (bs boxSeries) GetStyle() chart.Style     { return chart.Style{} }
+func (bs boxSeries) GetYAxis() chart.YAxisType { return chart.YAxisPrimary }
+func (bs boxSeries) Validate() error           { return nil }
+
+func (bs boxSeries) Render(r chart.Renderer, canvasBox chart.Box, xrange, yrange chart.Range, s chart.Style) {
+	half := (xrange.Translate(1.3) - xrange.Translate(1)) / 2
+	y := func(v float64) int { return canvasBox.Bottom - yrange.Translate(v) }
+
+	for i, b := range bs.boxes {
+		color := chart.GetDefaultColor(i)
+		x := canvasBox.Left + xrange.Translate(float64(i+1))
+
+		line := chart.Style{StrokeColor: color, StrokeWidth: 1.5}
+		line.WriteToRenderer(r)
+		r.MoveTo(x, y(b.low))
+		r.LineTo(x, y(b.q1))
+		r.MoveTo(x, y(b.q3))
+		r.LineTo(x, y(b.high))
+		r.MoveTo(x-half/2, y(b.low))
+		r.LineTo(x+half/2, y(b.low))
+		r.MoveTo(x-half/2, y(b.high))
+		r.LineTo(x+half/2, y(b.high))
+		r.Stroke()
+
+		chart.Draw.Box(r, chart.Box{Top: y(b.q3), Left: x - half, Right: x + half, Bottom: y(b.q1)},
+			chart.Style{StrokeColor: color, StrokeWidth: 1.5, FillColor: color.WithAlpha(64)})
+
+		median := chart.Style{StrokeColor: color, StrokeWidth: 3}
+		median.WriteToRenderer(r)
+		r.MoveTo(x-half, y(b.median))
+		r.LineTo(x+half, y(b.median))
+		r.Stroke()
+	}
+}
+
+// BuildLatencyBoxPlot renders the latency distributions of several runs
+// side by side into the nameserver's directory, labels name each run
+func BuildLatencyBoxPlot(nameserver, title string, labels []string, samples [][]float64, output string) {
+	buildBoxPlot(nameserver, false, title, "latency_box", labels, samples, output)
+}
+
+// BuildSubnetLatency renders the latency distribution of every client
+// subnet labels name
+func BuildSubnetLatency(nameserver string, clientStatus bool, title string,
+	labels []string, samples [][]float64, output string) {
+	buildBoxPlot(nameserver, clientStatus, title, "subnet_latency", labels, samples, output)
+}
+
+func buildBoxPlot(nameserver string, clientStatus bool, title, kind string,
+	labels []string, samples [][]float64, output string) {
+	var boxes []boxStats
+	var ticks []chart.Tick
+	max := 1.0
+	for i, s := range samples {
+		if len(s) == 0 {
+			continue
+		}
+		b := newBoxStats(s)
+		if b.high > max {
+			max = b.high
+		}
+		boxes = append(boxes, b)
+		ticks = append(ticks, chart.Tick{Value: float64(len(boxes)), Label: labels[i]})
+	}
+	if len(boxes) == 0 {
+		return
+	}
+	// the x range is taken from the ticks, unlabeled ones leave room for
+	// the outer boxes
+	ticks = append([]chart.Tick{{Value: 0.5}}, ticks...)
+	ticks = append(ticks, chart.Tick{Value: float64(len(boxes)) + 0.5})
+
+	graph := chart.Chart{
+		Title: title,
+		TitleStyle: chart.Style{
+			FontSize: 8.0,
+		},
+		Height: 400,
+		Width:  650,
+		Background: chart.Style{
+			Padding: chart.Box{
+				Top:    40,
+				Bottom: 20,
+				Left:   20,
+				Right:  20,
+				IsSet:  true,
+			},
+		},
+		XAxis: chart.XAxis{
+			Ticks: ticks,
+		},
+		YAxis: chart.YAxis{
+			Name: "Latency (ms)",
+			Range: &chart.ContinuousRange{
+				Min: 0.0,
+				Max: max * 1.05,
+			},
+		},
+		Series: []chart.Series{boxSeries{boxes}},
+	}
+
+	applyOptions(&graph)
+
+	f, err := createFile(output, nameserver, clientStatus, kind)
+	if err != nil {
+		log.Printf("Error writing to file\n%v", err)
+		return
+	}
+
+	defer f.Close()
+	if err := graph.Render(chart.PNG, f); err != nil {
+		log.Printf("Error rendering box plot\n%v", err)
+	}
+}
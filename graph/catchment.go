@@ -0,0 +1,7 @@
+package graph
+
+// BuildCatchmentGraph renders a bar chart of responses per answering site
+func BuildCatchmentGraph(nameserver string, clientStatus bool,
+	labels []string, counts []int, output string) {
+	buildBars(nameserver, clientStatus, "responses by NSID", "catchment", labels, counts, output)
+}
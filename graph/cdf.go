@@ -0,0 +1,116 @@
+package graph
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/wcharczuk/go-chart"
+)
+
+// cdfSeries returns the empirical distribution of samples as a step line,
+// y is the percentage of samples at or below x
+func cdfSeries(name string, color int, samples []float64) chart.ContinuousSeries {
+	s := append([]float64(nil), samples...)
+	sort.Float64s(s)
+
+	xs := make([]float64, 0, 2*len(s))
+	ys := make([]float64, 0, 2*len(s))
+	for i, v := range s {
+		xs = append(xs, v, v)
+		ys = append(ys, 100*float64(i)/float64(len(s)), 100*float64(i+1)/float64(len(s)))
+	}
+	return chart.ContinuousSeries{
+		Name: name,
+		Style: chart.Style{
+			StrokeColor: chart.GetDefaultColor(color),
+			StrokeWidth: 1.5,
+		},
+		XValues: xs,
+		YValues: ys,
+	}
+}
+
+// BuildLatencyCDF renders the cumulative distribution of latency for one or
+// more runs, labels name each run in the legend
+func BuildLatencyCDF(nameserver string, clientStatus bool,
+	labels []string, samples [][]float64, output string) {
+	buildCDF(nameserver, clientStatus, "cdf", fmt.Sprintf("ns:%v - latency distribution", nameserver),
+		labels, samples, output)
+}
+
+// BuildRunsCDF renders the latency distributions of the runs collected in
+// an appended data file
+func BuildRunsCDF(nameserver string, labels []string, samples [][]float64, output string) {
+	buildCDF(nameserver, false, "runs_cdf", fmt.Sprintf("ns:%v - latency distribution by run", nameserver),
+		labels, samples, output)
+}
+
+func buildCDF(nameserver string, clientStatus bool, kind, title string,
+	labels []string, samples [][]float64, output string) {
+	var series []chart.Series
+	max := 1.0
+	for i, s := range samples {
+		if len(s) < 2 {
+			continue
+		}
+		for _, v := range s {
+			if v > max {
+				max = v
+			}
+		}
+		series = append(series, cdfSeries(labels[i], len(series), s))
+	}
+	if len(series) == 0 {
+		return
+	}
+
+	graph := chart.Chart{
+		Title: title,
+		TitleStyle: chart.Style{
+			FontSize: 8.0,
+		},
+		Height: 400,
+		Width:  650,
+		Background: chart.Style{
+			Padding: chart.Box{
+				Top:    40,
+				Bottom: 20,
+				Left:   20,
+				Right:  20,
+				IsSet:  true,
+			},
+		},
+		XAxis: chart.XAxis{
+			Name: "Latency (ms)",
+			Range: &chart.ContinuousRange{
+				Min: 0.0,
+				Max: max,
+			},
+		},
+		YAxis: chart.YAxis{
+			Name: "Queries (%)",
+			Range: &chart.ContinuousRange{
+				Min: 0.0,
+				Max: 100.0,
+			},
+		},
+		Series: series,
+	}
+	graph.Elements = []chart.Renderable{
+		chart.LegendLeft(&graph, legendStyle()),
+	}
+
+	applyOptions(&graph)
+
+	f, err := createFile(output, nameserver, clientStatus, kind)
+	if err != nil {
+		log.Printf("Error writing to file\n%v", err)
+		return
+	}
+
+	defer f.Close()
+	if err := graph.Render(chart.PNG, f); err != nil {
+		log.Printf("Error rendering latency cdf\n%v", err)
+	}
+}
@@ -10,9 +10,10 @@ import (
 	"github.com/wcharczuk/go-chart"
 )
 
-// BuildGraph initializes new 2-axis graph
+// BuildGraph initializes new 2-axis graph and returns the path of the
+// rendered png
 func BuildGraph(nameserver, client string, clientStatus bool,
-	t, c *[]float64, threads, dmnCount int, output string) {
+	t, c *[]float64, rrl []float64, threads, dmnCount int, output string) string {
 	mainSeries := chart.ContinuousSeries{
 		Name:    "Rate",
 		XValues: *t,
@@ -24,6 +25,20 @@ func BuildGraph(nameserver, client string, clientStatus bool,
 		InnerSeries: mainSeries,
 	}
 
+	series := []chart.Series{
+		mainSeries,
+		smaSeries,
+		chart.ContinuousSeries{
+			Style: chart.Style{
+				StrokeColor: chart.GetDefaultColor(0).WithAlpha(64),
+				FillColor:   chart.GetDefaultColor(0).WithAlpha(64),
+			},
+		},
+	}
+	if len(rrl) > 0 {
+		series = append(series, throttleSeries(*t, *c, rrl))
+	}
+
 	graph := chart.Chart{
 		Title: fmt.Sprintf("ns:%v - subnet_client: %v %v | thread_count:%v | domain_count:%v",
 			nameserver, clientStatus, client, threads, dmnCount),
@@ -59,31 +74,69 @@ func BuildGraph(nameserver, client string, clientStatus bool,
 				Min: 0.0,
 			},
 		},
-		Series: []chart.Series{
-			mainSeries,
-			smaSeries,
-			chart.ContinuousSeries{
-				Style: chart.Style{
-					StrokeColor: chart.GetDefaultColor(0).WithAlpha(64),
-					FillColor:   chart.GetDefaultColor(0).WithAlpha(64),
-				},
-			},
-		},
+		Series: series,
 	}
 
 	graph.Elements = []chart.Renderable{
-		chart.Legend(&graph),
+		chart.Legend(&graph, legendStyle()),
 	}
 
-	newpath := filepath.Join(".", output, nameserver)
-	os.MkdirAll(newpath, os.ModePerm)
+	applyOptions(&graph)
 
-	f, err := os.Create(fmt.Sprintf("%v/%v/ns-%v_client-%v_%4v.png",
-		output, nameserver, nameserver, clientStatus, time.Now().Unix()))
+	f, err := createFile(output, nameserver, clientStatus, "")
 	if err != nil {
 		log.Printf("Error writing to file\n%v", err)
+		return ""
 	}
 
 	defer f.Close()
 	graph.Render(chart.PNG, f)
+	return f.Name()
+}
+
+// createFile opens a new png in the nameserver's output directory, kind
+// distinguishes the graphs produced for the same run
+func createFile(output, nameserver string, clientStatus bool, kind string) (*pngFile, error) {
+	dir := filepath.Join(output, nameserver)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	if kind != "" {
+		kind += "_"
+	}
+	id := fmt.Sprint(time.Now().Unix())
+	if RunID != nil {
+		id = RunID()
+	}
+	f, err := Create(filepath.Join(dir, fmt.Sprintf("ns-%v_client-%v_%v%v.png",
+		nameserver, clientStatus, kind, id)))
+	if err != nil {
+		return nil, err
+	}
+	return &pngFile{f: f}, nil
+}
+
+// throttleSeries labels the start of each rate limiting episode
+func throttleSeries(t, c, rrl []float64) chart.AnnotationSeries {
+	a := chart.AnnotationSeries{
+		Name: "Rate Limited",
+	}
+	for _, x := range rrl {
+		a.Annotations = append(a.Annotations, chart.Value2{
+			XValue: x,
+			YValue: valueAt(t, c, x),
+			Label:  fmt.Sprintf("RRL %.1fs", x),
+		})
+	}
+	return a
+}
+
+func valueAt(t, c []float64, x float64) float64 {
+	for i := range t {
+		if t[i] >= x && i < len(c) {
+			return c[i]
+		}
+	}
+	return 0
 }
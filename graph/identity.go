@@ -0,0 +1,88 @@
+package graph
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/wcharczuk/go-chart"
+)
+
+// BuildIdentityGraph draws the answering server over the run as steps
+// between one row per server, sites[i] answered from t[i] on until end
+func BuildIdentityGraph(nameserver string, clientStatus bool,
+	t []float64, sites []string, end float64, output string) {
+	if len(t) == 0 {
+		return
+	}
+
+	rows := make(map[string]float64)
+	var ticks []chart.Tick
+	var x, y []float64
+	for i, s := range sites {
+		row, ok := rows[s]
+		if !ok {
+			row = float64(len(rows) + 1)
+			rows[s] = row
+			ticks = append(ticks, chart.Tick{Value: row, Label: s})
+		}
+		if i > 0 {
+			x, y = append(x, t[i]), append(y, y[len(y)-1])
+		}
+		x, y = append(x, t[i]), append(y, row)
+	}
+	x, y = append(x, end), append(y, y[len(y)-1])
+	// unlabeled ticks keep the outer rows off the edges
+	ticks = append([]chart.Tick{{Value: 0.5}}, ticks...)
+	ticks = append(ticks, chart.Tick{Value: float64(len(rows)) + 0.5})
+
+	graph := chart.Chart{
+		Title: fmt.Sprintf("ns:%v - answering server", nameserver),
+		TitleStyle: chart.Style{
+			FontSize: 8.0,
+		},
+		Height: 400,
+		Width:  650,
+		Background: chart.Style{
+			Padding: chart.Box{
+				Top:    40,
+				Bottom: 20,
+				Left:   20,
+				Right:  20,
+				IsSet:  true,
+			},
+		},
+		XAxis: chart.XAxis{
+			Name: "Elapsed Time (sec)",
+			Range: &chart.ContinuousRange{
+				Min: 0.0,
+			},
+		},
+		YAxis: chart.YAxis{
+			Ticks: ticks,
+		},
+		Series: []chart.Series{
+			chart.ContinuousSeries{
+				Name: "Server",
+				Style: chart.Style{
+					StrokeColor: chart.ColorBlue,
+					StrokeWidth: 2,
+				},
+				XValues: x,
+				YValues: y,
+			},
+		},
+	}
+
+	applyOptions(&graph)
+
+	f, err := createFile(output, nameserver, clientStatus, "identity")
+	if err != nil {
+		log.Printf("Error writing to file\n%v", err)
+		return
+	}
+
+	defer f.Close()
+	if err := graph.Render(chart.PNG, f); err != nil {
+		log.Printf("Error rendering identity graph\n%v", err)
+	}
+}
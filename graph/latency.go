@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/wcharczuk/go-chart"
+)
+
+// BuildLatencyScatter plots the latency of every answered query against the
+// time its answer arrived, stalls show up as vertical bands
+func BuildLatencyScatter(nameserver string, clientStatus bool,
+	t, latency []float64, output string) {
+	if len(t) < 2 {
+		return
+	}
+
+	max := 1.0
+	for _, l := range latency {
+		if l > max {
+			max = l
+		}
+	}
+
+	graph := chart.Chart{
+		Title: fmt.Sprintf("ns:%v - query latency", nameserver),
+		TitleStyle: chart.Style{
+			FontSize: 8.0,
+		},
+		Height: 400,
+		Width:  650,
+		Background: chart.Style{
+			Padding: chart.Box{
+				Top:    40,
+				Bottom: 20,
+				Left:   20,
+				Right:  20,
+				IsSet:  true,
+			},
+		},
+		XAxis: chart.XAxis{
+			Name: "Elapsed Time (sec)",
+			Range: &chart.ContinuousRange{
+				Min: 0.0,
+			},
+		},
+		YAxis: chart.YAxis{
+			Name: "Latency (ms)",
+			Range: &chart.ContinuousRange{
+				Min: 0.0,
+				Max: max * 1.05,
+			},
+		},
+		Series: []chart.Series{
+			chart.ContinuousSeries{
+				Name: "Latency",
+				Style: chart.Style{
+					StrokeWidth: chart.Disabled,
+					DotWidth:    2,
+					DotColor:    chart.GetDefaultColor(0).WithAlpha(160),
+				},
+				XValues: t,
+				YValues: latency,
+			},
+		},
+	}
+
+	applyOptions(&graph)
+
+	f, err := createFile(output, nameserver, clientStatus, "latency")
+	if err != nil {
+		log.Printf("Error writing to file\n%v", err)
+		return
+	}
+
+	defer f.Close()
+	if err := graph.Render(chart.PNG, f); err != nil {
+		log.Printf("Error rendering latency graph\n%v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package graph
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/wcharczuk/go-chart"
+)
+
+// BuildLossGraph plots the share of attempts that timed out over the run,
+// bursts point at path problems while a constant level suggests a server
+// dropping queries
+func BuildLossGraph(nameserver string, clientStatus bool,
+	t, loss []float64, output string) {
+	if len(t) < 2 {
+		return
+	}
+
+	graph := chart.Chart{
+		Title: fmt.Sprintf("ns:%v - timed out attempts", nameserver),
+		TitleStyle: chart.Style{
+			FontSize: 8.0,
+		},
+		Height: 400,
+		Width:  650,
+		Background: chart.Style{
+			Padding: chart.Box{
+				Top:    40,
+				Bottom: 20,
+				Left:   20,
+				Right:  20,
+				IsSet:  true,
+			},
+		},
+		XAxis: chart.XAxis{
+			Name: "Elapsed Time (sec)",
+			Range: &chart.ContinuousRange{
+				Min: 0.0,
+			},
+		},
+		YAxis: chart.YAxis{
+			Name: "Loss (%)",
+			Range: &chart.ContinuousRange{
+				Min: 0.0,
+				Max: 100.0,
+			},
+		},
+		Series: []chart.Series{
+			chart.ContinuousSeries{
+				Name: "Loss",
+				Style: chart.Style{
+					StrokeColor: chart.ColorRed,
+					FillColor:   chart.ColorRed.WithAlpha(64),
+				},
+				XValues: t,
+				YValues: loss,
+			},
+		},
+	}
+
+	applyOptions(&graph)
+
+	f, err := createFile(output, nameserver, clientStatus, "loss")
+	if err != nil {
+		log.Printf("Error writing to file\n%v", err)
+		return
+	}
+
+	defer f.Close()
+	if err := graph.Render(chart.PNG, f); err != nil {
+		log.Printf("Error rendering loss graph\n%v", err)
+	}
+}
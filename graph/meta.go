@@ -0,0 +1,74 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"sort"
+)
+
+// Metadata returns the text chunks written into every graph, so an image
+// can be traced back to the run that produced it
+var Metadata func() map[string]string
+
+// RunID names the run in the file names of its graphs and Create opens
+// them, an existing graph is only replaced where Create allows it
+var (
+	RunID  func() string
+	Create = os.Create
+)
+
+// pngFile holds the rendered image until Close, when the text chunks are
+// inserted behind the header chunk. The file is not embedded as its
+// WriteString would let the encoder bypass the buffer.
+type pngFile struct {
+	f   *os.File
+	buf bytes.Buffer
+}
+
+func (p *pngFile) Write(b []byte) (int, error) {
+	return p.buf.Write(b)
+}
+
+func (p *pngFile) Name() string {
+	return p.f.Name()
+}
+
+// ihdrEnd is the length of the PNG signature and the IHDR chunk that has
+// to come first
+const ihdrEnd = 8 + 4 + 4 + 13 + 4
+
+func textChunk(key, value string) []byte {
+	data := append(append([]byte(key), 0), value...)
+	c := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(c, uint32(len(data)))
+	copy(c[4:], "tEXt")
+	c = append(c, data...)
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.ChecksumIEEE(c[4:]))
+	return append(c, crc[:]...)
+}
+
+func (p *pngFile) Close() error {
+	img := p.buf.Bytes()
+	if len(img) >= ihdrEnd && Metadata != nil {
+		m := Metadata()
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var chunks []byte
+		for _, k := range keys {
+			chunks = append(chunks, textChunk(k, m[k])...)
+		}
+		img = append(append(append([]byte(nil), img[:ihdrEnd]...), chunks...), img[ihdrEnd:]...)
+	}
+	_, err := p.f.Write(img)
+	if cerr := p.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package graph
+
+import (
+	"strings"
+
+	"github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// Options adjust every rendered graph for reports and slides. A title or
+// axis label replaces the generated one, which can be kept in the new
+// text as {title} or {label}. Zero sizes keep the default of 650x400.
+type Options struct {
+	Title  string
+	XLabel string
+	YLabel string
+	Width  int
+	Height int
+	Dark   bool
+}
+
+// Settings are applied to the graphs as they are rendered
+var Settings Options
+
+var (
+	darkBackground = drawing.ColorFromHex("1e1e1e")
+	darkForeground = drawing.ColorFromHex("d4d4d4")
+)
+
+func replaceText(custom, placeholder, generated string) string {
+	if custom == "" {
+		return generated
+	}
+	return strings.Replace(custom, placeholder, generated, -1)
+}
+
+func themeStyle(s chart.Style) chart.Style {
+	if !Settings.Dark {
+		return s
+	}
+	s.FontColor = darkForeground
+	s.StrokeColor = darkForeground
+	return s
+}
+
+func themeFill(s chart.Style) chart.Style {
+	if Settings.Dark {
+		s.FillColor = darkBackground
+	}
+	return s
+}
+
+// legendStyle is passed to the legends so they follow the theme
+func legendStyle() chart.Style {
+	if !Settings.Dark {
+		return chart.Style{}
+	}
+	return chart.Style{FillColor: darkBackground, FontColor: darkForeground, StrokeColor: darkForeground}
+}
+
+func applyOptions(c *chart.Chart) {
+	c.Title = replaceText(Settings.Title, "{title}", c.Title)
+	c.XAxis.Name = replaceText(Settings.XLabel, "{label}", c.XAxis.Name)
+	c.YAxis.Name = replaceText(Settings.YLabel, "{label}", c.YAxis.Name)
+	if Settings.Width > 0 {
+		c.Width = Settings.Width
+	}
+	if Settings.Height > 0 {
+		c.Height = Settings.Height
+	}
+	c.Background = themeFill(c.Background)
+	c.Canvas = themeFill(c.Canvas)
+	c.TitleStyle = themeStyle(c.TitleStyle)
+	c.XAxis.Style = themeStyle(c.XAxis.Style)
+	c.XAxis.NameStyle = themeStyle(c.XAxis.NameStyle)
+	c.YAxis.Style = themeStyle(c.YAxis.Style)
+	c.YAxis.NameStyle = themeStyle(c.YAxis.NameStyle)
+}
+
+func applyBarOptions(c *chart.BarChart) {
+	c.Title = replaceText(Settings.Title, "{title}", c.Title)
+	c.YAxis.Name = replaceText(Settings.YLabel, "{label}", c.YAxis.Name)
+	if Settings.Width > 0 {
+		c.Width = Settings.Width
+	}
+	if Settings.Height > 0 {
+		c.Height = Settings.Height
+	}
+	c.Background = themeFill(c.Background)
+	c.Canvas = themeFill(c.Canvas)
+	c.TitleStyle = themeStyle(c.TitleStyle)
+	c.XAxis = themeStyle(c.XAxis)
+	c.YAxis.Style = themeStyle(c.YAxis.Style)
+	c.YAxis.NameStyle = themeStyle(c.YAxis.NameStyle)
+}
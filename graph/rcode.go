@@ -0,0 +1,143 @@
+package graph
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/wcharczuk/go-chart"
+)
+
+// BuildRcodeGraph renders a bar chart of response counts per RCODE
+func BuildRcodeGraph(nameserver string, clientStatus bool,
+	labels []string, counts []int, output string) {
+	buildBars(nameserver, clientStatus, "response codes", "rcodes", labels, counts, output)
+}
+
+// buildBars renders a bar chart of response counts under kind
+func buildBars(nameserver string, clientStatus bool, title, kind string,
+	labels []string, counts []int, output string) {
+	if len(labels) == 0 {
+		return
+	}
+
+	max := 1.0
+	bars := make([]chart.Value, 0, len(labels))
+	for i, l := range labels {
+		if float64(counts[i]) > max {
+			max = float64(counts[i])
+		}
+		bars = append(bars, chart.Value{
+			Label: fmt.Sprintf("%v (%v)", l, counts[i]),
+			Value: float64(counts[i]),
+		})
+	}
+
+	graph := chart.BarChart{
+		Title: fmt.Sprintf("ns:%v - %v", nameserver, title),
+		TitleStyle: chart.Style{
+			FontSize: 8.0,
+		},
+		Height:   400,
+		Width:    650,
+		BarWidth: 60,
+		Background: chart.Style{
+			Padding: chart.Box{
+				Top:    40,
+				Bottom: 40,
+				Left:   20,
+				Right:  20,
+				IsSet:  true,
+			},
+		},
+		YAxis: chart.YAxis{
+			Name: "Responses",
+			Range: &chart.ContinuousRange{
+				Min: 0.0,
+				Max: max,
+			},
+		},
+		Bars: bars,
+	}
+
+	applyBarOptions(&graph)
+
+	f, err := createFile(output, nameserver, clientStatus, kind)
+	if err != nil {
+		log.Printf("Error writing to file\n%v", err)
+		return
+	}
+
+	defer f.Close()
+	if err := graph.Render(chart.PNG, f); err != nil {
+		log.Printf("Error rendering %v graph\n%v", kind, err)
+	}
+}
+
+// BuildRcodeSeries plots the rate of every response code, and of timeouts,
+// over the course of the run
+func BuildRcodeSeries(nameserver string, clientStatus bool,
+	t []float64, labels []string, rates [][]float64, output string) {
+	if len(t) < 2 {
+		return
+	}
+
+	series := make([]chart.Series, 0, len(labels))
+	for i, l := range labels {
+		series = append(series, chart.ContinuousSeries{
+			Name: l,
+			Style: chart.Style{
+				StrokeColor: chart.GetDefaultColor(i),
+				StrokeWidth: 1.5,
+			},
+			XValues: t,
+			YValues: rates[i],
+		})
+	}
+
+	graph := chart.Chart{
+		Title: fmt.Sprintf("ns:%v - response codes over time", nameserver),
+		TitleStyle: chart.Style{
+			FontSize: 8.0,
+		},
+		Height: 400,
+		Width:  650,
+		Background: chart.Style{
+			Padding: chart.Box{
+				Top:    40,
+				Bottom: 20,
+				Left:   20,
+				Right:  20,
+				IsSet:  true,
+			},
+		},
+		XAxis: chart.XAxis{
+			Name: "Elapsed Time (sec)",
+			Range: &chart.ContinuousRange{
+				Min: 0.0,
+			},
+		},
+		YAxis: chart.YAxis{
+			Name: "Responses/s",
+			Range: &chart.ContinuousRange{
+				Min: 0.0,
+			},
+		},
+		Series: series,
+	}
+	graph.Elements = []chart.Renderable{
+		chart.LegendLeft(&graph, legendStyle()),
+	}
+
+	applyOptions(&graph)
+
+	f, err := createFile(output, nameserver, clientStatus, "rcodes_time")
+	if err != nil {
+		log.Printf("Error writing to file\n%v", err)
+		return
+	}
+
+	defer f.Close()
+	if err := graph.Render(chart.PNG, f); err != nil {
+		log.Printf("Error rendering rcode series\n%v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package graph
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/wcharczuk/go-chart"
+)
+
+// BuildRunsGraph renders the query rate of several runs over their elapsed
+// time, one line per run so a longitudinal view builds up as runs are
+// appended
+func BuildRunsGraph(nameserver string, labels []string, t, c [][]float64, output string) {
+	var series []chart.Series
+	for i := range t {
+		if len(t[i]) < 2 {
+			continue
+		}
+		series = append(series, chart.ContinuousSeries{
+			Name: labels[i],
+			Style: chart.Style{
+				StrokeColor: chart.GetDefaultColor(len(series)),
+				StrokeWidth: 1.5,
+			},
+			XValues: t[i],
+			YValues: c[i],
+		})
+	}
+	if len(series) == 0 {
+		return
+	}
+
+	graph := chart.Chart{
+		Title: fmt.Sprintf("ns:%v - rate by run", nameserver),
+		TitleStyle: chart.Style{
+			FontSize: 8.0,
+		},
+		Height: 400,
+		Width:  650,
+		Background: chart.Style{
+			Padding: chart.Box{
+				Top:    40,
+				Bottom: 20,
+				Left:   20,
+				Right:  20,
+				IsSet:  true,
+			},
+		},
+		XAxis: chart.XAxis{
+			Name: "Elapsed Time (sec)",
+			Range: &chart.ContinuousRange{
+				Min: 0.0,
+			},
+		},
+		YAxis: chart.YAxis{
+			Name: "Successful Queries/s",
+			Range: &chart.ContinuousRange{
+				Min: 0.0,
+			},
+		},
+		Series: series,
+	}
+	graph.Elements = []chart.Renderable{
+		chart.LegendLeft(&graph, legendStyle()),
+	}
+
+	applyOptions(&graph)
+
+	f, err := createFile(output, nameserver, false, "runs")
+	if err != nil {
+		log.Printf("Error writing to file\n%v", err)
+		return
+	}
+
+	defer f.Close()
+	if err := graph.Render(chart.PNG, f); err != nil {
+		log.Printf("Error rendering runs graph\n%v", err)
+	}
+}
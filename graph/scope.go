@@ -0,0 +1,8 @@
+package graph
+
+// BuildScopeGraph renders a bar chart of responses per ECS scope prefix
+// length
+func BuildScopeGraph(nameserver string, clientStatus bool,
+	labels []string, counts []int, output string) {
+	buildBars(nameserver, clientStatus, "ECS scope prefix lengths", "scopes", labels, counts, output)
+}
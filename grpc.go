@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	grpcAddr = flag.String("grpc", "", "Serve the gRPC API of the monitor command on this address")
+)
+
+// benchmark.pb.go is generated with the protoc-gen-go of
+// github.com/golang/protobuf, its grpc plugin targets the grpc version the
+// module requires
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. benchmark.proto
+
+func grpcStatus() *RunStatus {
+	s := currentStatus()
+	r := &RunStatus{
+		Running:     s.Running,
+		Attempts:    int32(s.Attempts),
+		Success:     int32(s.Success),
+		Fail:        int32(s.Fail),
+		Runs:        int32(s.Runs),
+		NextRunUnix: s.NextRun.Unix(),
+	}
+	if s.Started != nil {
+		r.StartedUnix = s.Started.Unix()
+	}
+	return r
+}
+
+// benchmarkServer implements the service of benchmark.proto on top of the
+// monitor, control errors are reported as a failed precondition
+type benchmarkServer struct{}
+
+func control(f func() error) (*RunStatus, error) {
+	if err := f(); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return grpcStatus(), nil
+}
+
+func (benchmarkServer) Status(context.Context, *Empty) (*RunStatus, error) {
+	return grpcStatus(), nil
+}
+
+func (benchmarkServer) StartRun(context.Context, *Empty) (*RunStatus, error) {
+	return control(startRun)
+}
+
+func (benchmarkServer) StopRun(context.Context, *Empty) (*RunStatus, error) {
+	return control(stopRun)
+}
+
+func (benchmarkServer) Results(_ *Empty, stream Benchmark_ResultsServer) error {
+	ch := subscribeResults()
+	defer unsubscribeResults(ch)
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case r := <-ch:
+			err := stream.Send(&QueryResult{
+				Domain:       r.Domain,
+				Rcode:        r.Rcode,
+				Ips:          r.IPs,
+				Tries:        int32(r.Tries),
+				TimeUnixNano: r.Time.UnixNano(),
+				LatencyMs:    r.Latency,
+				Server:       r.Server,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func serveGRPC(addr string) {
+	l, err := net.Listen("tcp", addr)
+	if err == nil {
+		s := grpc.NewServer()
+		RegisterBenchmarkServer(s, benchmarkServer{})
+		err = s.Serve(l)
+	}
+	fmt.Fprintf(os.Stderr, "grpc: %s\n", err)
+	os.Exit(1)
+}
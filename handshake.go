@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// setupTimes are the connection establishment phases of the stream and
+// DoH transports, kept apart from the query latencies since how well a
+// transport amortizes them decides most encrypted DNS comparisons
+var setupTimes struct {
+	sync.Mutex
+	connect   []time.Duration
+	handshake []time.Duration
+	resumed   map[string][]bool
+	byResume  [2][]time.Duration
+}
+
+// tlsSessions is shared by every TLS connection of a run so reconnects can
+// resume with a ticket of an earlier one, each run starts without any
+var tlsSessions tls.ClientSessionCache
+
+func recordConnect(d time.Duration) {
+	setupTimes.Lock()
+	setupTimes.connect = append(setupTimes.connect, d)
+	setupTimes.Unlock()
+}
+
+// recordHandshake notes a completed handshake with endpoint and whether it
+// resumed an earlier session
+func recordHandshake(endpoint string, d time.Duration, resumed bool) {
+	setupTimes.Lock()
+	defer setupTimes.Unlock()
+	setupTimes.handshake = append(setupTimes.handshake, d)
+	if setupTimes.resumed == nil {
+		setupTimes.resumed = make(map[string][]bool)
+	}
+	setupTimes.resumed[endpoint] = append(setupTimes.resumed[endpoint], resumed)
+	i := 0
+	if resumed {
+		i = 1
+	}
+	setupTimes.byResume[i] = append(setupTimes.byResume[i], d)
+}
+
+func resetSetup() {
+	setupTimes.Lock()
+	setupTimes.connect, setupTimes.handshake = nil, nil
+	setupTimes.resumed = make(map[string][]bool)
+	setupTimes.byResume = [2][]time.Duration{}
+	setupTimes.Unlock()
+	tlsSessions = tls.NewLRUClientSessionCache(256)
+}
+
+func meanDuration(s []time.Duration) time.Duration {
+	if len(s) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, d := range s {
+		sum += d
+	}
+	return sum / time.Duration(len(s))
+}
+
+// setupPerQuery is the setup time of every connection spread over the
+// answers of the run
+func setupPerQuery() time.Duration {
+	setupTimes.Lock()
+	defer setupTimes.Unlock()
+	if len(latencies) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range append(setupTimes.connect, setupTimes.handshake...) {
+		total += d
+	}
+	return total / time.Duration(len(latencies))
+}
+
+// setupStats prints the setup phases and what they add per answered query
+// when spread over the run. QUIC connections have no connect phase apart
+// from their handshake.
+func setupStats() {
+	setupTimes.Lock()
+	defer setupTimes.Unlock()
+	conns := len(setupTimes.connect)
+	if conns == 0 {
+		conns = len(setupTimes.handshake)
+	}
+	if conns == 0 {
+		return
+	}
+	var total time.Duration
+	for _, d := range append(setupTimes.connect, setupTimes.handshake...) {
+		total += d
+	}
+	fmt.Printf("[+] Connections:      %v\n", conns)
+	if len(setupTimes.connect) > 0 {
+		fmt.Printf("[+]   %-16smean %v / p95 %v\n", "Connect:",
+			formatMs(meanDuration(setupTimes.connect)), formatMs(percentileOf(setupTimes.connect, 95)))
+	}
+	if len(setupTimes.handshake) > 0 {
+		fmt.Printf("[+]   %-16smean %v / p95 %v\n", "TLS Handshake:",
+			formatMs(meanDuration(setupTimes.handshake)), formatMs(percentileOf(setupTimes.handshake, 95)))
+	}
+	if len(latencies) > 0 {
+		fmt.Printf("[+]   %-16s%v\n", "Per Query:", formatMs(total/time.Duration(len(latencies))))
+	}
+	resumptionStats()
+}
+
+// resumptionStats prints the share of handshakes per endpoint that resumed
+// and how much faster they were than full ones
+func resumptionStats() {
+	if len(setupTimes.handshake) == 0 {
+		return
+	}
+	full, resumed := setupTimes.byResume[0], setupTimes.byResume[1]
+	fmt.Printf("[+] TLS Resumption:   %v of %v", len(resumed), len(setupTimes.handshake))
+	if len(resumed) > 0 && len(full) > 0 {
+		fmt.Printf(" (resumed %v / full %v)", formatMs(meanDuration(resumed)), formatMs(meanDuration(full)))
+	}
+	fmt.Println()
+
+	var endpoints []string
+	for e := range setupTimes.resumed {
+		endpoints = append(endpoints, e)
+	}
+	if len(endpoints) < 2 {
+		return
+	}
+	sort.Strings(endpoints)
+	for _, e := range endpoints {
+		n := 0
+		for _, r := range setupTimes.resumed[e] {
+			if r {
+				n++
+			}
+		}
+		fmt.Printf("[+]   %-24s%v of %v\n", e, n, len(setupTimes.resumed[e]))
+	}
+}
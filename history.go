@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// historyFile is kept in the output directory and holds one summary per
+// line for every run made by the monitor
+const historyFile = "history.jsonl"
+
+type runSummary struct {
+	Time       time.Time        `json:"time"`
+	Nameserver string           `json:"nameserver"`
+	Client     string           `json:"client,omitempty"`
+	Completed  bool             `json:"completed"`
+	Attempts   int              `json:"attempts"`
+	Success    int              `json:"success"`
+	Fail       int              `json:"fail"`
+	Truncated  int              `json:"truncated"`
+	AvgTries   float64          `json:"avg_retry_count"`
+	AvgRate    float64          `json:"avg_rate"`
+	Elapsed    float64          `json:"elapsed_seconds"`
+	LatencyP95 float64          `json:"latency_p95_ms"`
+	Jitter     float64          `json:"jitter_ms"`
+	Rcodes     map[string]int   `json:"rcodes"`
+	Errors     map[string]int64 `json:"errors"`
+	Graph      string           `json:"graph,omitempty"`
+	Meta       *runMeta         `json:"meta,omitempty"`
+}
+
+// summarize captures the statistics of the run that just finished
+func summarize(completed bool) runSummary {
+	s := runSummary{
+		Time:       t0,
+		Nameserver: *nameserver,
+		Client:     *client,
+		Completed:  completed,
+		Attempts:   stats.attempts,
+		Success:    stats.success,
+		Fail:       stats.fail,
+		Truncated:  stats.truncated,
+		AvgTries:   avgTries,
+		Elapsed:    td.Seconds(),
+		Rcodes:     make(map[string]int),
+		Errors:     make(map[string]int64),
+		Graph:      graphPath,
+		Meta:       currentMeta(),
+		LatencyP95: durationMs(latencyPercentile(95)),
+		Jitter:     durationMs(latencyJitter()),
+	}
+	if td > 0 {
+		s.AvgRate = float64(stats.success) / td.Seconds()
+	}
+	for rc, n := range stats.rcodes {
+		s.Rcodes[rcodeName(rc)] = n
+	}
+	for c := errorClass(0); c < numErrorClasses; c++ {
+		s.Errors[c.String()] = errorCount(c)
+	}
+	return s
+}
+
+func historyPath() string {
+	return filepath.Join(*outputDir, historyFile)
+}
+
+func appendHistory(s runSummary) error {
+	os.MkdirAll(*outputDir, os.ModePerm)
+	f, err := os.OpenFile(historyPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// loadHistory returns every summary recorded so far, oldest first
+func loadHistory() ([]runSummary, error) {
+	f, err := os.Open(historyPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var h []runSummary
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s runSummary
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			continue
+		}
+		h = append(h, s)
+	}
+	return h, scanner.Err()
+}
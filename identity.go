@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/rtmoranorg/dns-client-subnet-ext/graph"
+)
+
+var (
+	identityEvery = flag.Duration("identity", 0, "Probe CH hostname.bind at this interval during the run, responses without NSID are attributed to the latest identity")
+)
+
+// identityNames are asked in turn, servers not knowing the first often
+// answer the second
+var identityNames = []string{"hostname.bind.", "id.server."}
+
+var (
+	identityMu sync.Mutex
+	// probedIdentity is the latest answer of the interleaved probes
+	probedIdentity string
+	// identityTimes and identitySites hold the run time of every change of
+	// the answering server and the server from then on
+	identityTimes []float64
+	identitySites []string
+	identityCount map[string]int
+)
+
+func trackIdentity() bool {
+	return *nsidOn || *identityEvery > 0
+}
+
+// probeIdentity asks for the identity of the server until the run stops
+func probeIdentity(stop <-chan struct{}) {
+	if *identityEvery <= 0 {
+		return
+	}
+	t := time.NewTicker(*identityEvery)
+	defer t.Stop()
+	for {
+		id := chaosIdentity()
+		identityMu.Lock()
+		probedIdentity = id
+		identityMu.Unlock()
+
+		select {
+		case <-t.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func chaosIdentity() string {
+	for _, n := range identityNames {
+		r, _, err := exchange(n, dns.TypeTXT, dns.ClassCHAOS)
+		if err != nil {
+			continue
+		}
+		for _, a := range r.Answer {
+			if t, ok := a.(*dns.TXT); ok {
+				return strings.Join(t.Txt, " ")
+			}
+		}
+	}
+	return ""
+}
+
+// recordIdentity attributes the response to the NSID it carries or to the
+// latest probed identity and returns it
+func recordIdentity(msg *dns.Msg) string {
+	if !trackIdentity() {
+		return ""
+	}
+	id := responseNSID(msg)
+	if id == "" {
+		identityMu.Lock()
+		id = probedIdentity
+		identityMu.Unlock()
+	}
+	if id == "" {
+		id = "unknown"
+	}
+
+	identityCount[id]++
+	if n := len(identitySites); n == 0 || identitySites[n-1] != id {
+		identityTimes = append(identityTimes, getRunTime())
+		identitySites = append(identitySites, id)
+	}
+	return id
+}
+
+func resetIdentity() {
+	identityMu.Lock()
+	probedIdentity = ""
+	identityMu.Unlock()
+	identityTimes, identitySites = nil, nil
+	identityCount = make(map[string]int)
+}
+
+// identityStats lists every change of the answering server, a nameserver
+// flapping between anycast sites changes many times during a run
+func identityStats() {
+	if len(identitySites) == 0 {
+		return
+	}
+	changes := len(identitySites) - 1
+	fmt.Printf("[+] Server Identity:  %v (%v changes)\n", siteCounts(identityCount), changes)
+	for i := 1; i < len(identitySites); i++ {
+		if i > *topN {
+			fmt.Printf("[+]   ... %v more\n", changes-*topN)
+			break
+		}
+		fmt.Printf("[+]   %-16s%v -> %v\n", fmt.Sprintf("%.2fs:", identityTimes[i]),
+			identitySites[i-1], identitySites[i])
+	}
+
+	graph.BuildIdentityGraph(*nameserver, len(*client) != 0, identityTimes, identitySites,
+		getRunTime(), *outputDir)
+}
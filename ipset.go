@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"sort"
+)
+
+var (
+	uniqueIPs = flag.Bool("unique-ips", false, "Write the set of all A/AAAA addresses seen to the output directory")
+)
+
+var answerIPs = make(map[string]net.IP)
+
+func recordIPs(ips []net.IP) {
+	for _, ip := range ips {
+		answerIPs[ip.String()] = ip
+	}
+}
+
+// sortedIPs orders addresses numerically with IPv4 before IPv6
+func sortedIPs() []net.IP {
+	ips := make([]net.IP, 0, len(answerIPs))
+	for _, ip := range answerIPs {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		a, b := ips[i].To4(), ips[j].To4()
+		if (a == nil) != (b == nil) {
+			return a != nil
+		}
+		if a == nil {
+			a, b = ips[i].To16(), ips[j].To16()
+		}
+		return bytes.Compare(a, b) < 0
+	})
+	return ips
+}
+
+func writeUniqueIPs() {
+	if !*uniqueIPs {
+		return
+	}
+	fmt.Printf("[+] Unique IPs:       %v\n", len(answerIPs))
+
+	f, err := createOutputFile("ips", "txt")
+	if err != nil {
+		fmt.Printf("Error writing IP file\n%v\n", err)
+		return
+	}
+	defer f.Close()
+
+	for _, ip := range sortedIPs() {
+		if n, ok := reverseNames[ip.String()]; ok {
+			fmt.Fprintf(f, "%v\t%v\n", ip, n)
+			continue
+		}
+		fmt.Fprintln(f, ip)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// iterateMaxDepth bounds referrals followed for a single name
+	iterateMaxDepth = 16
+	// iterateMaxCNAME bounds CNAME restarts for a single name
+	iterateMaxCNAME = 8
+)
+
+// rootHints are the IPv4 addresses of the root server letters
+var rootHints = []authServer{
+	{"a.root-servers.net.", "198.41.0.4"},
+	{"b.root-servers.net.", "170.247.170.2"},
+	{"c.root-servers.net.", "192.33.4.12"},
+	{"d.root-servers.net.", "199.7.91.13"},
+	{"e.root-servers.net.", "192.203.230.10"},
+	{"f.root-servers.net.", "192.5.5.241"},
+	{"g.root-servers.net.", "192.112.36.4"},
+	{"h.root-servers.net.", "198.97.190.53"},
+	{"i.root-servers.net.", "192.36.148.17"},
+	{"j.root-servers.net.", "192.58.128.30"},
+	{"k.root-servers.net.", "193.0.14.129"},
+	{"l.root-servers.net.", "199.7.83.42"},
+	{"m.root-servers.net.", "202.12.27.33"},
+}
+
+// delegationStep describes a single response received while iterating
+type delegationStep struct {
+	zone   string
+	name   string
+	server authServer
+	rtt    time.Duration
+	msg    *dns.Msg
+	err    error
+	// referral holds the child zone when the response delegated further
+	referral string
+	nsCount  int
+}
+
+type stepFunc func(delegationStep)
+
+// iterator resolves names from the root. step, when set, is called for
+// every response or failed server along the way. A non-nil cache keeps
+// the servers of every delegation seen so later names skip the upper
+// levels, the way a recursive resolver would.
+type iterator struct {
+	step  stepFunc
+	mu    sync.Mutex
+	cache map[string][]authServer
+}
+
+func newCachingIterator() *iterator {
+	return &iterator{cache: make(map[string][]authServer)}
+}
+
+// closestZone returns the deepest cached zone enclosing name
+func (it *iterator) closestZone(name string) (string, []authServer) {
+	if it.cache != nil {
+		it.mu.Lock()
+		defer it.mu.Unlock()
+		for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+			if s, ok := it.cache[strings.ToLower(name[off:])]; ok {
+				return strings.ToLower(name[off:]), s
+			}
+		}
+	}
+	return ".", rootHints
+}
+
+func (it *iterator) remember(zone string, servers []authServer) {
+	if it.cache == nil {
+		return
+	}
+	it.mu.Lock()
+	it.cache[zone] = servers
+	it.mu.Unlock()
+}
+
+func shuffled(servers []authServer) []authServer {
+	s := make([]authServer, len(servers))
+	for i, j := range rand.Perm(len(servers)) {
+		s[i] = servers[j]
+	}
+	return s
+}
+
+// resolve resolves name without any recursive server, following
+// referrals and CNAMEs. Every server along the way is sent subnet as the
+// client subnet, none when it is empty.
+func (it *iterator) resolve(name string, qtype uint16, subnet string) (*dns.Msg, error) {
+	name = dns.Fqdn(name)
+	for restarts := 0; restarts <= iterateMaxCNAME; restarts++ {
+		r, err := it.resolveName(name, qtype, subnet, it.step, 0)
+		if err != nil || r.Rcode != dns.RcodeSuccess || qtype == dns.TypeCNAME {
+			return r, err
+		}
+
+		chain := followCNAME(name, r.Answer)
+		if len(chain) == 0 || hasType(r.Answer, chain[len(chain)-1], qtype) {
+			return r, nil
+		}
+		name = chain[len(chain)-1]
+	}
+	return nil, fmt.Errorf("too many CNAMEs resolving %v", name)
+}
+
+func hasType(answer []dns.RR, name string, qtype uint16) bool {
+	for _, a := range answer {
+		if a.Header().Rrtype == qtype && strings.EqualFold(a.Header().Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (it *iterator) resolveName(name string, qtype uint16, subnet string, step stepFunc, depth int) (*dns.Msg, error) {
+	zone, servers := it.closestZone(name)
+	servers = shuffled(servers)
+
+	for i := 0; i < iterateMaxDepth; i++ {
+		r, err := queryZone(zone, name, qtype, subnet, servers, step)
+		if err != nil {
+			return nil, err
+		}
+
+		child, nsNames := referral(r, zone)
+		if len(r.Answer) > 0 || r.Rcode != dns.RcodeSuccess || child == "" {
+			return r, nil
+		}
+
+		next := glue(r, nsNames)
+		if len(next) == 0 && depth < iterateMaxDepth {
+			for _, ns := range nsNames {
+				a, err := it.resolveName(ns, dns.TypeA, subnet, nil, depth+1)
+				if err != nil {
+					continue
+				}
+				for _, rr := range a.Answer {
+					if t, ok := rr.(*dns.A); ok {
+						next = append(next, authServer{ns, t.A.String()})
+					}
+				}
+				if len(next) > 0 {
+					break
+				}
+			}
+		}
+		if len(next) == 0 {
+			return nil, fmt.Errorf("no reachable nameserver for %v", child)
+		}
+
+		it.remember(child, next)
+		zone = child
+		servers = shuffled(next)
+	}
+	return nil, fmt.Errorf("delegation too deep resolving %v", name)
+}
+
+// queryZone asks the servers of zone in turn until one of them answers
+func queryZone(zone, name string, qtype uint16, subnet string, servers []authServer, step stepFunc) (*dns.Msg, error) {
+	for _, s := range servers {
+		m := new(dns.Msg)
+		m.SetQuestion(name, qtype)
+		m.RecursionDesired = false
+		if subnet != "" {
+			o := setupOptions(subnet)
+			o.SetUDPSize(1232)
+			m.Extra = append(m.Extra, o)
+		} else {
+			m.SetEdns0(1232, false)
+		}
+
+		start := time.Now()
+		r, _, err := exchangeAt(m, net.JoinHostPort(s.addr, "53"))
+		st := delegationStep{zone: zone, name: name, server: s, rtt: time.Since(start), msg: r, err: err}
+		if err == nil {
+			st.referral, _ = referral(r, zone)
+			st.nsCount = len(nsRecords(r, st.referral))
+		}
+		if step != nil {
+			step(st)
+		}
+		if err == nil {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("all %d nameservers of %v failed", len(servers), zone)
+}
+
+func nsRecords(r *dns.Msg, zone string) []*dns.NS {
+	var ns []*dns.NS
+	for _, a := range r.Ns {
+		if t, ok := a.(*dns.NS); ok && strings.EqualFold(t.Hdr.Name, zone) {
+			ns = append(ns, t)
+		}
+	}
+	return ns
+}
+
+// referral returns the child zone and its nameserver names when r
+// delegates to a zone below parent
+func referral(r *dns.Msg, parent string) (string, []string) {
+	for _, a := range r.Ns {
+		t, ok := a.(*dns.NS)
+		if !ok {
+			continue
+		}
+		child := strings.ToLower(t.Hdr.Name)
+		if child == parent || !dns.IsSubDomain(parent, child) {
+			continue
+		}
+
+		var names []string
+		for _, ns := range nsRecords(r, t.Hdr.Name) {
+			names = append(names, strings.ToLower(ns.Ns))
+		}
+		return child, names
+	}
+	return "", nil
+}
+
+// glue collects the addresses from the additional section for nsNames
+func glue(r *dns.Msg, nsNames []string) []authServer {
+	var servers []authServer
+	for _, n := range nsNames {
+		for _, a := range r.Extra {
+			if !strings.EqualFold(a.Header().Name, n) {
+				continue
+			}
+			if t, ok := a.(*dns.A); ok {
+				servers = append(servers, authServer{n, t.A.String()})
+			}
+		}
+	}
+	return servers
+}
+
+func printStep(s delegationStep) {
+	checkLame(s.zone, s.server, s.msg, s.err)
+	if s.err != nil {
+		fmt.Printf("[-] %-20v %-45v %v\n", s.zone, s.server, s.err)
+		return
+	}
+
+	var result string
+	switch {
+	case s.referral != "":
+		result = fmt.Sprintf("referral %v (%d NS)", s.referral, s.nsCount)
+	case len(s.msg.Answer) > 0:
+		result = fmt.Sprintf("answer (%d RRs)", len(s.msg.Answer))
+	default:
+		result = rcodeName(s.msg.Rcode)
+	}
+	fmt.Printf("[+] %-20v %-45v %8.2fms  %v\n", s.zone, s.server,
+		float64(s.rtt)/float64(time.Millisecond), result)
+}
+
+func runTrace(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected a single name")
+	}
+
+	fmt.Printf("Iterative Trace\n"+
+		"[+] Name:             %v\n\n", dns.Fqdn(args[0]))
+
+	start := time.Now()
+	it := &iterator{step: printStep}
+	r, err := it.resolve(args[0], dns.TypeA, *client)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	for _, a := range r.Answer {
+		fmt.Println(a)
+	}
+	fmt.Printf("\n[+] Rcode:            %v\n"+
+		"[+] Total Time:       %v\n", rcodeName(r.Rcode), time.Since(start))
+	lameReport()
+	return nil
+}
+
+// iterateRequests resolves the queued records itself instead of sending
+// them to a nameserver, answers are fed back as if read from the socket
+func iterateRequests(tryResolving <-chan *domainRecord, resolved chan<- *domainAnswer,
+	stop <-chan struct{}) {
+	it := newCachingIterator()
+	for dr := range tryResolving {
+		atomic.StoreInt64(&dr.sent, time.Now().UnixNano())
+		go func(id uint16, domain string, qtype uint16, subnet string) {
+			r, err := it.resolve(domain, qtype, subnet)
+			if err != nil {
+				countError(errNetwork)
+				if *verbose {
+					fmt.Fprintf(os.Stderr, "0x%04x iterate %s: %s\n", id, domain, err)
+				}
+				return
+			}
+			da := newAnswer(r)
+			da.id = id
+			da.domain = domain
+			select {
+			case resolved <- da:
+			case <-stop:
+			}
+		}(dr.id, dr.domain, dr.qtype, dr.subnet)
+		atomic.AddInt64(&stats.sent, 1)
+		time.Sleep(sendingDelay)
+	}
+}
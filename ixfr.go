@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ixfrKind tells how a server answered an IXFR (RFC 1995 section 4): a
+// lone SOA when the client is current, difference sequences bracketed by
+// SOAs, or the whole zone as if AXFR had been asked for. The transfer runs
+// over TCP already, so a lone SOA of another serial is not the hint to
+// retry over TCP but a server behind the client or one that keeps no
+// history.
+func ixfrKind(records []dns.RR, serial uint32) (string, int) {
+	if len(records) == 0 {
+		return "empty", 0
+	}
+	first, ok := records[0].(*dns.SOA)
+	if !ok {
+		return "malformed", 0
+	}
+	if len(records) == 1 {
+		switch d := int32(first.Serial - serial); {
+		case d == 0:
+			return "up to date", 0
+		case d < 0:
+			// serials compare in sequence space arithmetic (RFC 1982)
+			return "server serial older than requested", 0
+		}
+		return "not incremental", 0
+	}
+	if _, ok := records[1].(*dns.SOA); !ok {
+		return "full zone", 0
+	}
+
+	// every change set starts with the SOA of the old and of the new
+	// version, the final SOA closes the transfer
+	soas := 0
+	for _, rr := range records[1 : len(records)-1] {
+		if _, ok := rr.(*dns.SOA); ok {
+			soas++
+		}
+	}
+	return "incremental", soas / 2
+}
+
+// runIXFR asks each server for the changes to zone since serial
+func runIXFR(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("expected a zone, a serial and optionally the servers to ask")
+	}
+	zone := dns.Fqdn(strings.ToLower(args[0]))
+	serial, err := strconv.ParseUint(args[1], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid serial %v", args[1])
+	}
+	servers := args[2:]
+	if len(servers) == 0 {
+		servers = []string{*nameserver}
+	}
+
+	fmt.Printf("Incremental Transfer\n"+
+		"[+] Zone:             %v\n"+
+		"[+] Serial:           %v\n"+
+		"[+] TSIG:             %v\n\n", zone, serial, tsig != nil)
+
+	incremental := 0
+	for _, s := range servers {
+		m := new(dns.Msg)
+		m.SetIxfr(zone, uint32(serial), ".", ".")
+		res, err := transfer(m, net.JoinHostPort(s, "53"))
+		if err != nil {
+			fmt.Printf("[-] %-40v %v\n", s, err)
+			continue
+		}
+
+		kind, changes := ixfrKind(res.records, uint32(serial))
+		if kind == "incremental" {
+			incremental++
+			kind = fmt.Sprintf("incremental (%v change sets)", changes)
+		}
+		var current uint32
+		if soa, ok := res.records[0].(*dns.SOA); ok {
+			current = soa.Serial
+		}
+		fmt.Printf("[+] %-40v %v, serial %v, %v records, %v bytes in %v\n", s, kind, current,
+			len(res.records), res.bytes, res.elapsed.Round(time.Millisecond))
+	}
+	fmt.Printf("\n[+] Incremental:      %v of %v servers\n", incremental, len(servers))
+	return nil
+}
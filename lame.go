@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+type lameServer struct {
+	zone   string
+	server authServer
+	reason string
+}
+
+var (
+	lameServers []lameServer
+	lameSeen    = make(map[string]bool)
+)
+
+// checkLame records server as lame for zone when it did not respond or
+// answered without authority, referrals to a child zone are legitimate
+// non-authoritative answers
+func checkLame(zone string, s authServer, r *dns.Msg, err error) {
+	var reason string
+	switch {
+	case err != nil:
+		reason = "unresponsive"
+	case r.Rcode == dns.RcodeRefused || r.Rcode == dns.RcodeServerFailure:
+		reason = rcodeName(r.Rcode)
+	case !r.Authoritative:
+		if child, _ := referral(r, zone); child != "" {
+			return
+		}
+		reason = "not authoritative"
+	default:
+		return
+	}
+
+	k := zone + " " + s.String()
+	if lameSeen[k] {
+		return
+	}
+	lameSeen[k] = true
+	lameServers = append(lameServers, lameServer{zone, s, reason})
+}
+
+func lameReport() {
+	fmt.Printf("[+] Lame Servers:     %v\n", len(lameServers))
+	for _, l := range lameServers {
+		fmt.Printf("[-]   %v %v: %v\n", l.zone, l.server, l.reason)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// latencies holds the round trip of the attempt that got each answer, in
+// the order answers arrived, latencyTimes when each arrived in seconds
+// since the start of the run
+var (
+	latencies    []time.Duration
+	latencyTimes []float64
+)
+
+func recordLatency(d time.Duration) {
+	seriesMu.Lock()
+	latencies = append(latencies, d)
+	latencyTimes = append(latencyTimes, getRunTime())
+	seriesMu.Unlock()
+}
+
+// latencyMs returns the recorded latencies in milliseconds for graphing
+func latencyMs() []float64 {
+	ms := make([]float64, len(latencies))
+	for i, l := range latencies {
+		ms[i] = durationMs(l)
+	}
+	return ms
+}
+
+// latencyPercentile returns the nearest rank percentile p (0-100) of the
+// recorded latencies
+func latencyPercentile(p float64) time.Duration {
+	return percentileOf(latencies, p)
+}
+
+func percentileOf(d []time.Duration, p float64) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	s := append([]time.Duration(nil), d...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+
+	i := int(p/100*float64(len(s))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(s) {
+		i = len(s) - 1
+	}
+	return s[i]
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func formatMs(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", durationMs(d))
+}
+
+// latencyStats summarizes the distribution of the answered queries, the
+// interquartile range is less sensitive to a few stragglers than the
+// standard deviation
+func latencyStats() {
+	if len(latencies) == 0 {
+		return
+	}
+
+	var sum float64
+	min, max := latencies[0], latencies[0]
+	for _, l := range latencies {
+		sum += float64(l)
+		if l < min {
+			min = l
+		}
+		if l > max {
+			max = l
+		}
+	}
+	mean := sum / float64(len(latencies))
+
+	var sq float64
+	for _, l := range latencies {
+		sq += (float64(l) - mean) * (float64(l) - mean)
+	}
+	stddev := time.Duration(math.Sqrt(sq / float64(len(latencies))))
+
+	q1, q3 := latencyPercentile(25), latencyPercentile(75)
+	fmt.Printf("[+] Latency:          min %v / mean %v / max %v\n",
+		formatMs(min), formatMs(time.Duration(mean)), formatMs(max))
+	fmt.Printf("[+]   %-16s%v\n", "Std Dev:", formatMs(stddev))
+	fmt.Printf("[+]   %-16s%v\n", "Median:", formatMs(latencyPercentile(50)))
+	fmt.Printf("[+]   %-16s%v (%v - %v)\n", "IQR:", formatMs(q3-q1), formatMs(q1), formatMs(q3))
+	fmt.Printf("[+]   %-16s%v\n", "p95:", formatMs(latencyPercentile(95)))
+	fmt.Printf("[+]   %-16s%v\n", "p99:", formatMs(latencyPercentile(99)))
+	fmt.Printf("[+]   %-16s%v\n", "Jitter:", formatMs(latencyJitter()))
+}
+
+// latencyJitter is the mean absolute difference between the latencies of
+// consecutive answers, the interarrival jitter of RFC 3550 without its
+// smoothing
+func latencyJitter() time.Duration {
+	if len(latencies) < 2 {
+		return 0
+	}
+	var sum time.Duration
+	for i := 1; i < len(latencies); i++ {
+		d := latencies[i] - latencies[i-1]
+		if d < 0 {
+			d = -d
+		}
+		sum += d
+	}
+	return sum / time.Duration(len(latencies)-1)
+}
+
+func msDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
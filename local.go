@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+)
+
+var (
+	localMode = flag.String("local", "", "Query the local network over multicast instead of the nameserver (mdns|llmnr)")
+)
+
+// localGroups are the IPv4 multicast groups of mDNS (RFC 6762) and LLMNR
+// (RFC 4795)
+var localGroups = map[string]string{
+	"mdns":  "224.0.0.251:5353",
+	"llmnr": "224.0.0.252:5355",
+}
+
+// multicastConn sends every write to the group while reading the unicast
+// answers of any responder, a connected socket would drop those since
+// they do not come from the group address. Queries from a port other than
+// 5353 are one-shot mDNS queries which responders answer directly with
+// the query ID echoed (RFC 6762 section 6.7).
+type multicastConn struct {
+	*net.UDPConn
+	group *net.UDPAddr
+}
+
+func (c *multicastConn) Write(b []byte) (int, error) {
+	return c.WriteTo(b, c.group)
+}
+
+func (c *multicastConn) RemoteAddr() net.Addr {
+	return c.group
+}
+
+func dialMulticast(addr string) (net.Conn, error) {
+	group, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	return &multicastConn{c, group}, nil
+}
+
+// setupLocal points the run at the multicast group of the chosen protocol,
+// the nameserver only remains as the label of the output
+func setupLocal() error {
+	group, ok := localGroups[*localMode]
+	if !ok {
+		return fmt.Errorf("unknown protocol %v", *localMode)
+	}
+	*nameserver = *localMode
+	nsAddr = group
+	*preflight = false
+	return nil
+}
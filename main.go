@@ -4,10 +4,15 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math/rand"
 	"net"
 	"os"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/miekg/dns"
@@ -19,23 +24,48 @@ type domainRecord struct {
 	domain  string
 	timeout time.Time
 	resend  int
+	// sent is the UnixNano time of the latest attempt, stored by the writer
+	sent   int64
+	socket int
+	qtype  uint16
+	subnet string
+	// mac is the TSIG request MAC of the latest attempt, stored by the
+	// writer
+	mac atomic.Value
 }
 
 type domainAnswer struct {
-	id     uint16
-	domain string
-	ips    []net.IP
+	id        uint16
+	domain    string
+	ips       []net.IP
+	rcode     int
+	truncated bool
+	msg       *dns.Msg
+	received  time.Time
+	socket    int
+	// raw is kept to verify the TSIG of the response
+	raw []byte
 }
 
 type statistics struct {
 	attempts int
-	success  int
-	fail     int
+	// sent is added to by the writers and read by the stats goroutine, so
+	// it is only accessed atomically
+	sent      int64
+	success   int
+	fail      int
+	truncated int
+	rcodes    map[int]int
 }
 
 var (
 	rateValues = []float64{0}
 	timeValues = []float64{0}
+	// seriesMu guards the rate and latency samples against the dashboard
+	// reading them while the run appends to them
+	seriesMu sync.Mutex
+	// graphPath is the rate graph of the last finished run
+	graphPath string
 )
 
 var (
@@ -48,7 +78,12 @@ var (
 	td       time.Duration
 	avgRate  float64
 	avgTries float64
-	stats    statistics
+	stats    = statistics{rcodes: make(map[int]int)}
+	// statsMu guards the attempt, success and fail counts the guard
+	// goroutine writes for anyone reading them during the run
+	statsMu sync.Mutex
+	// runCompleted tells whether the last run got through its domain list
+	runCompleted bool
 )
 
 var (
@@ -61,23 +96,74 @@ var (
 	client           = flag.String("c", "", "Client subnet address")
 	outputDir        = flag.String("o", "output", "Location of output directory")
 	retryCount       = flag.Int("retries", 1, "Number of attempts made to resolve a domain")
+	iterative        = flag.Bool("iterative", false, "Resolve from the root servers instead of querying the nameserver")
+	maxIdle          = flag.Duration("max-idle", 0, "Abandon the run when no query succeeds for this long (0 waits for every query's deadline)")
+	queryTypeName    = flag.String("type", "A", "Query type of the benchmark (A, AAAA, HTTPS, SVCB, ...)")
 )
 
-func main() {
-	domains := make(chan string, *concurrency)
-	domainSlotAvailable := make(chan bool, *concurrency)
+// queryType is the parsed -type
+var queryType = dns.TypeA
 
-	for i := 0; i < *concurrency; i++ {
-		domainSlotAvailable <- true
+func main() {
+	if subcommand != nil {
+		runCommand(flag.Arg(0), flag.Args()[1:])
+		return
 	}
 
-	go readDomains(domains, domainSlotAvailable)
+	if err := chooseFamily(); err != nil {
+		fmt.Fprintf(os.Stderr, "preflight: %s\n", err)
+		os.Exit(1)
+	}
+	if *preflight && !*iterative {
+		if err := checkHealth(); err != nil {
+			fmt.Fprintf(os.Stderr, "preflight: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	runProbes()
 
-	c, err := net.Dial("udp", fmt.Sprintf("%v:53", *nameserver))
+	completed, err := runBenchmark()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "bind(udp, %s): %s\n", *nameserver, err)
+		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}
+	if !completed {
+		fmt.Println("Requests being declined. Terminating query.")
+	}
+	finalStats()
+	sum := summarize(completed)
+	notifyRun(sum)
+	writeSummaryJSON(sum)
+	if !completed {
+		os.Exit(2)
+	}
+	breached := reportThresholds(sum)
+	if reportSLO(sum) || breached {
+		os.Exit(exitThreshold)
+	}
+}
+
+// runBenchmark resolves the domain list once, it returns false when the
+// run was abandoned because the nameserver stopped answering. An error is
+// returned when the run could not start, leaving it to the caller whether
+// that ends the program.
+func runBenchmark() (bool, error) {
+	resetRun()
+
+	if err := openAnswers(); err != nil {
+		return false, fmt.Errorf("answers: %v", err)
+	}
+	if err := openSinks(); err != nil {
+		closeAnswers()
+		return false, fmt.Errorf("sink: %v", err)
+	}
+
+	domains := make(chan string, *concurrency)
+	domainSlotAvailable := make(chan bool, *concurrency)
+
+	for i := 0; i < *concurrency; i++ {
+		domainSlotAvailable <- true
+	}
 
 	timeoutRegister := make(chan *domainRecord, *concurrency*1000)
 	timeoutExpired := make(chan *domainRecord)
@@ -85,25 +171,106 @@ func main() {
 	resolved := make(chan *domainAnswer, *concurrency)
 	tryResolving := make(chan *domainRecord, *concurrency)
 
-	done := make(chan bool)
-	defer close(done)
-
-	go getTimeout(timeoutRegister, timeoutExpired)
-	go writeRequest(c, tryResolving)
-	go readRequest(c, resolved)
-	go updateStats(done)
+	stop := make(chan struct{})
+	go readDomains(domains, domainSlotAvailable, stop)
+
+	abort := make(chan struct{})
+	var once sync.Once
+	cancel := func() { once.Do(func() { close(abort) }) }
+	setRunning(cancel)
+	defer setRunning(nil)
+
+	go getTimeout(timeoutRegister, timeoutExpired, stop)
+
+	var conns []net.Conn
+	switch {
+	case *iterative:
+		go iterateRequests(tryResolving, resolved, stop)
+	case *dohURL != "":
+		go writeDoH(tryResolving, resolved, stop)
+	case *portStrategy == "per-query":
+		go writePerQuery(tryResolving, resolved, stop)
+	default:
+		var err error
+		conns, err = openSockets()
+		if err != nil {
+			close(stop)
+			closeAnswers()
+			closeSinks(runSummary{})
+			return false, fmt.Errorf("bind(%s, %s): %v", *transport, *nameserver, err)
+		}
+		go writeRequest(conns, tryResolving)
+		for i, c := range conns {
+			go readRequest(c, i, resolved, stop)
+		}
+	}
+	go updateStats(stop, cancel)
 
 	t0 = time.Now()
+	go probeIdentity(stop)
 
-	doMapGuard(
+	completed := doMapGuard(
 		domains, domainSlotAvailable,
 		timeoutRegister, timeoutExpired,
-		tryResolving, resolved)
+		tryResolving, resolved, abort)
 
 	td = time.Now().Sub(t0)
-	done <- true
+	runCompleted = completed
+	close(stop)
+	close(tryResolving)
+	closeSockets(conns)
+	return completed, nil
+}
 
-	finalStats()
+// resetRun clears the results of a previous run so the engine can be
+// started again
+func resetRun() {
+	runID = newRunID()
+	seriesMu.Lock()
+	rateValues = []float64{0}
+	timeValues = []float64{0}
+	latencies, latencyTimes = nil, nil
+	seriesMu.Unlock()
+	avgRate, avgTries = 0, 0
+	stats = statistics{rcodes: make(map[int]int)}
+	errorCounts = [numErrorClasses]int64{}
+
+	rrlCur, rrlAvg, rrlOnset, rrlActive = rrlWindow{}, 0, -1, false
+	rrlWindows, rrlMarks = 0, nil
+	ttlValues, ttlDomain = nil, make(map[string][]uint32)
+	answerSizes, answerTypes = make(map[int]int), make(map[uint16]int)
+	emptyNoError = nil
+	cnameLengths, cnameTargets = make(map[int]int), make(map[string]int)
+	answerIPs = make(map[string]net.IP)
+	reverseNames = make(map[string]string)
+	expectFailures, expectChecked = nil, 0
+	goldenRun = make(map[string]goldenEntry)
+	digResponses = nil
+	outcomeEvents = nil
+	attemptTimes, lostTimes = nil, nil
+	answered, failed = nil, nil
+	unmatchedIDs, unmatchedQuestions, unmatchedExamples = 0, 0, nil
+	duplicates, lateAnswers = 0, 0
+	portsUsed = make(map[int]int)
+	timeoutFailovers, rcodeFailovers, secondaryAnswers = 0, 0, 0
+	resetBreakers()
+	echConfigs, svcAnswers = make(map[string]string), 0
+	srvEndpoints = nil
+	ptrNamed = 0
+	mixSent = make(map[uint16]int)
+	anyResponses = make(map[string]int)
+	resetSetup()
+	resetPool()
+	resetTFO()
+	resetDoH()
+	resetSubnets()
+	resetScopes()
+	resetGeo()
+	answerSets = make(map[string]map[string]int)
+	catchment = make(map[string]map[string]int)
+	resetIdentity()
+	subnetAnswers = make(map[string]map[string]net.IP)
+	resetEnrich()
 }
 
 func doMapGuard(
@@ -112,14 +279,21 @@ func doMapGuard(
 	timeoutRegister chan<- *domainRecord,
 	timeoutExpired <-chan *domainRecord,
 	tryResolving chan<- *domainRecord,
-	resolved <-chan *domainAnswer) {
+	resolved <-chan *domainAnswer,
+	abort <-chan struct{}) bool {
 
-	m := make(map[uint16]*domainRecord)
+	ct := newCorrelator()
 	done := false
+	completed := true
 	sumTries := 0
 
-	for done == false || len(m) > 0 {
+guard:
+	for done == false || ct.len() > 0 {
 		select {
+		case <-abort:
+			completed = false
+			break guard
+
 		case domain := <-domains:
 			if domain == "" {
 				domains = make(chan string)
@@ -127,31 +301,52 @@ func doMapGuard(
 				break
 			}
 
-			var id uint16
-			for {
-				id = dns.Id()
-				if id != 0 && m[id] == nil {
-					break
+			socket := routeQuery(stats.attempts % querySockets())
+			id, wait := ct.nextID(socket)
+			for wait > 0 {
+				if !ct.exhausted {
+					ct.exhausted = true
+					fmt.Fprintf(os.Stderr, "warning: query IDs of socket %d exhausted, queries wait for released ones "+
+						"to leave their %v quarantine, spread the rate over more -sockets or lower -rr\n", socket, quarantine())
 				}
+				time.Sleep(wait)
+				id, wait = ct.nextID(socket)
 			}
 
-			dr := &domainRecord{id, domain, time.Now(), 0}
-			m[id] = dr
+			dr := &domainRecord{id: id, domain: domain, timeout: time.Now(), socket: socket,
+				qtype: pickType(), subnet: pickSubnet(stats.attempts, domain)}
+			ct.add(dr)
 
 			if *verbose {
 				fmt.Fprintf(os.Stderr, "0x%04x resolving %s\n", id, domain)
 			}
 
+			statsMu.Lock()
 			stats.attempts++
+			statsMu.Unlock()
+			recordAttempt()
 			timeoutRegister <- dr
 			tryResolving <- dr
 
 		case dr := <-timeoutExpired:
-			if m[dr.id] == dr {
+			// an attempt moved to the secondary before it timed out
+			// leaves its earlier timeout behind
+			if ct.outstanding(dr) && time.Since(dr.timeout) >= retryDelay {
+				recordLost(dr.timeout)
+				recordHealth(dr.socket, false)
 				if dr.resend == *retryCount {
-					delete(m, dr.id)
+					ct.remove(dr, false)
 					domainSlotAvailable <- true
+					statsMu.Lock()
 					stats.fail++
+					statsMu.Unlock()
+					countError(errTimeout)
+					recordOutcome(dr.domain, "TIMEOUT", nil)
+					publishResult(dr.domain, "TIMEOUT", nil, dr.resend, 0, "")
+					recordDig(dr.domain, dr.qtype, nil, 0, false)
+					recordOutcomeEvent("TIMEOUT")
+					recordDomain(domainOutcome{dr.domain, false, "TIMEOUT",
+						errTimeout, 0, dr.resend, nil, dr.subnet, ""})
 
 					if *verbose {
 						fmt.Fprintf(os.Stderr, "0x%04x resend (FAILED: exceed %v attempts) %s\n",
@@ -159,6 +354,9 @@ func doMapGuard(
 					}
 					break
 				}
+				if ct.failover(dr, false) {
+					timeoutFailovers++
+				}
 				dr.resend++
 				dr.timeout = time.Now()
 
@@ -167,21 +365,48 @@ func doMapGuard(
 						dr.resend, dr.domain)
 				}
 
+				recordAttempt()
 				timeoutRegister <- dr
 				tryResolving <- dr
 			}
 
 		case da := <-resolved:
-			if m[da.id] != nil {
-				dr := m[da.id]
-				if dr.domain != da.domain {
-					if *verbose {
-						fmt.Fprintf(os.Stderr, "0x%04x error, unrecognized domain: %s != %s\n",
-							da.id, dr.domain, da.domain)
-					}
-					break
+			dr, r, asked := ct.match(da)
+			if dr == nil {
+				recordUnmatched(da, r, asked)
+			} else if err := verifyTSIG(dr, da); err != nil {
+				// nothing of a response failing its TSIG can be trusted,
+				// it only counts as a failure of the domain
+				ct.remove(dr, true)
+				domainSlotAvailable <- true
+				statsMu.Lock()
+				stats.fail++
+				statsMu.Unlock()
+				countError(errTSIG)
+				recordOutcome(dr.domain, "BADSIG", nil)
+				publishResult(dr.domain, "BADSIG", nil, dr.resend, 0, "")
+				recordDig(dr.domain, dr.qtype, nil, 0, false)
+				recordOutcomeEvent("BADSIG")
+				recordDomain(domainOutcome{dr.domain, false, "BADSIG",
+					errTSIG, 0, dr.resend, nil, dr.subnet, ""})
+
+				if *verbose {
+					fmt.Fprintf(os.Stderr, "0x%04x tsig: %s %s\n", dr.id, err, dr.domain)
+				}
+			} else if ct.failoverAnswer(dr, da) {
+				dr.resend++
+				dr.timeout = time.Now()
+
+				if *verbose {
+					fmt.Fprintf(os.Stderr, "0x%04x failover (%s) %s\n", dr.id,
+						rcodeName(da.rcode), dr.domain)
 				}
 
+				recordAttempt()
+				timeoutRegister <- dr
+				tryResolving <- dr
+			} else {
+
 				if *verbose {
 					fmt.Fprintf(os.Stderr, "0x%04x resolved %s\n",
 						dr.id, dr.domain)
@@ -193,80 +418,196 @@ func doMapGuard(
 				}
 				sort.Sort(sort.StringSlice(s))
 
+				if onSecondary(dr.socket) {
+					secondaryAnswers++
+				}
 				sumTries += dr.resend
-				stats.success++
+				latency := da.received.Sub(time.Unix(0, atomic.LoadInt64(&dr.sent)))
+				recordLatency(latency)
+				recordSubnetLatency(dr.subnet, latency)
+				stats.rcodes[da.rcode]++
+				if da.truncated {
+					stats.truncated++
+				}
+				recordResponse(da)
+				recordTTLs(dr.domain, da.msg)
+				recordShape(dr.domain, da.msg)
+				recordScope(dr.subnet, da.msg)
+				recordNSID(dr.subnet, da.msg)
+				server := recordIdentity(da.msg)
+				recordCNAME(dr.domain, da.msg)
+				recordECH(dr.domain, svcRecords(da))
+				recordSRV(dr.domain, da.msg)
+				recordPTR(da.msg)
+				recordANY(dr.qtype, da.msg)
+				writeAnswers(dr.domain, da.msg)
+				recordIPs(da.ips)
+				recordDiversity(dr.domain, da.ips)
+				recordSubnetAnswers(dr.subnet, da.ips)
+				recordEnrich(da.msg)
+				recordOutcome(dr.domain, rcodeName(da.rcode), da.ips)
+				publishResult(dr.domain, rcodeName(da.rcode), da.ips, dr.resend, latency, server)
+				ok := !isRcodeError(da.rcode)
+				class := errRcode
+				if _, expected := expectations[strings.ToLower(dr.domain)]; expected {
+					ok = recordExpectation(dr.domain, da)
+					class = errAssertion
+				} else if ok && geoRules != nil && !recordGeo(dr, da) {
+					ok, class = false, errAssertion
+				} else if !ok {
+					countError(errRcode)
+				}
+				statsMu.Lock()
+				if ok {
+					stats.success++
+				} else {
+					stats.fail++
+				}
+				statsMu.Unlock()
+				recordDig(dr.domain, dr.qtype, da.msg, latency, ok)
+				recordOutcomeEvent(rcodeName(da.rcode))
+				recordDomain(domainOutcome{dr.domain, ok, rcodeName(da.rcode),
+					class, latency, dr.resend, da.ips, dr.subnet, server})
 
-				delete(m, dr.id)
+				ct.remove(dr, true)
 				domainSlotAvailable <- true
 			}
 		}
 	}
-	avgTries = float64(sumTries) / float64(stats.success)
+	if stats.success > 0 {
+		avgTries = float64(sumTries) / float64(stats.success)
+	}
+	return completed
 }
 
 func getTimeout(timeoutRegister <-chan *domainRecord,
-	timeoutExpired chan<- *domainRecord) {
+	timeoutExpired chan<- *domainRecord, stop <-chan struct{}) {
 	for {
-		dr := <-timeoutRegister
+		var dr *domainRecord
+		select {
+		case dr = <-timeoutRegister:
+		case <-stop:
+			return
+		}
 		t := dr.timeout.Add(retryDelay)
 		now := time.Now()
 
 		if delta := t.Sub(now); delta > 0 {
 			time.Sleep(delta)
 		}
-		timeoutExpired <- dr
+		select {
+		case timeoutExpired <- dr:
+		case <-stop:
+			return
+		}
 	}
 }
 
-func writeRequest(c net.Conn, tryResolving <-chan *domainRecord) {
-	for {
-		dr := <-tryResolving
+func writeRequest(conns []net.Conn, tryResolving <-chan *domainRecord) {
+	for dr := range tryResolving {
+		writeQuery(conns[dr.socket], dr)
+	}
+}
 
-		t := dns.TypeA
-		msg := buildQuery(dr.id, dr.domain, t, dns.ClassINET)
+func writeQuery(c net.Conn, dr *domainRecord) {
+	msg, mac := buildQuery(dr.id, dr.domain, dr.qtype, dns.ClassINET, dr.subnet)
+	dr.mac.Store(mac)
 
-		_, err := c.Write(msg)
-		if err != nil {
+	atomic.StoreInt64(&dr.sent, time.Now().UnixNano())
+	_, err := c.Write(msg)
+	if err != nil {
+		countError(errNetwork)
+		if *verbose {
 			fmt.Fprintf(os.Stderr, "write(udp): %s\n", err)
-			os.Exit(1)
 		}
-		time.Sleep(sendingDelay)
 	}
+	atomic.AddInt64(&stats.sent, 1)
+	time.Sleep(sendingDelay)
 }
 
-func readRequest(c net.Conn, resolved chan<- *domainAnswer) {
-	buf := make([]byte, 4096)
+func readRequest(c net.Conn, socket int, resolved chan<- *domainAnswer, stop <-chan struct{}) {
+	buf := make([]byte, dns.MaxMsgSize)
 
 	for {
 		n, err := c.Read(buf)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%s\n", err)
-			os.Exit(1)
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				// the deadline of a per-query socket passed
+				return
+			}
+			if err == io.EOF {
+				// the server closed a stream transport connection
+				return
+			}
+
+			// connected udp sockets surface icmp errors on read, these
+			// only affect the query in flight which will time out
+			countError(errNetwork)
+			if *verbose {
+				fmt.Fprintf(os.Stderr, "read(udp): %s\n", err)
+			}
+			continue
 		}
 
 		msg := new(dns.Msg)
-		msg.Unpack(buf[:n])
+		if err := msg.Unpack(buf[:n]); err != nil || len(msg.Question) == 0 {
+			countError(errMalformed)
+			continue
+		}
+		da := newAnswer(msg)
+		da.socket = socket
+		if tsig != nil {
+			da.raw = append([]byte(nil), buf[:n]...)
+		}
+		select {
+		case resolved <- da:
+		case <-stop:
+			return
+		}
+	}
+}
 
-		domain := msg.Question[0].Name
-		id := msg.Id
-		var ips []net.IP
+func newAnswer(msg *dns.Msg) *domainAnswer {
+	var ips []net.IP
 
-		for _, a := range msg.Answer {
-			if t, ok := a.(*dns.A); ok {
-				ips = append(ips, t.A.To4())
-			}
+	for _, a := range msg.Answer {
+		switch t := a.(type) {
+		case *dns.A:
+			ips = append(ips, t.A.To4())
+		case *dns.AAAA:
+			ips = append(ips, t.AAAA)
 		}
-		resolved <- &domainAnswer{id, domain, ips}
+	}
+
+	var domain string
+	if len(msg.Question) > 0 {
+		domain = msg.Question[0].Name
+	}
+	return &domainAnswer{
+		id:        msg.Id,
+		domain:    domain,
+		ips:       ips,
+		rcode:     msg.Rcode,
+		truncated: msg.Truncated,
+		msg:       msg,
+		received:  time.Now(),
 	}
 }
 
-func buildQuery(id uint16, name string, qtype uint16, qclass uint16) []byte {
+// buildQuery packs a query, signing it when a TSIG key is configured. The
+// MAC is needed to verify the response.
+func buildQuery(id uint16, name string, qtype uint16, qclass uint16, subnet string) ([]byte, string) {
 	m := &dns.Msg{
 		MsgHdr: dns.MsgHdr{
 			Authoritative:     false,
 			AuthenticatedData: false,
 			CheckingDisabled:  false,
-			RecursionDesired:  true,
+			RecursionDesired:  *localMode == "",
 			Opcode:            dns.OpcodeQuery,
 			Id:                id,
 			Rcode:             dns.RcodeSuccess,
@@ -279,34 +620,65 @@ func buildQuery(id uint16, name string, qtype uint16, qclass uint16) []byte {
 		Qclass: qclass,
 	}
 
-	if *client != "" {
-		m.Extra = append(m.Extra, setupOptions())
+	if subnet != "" || *bufSize > 0 || *nsidOn {
+		m.Extra = append(m.Extra, setupOptions(subnet))
 	}
 
+	if tsig != nil {
+		tsig.sign(m)
+		msg, mac, _ := dns.TsigGenerate(m, tsig.secret, "", false)
+		return msg, mac
+	}
 	msg, _ := m.Pack()
-	return msg
+	return msg, ""
 }
 
-func setupOptions() *dns.OPT {
+func setupOptions(subnet string) *dns.OPT {
 	o := &dns.OPT{
 		Hdr: dns.RR_Header{
 			Name:   ".",
 			Rrtype: dns.TypeOPT,
 		},
 	}
+	if *bufSize > 0 {
+		o.SetUDPSize(uint16(*bufSize))
+	}
+	if *nsidOn {
+		o.Option = append(o.Option, nsidOption())
+	}
+	if subnet == "" {
+		return o
+	}
+
+	// a prefix is sent with its length, a plain address without one
+	ip, ones := net.ParseIP(subnet), 0
+	if p, ipnet, err := net.ParseCIDR(subnet); err == nil {
+		ip = p.Mask(ipnet.Mask)
+		ones, _ = ipnet.Mask.Size()
+	}
 	e := &dns.EDNS0_SUBNET{
 		Code:    dns.EDNS0SUBNET,
-		Address: net.ParseIP(*client).To4(),
+		Address: ip.To4(),
 		Family:  1, // IP4
 		// SourceNetmask: net.IPv4len * 8,
-		SourceNetmask: 0,
+		SourceNetmask: uint8(ones),
 		SourceScope:   0,
 	}
+	if ip != nil && ip.To4() == nil {
+		e.Family, e.Address = 2, ip // IP6
+	}
 	o.Option = append(o.Option, e)
 
 	return o
 }
 
+func rcodeName(rc int) string {
+	if s, ok := dns.RcodeToString[rc]; ok {
+		return s
+	}
+	return fmt.Sprintf("RCODE%d", rc)
+}
+
 func getRunTime() float64 {
 	return float64(time.Since(t0).Seconds())
 }
@@ -347,68 +719,122 @@ func GetDomains(n string) ([]string, error) {
 	return qname, err
 }
 
-func readDomains(domains chan<- string, domainSlotAvailable <-chan bool) {
+// readDomains hands out the domain list as slots become available, it
+// gives up once stop is closed by a run that ended early
+func readDomains(domains chan<- string, domainSlotAvailable <-chan bool, stop <-chan struct{}) {
 	i := 0
-	in, err := GetDomains(*domainList)
+	in, err := shardDomains, error(nil)
+	switch {
+	case in != nil:
+	case *ptrRange != "":
+		in, err = ptrDomains(*ptrRange)
+	default:
+		in, err = GetDomains(*domainList)
+	}
+	if err == nil {
+		in, querySubnets, err = splitDomains(in)
+	}
 	domainLength := len(in)
 	if err != nil {
 		fmt.Printf("%v", err)
 	}
 
-	for range domainSlotAvailable {
-		if i == domainLength-1 {
-			break
+	for ; i < domainLength; i++ {
+		select {
+		case <-domainSlotAvailable:
+		case <-stop:
+			return
+		}
+		select {
+		case domains <- in[i] + ".":
+		case <-stop:
+			return
 		}
-		domain := in[i] + "."
-		domains <- domain
-		i++
 	}
 	close(domains)
 }
 
-func updateStats(done <-chan bool) {
-	// Stop execution after 50 consecutive zero-rate returns
-	var deadStop int = 50
+func successCount() int {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	return stats.success
+}
+
+// updateStats samples the success rate for the graphs and progress line.
+// Queries end on their own deadline, the run is only abandoned early when
+// -max-idle is set and no answer succeeded for that long.
+func updateStats(stop <-chan struct{}, abort func()) {
 	var deltaCount int
 	interval := 50 * time.Millisecond
 	ticker := time.NewTicker(interval)
-	lastCount := stats.success
+	defer ticker.Stop()
+	lastCount := successCount()
+	lastSent := atomic.LoadInt64(&stats.sent)
+	lastAnswer := time.Now()
 
 	for {
 		select {
-		case <-done:
-			ticker.Stop()
+		case <-stop:
 			return
-		case <-ticker.C:
-			if deltaCount == 0 {
-				deadStop--
-				if deadStop < 1 {
-					fmt.Println("Requests being declined. Terminating query.")
-					finalStats()
-					os.Exit(2)
-				}
-			} else {
-				deadStop = 50
-			}
-			currentCount := stats.success
+		case now := <-ticker.C:
+			currentCount := successCount()
 			deltaCount = currentCount - lastCount
 			lastCount = currentCount
+			if deltaCount > 0 {
+				lastAnswer = now
+			} else if *maxIdle > 0 && now.Sub(lastAnswer) > *maxIdle {
+				abort()
+				return
+			}
+
+			rate := float64(deltaCount) / float64(interval) * float64(time.Second)
+			seriesMu.Lock()
 			timeValues = append(timeValues, getRunTime())
-			rateValues = append(rateValues,
-				float64(deltaCount)/float64(interval)*float64(time.Second))
-		default:
-			fmt.Printf("\033[2K\r[%.2f] rate: %.4f queries/s",
-				getRunTime(),
-				float64(deltaCount)/float64(interval)*float64(time.Second))
-		}
+			rateValues = append(rateValues, rate)
+			seriesMu.Unlock()
+			sent := atomic.LoadInt64(&stats.sent)
+			checkThrottle(getRunTime(), rate, int(sent-lastSent))
+			lastSent = sent
 
+			fmt.Printf("\033[2K\r[%.2f] rate: %.4f queries/s", getRunTime(), rate)
+		}
 	}
 }
 
 func finalStats() {
-	graph.BuildGraph(*nameserver, *client, len(*client) != 0,
-		&timeValues, &rateValues, *concurrency, stats.success, *outputDir)
+	closeAnswers()
+	flushTSV()
+
+	graphPath = graph.BuildGraph(*nameserver, *client, len(*client) != 0,
+		&timeValues, &rateValues, rrlMarks, *concurrency, stats.success, *outputDir)
 
+	rcodes := make([]int, 0, len(stats.rcodes))
+	for rc := range stats.rcodes {
+		rcodes = append(rcodes, rc)
+	}
+	sort.Ints(rcodes)
+
+	labels := make([]string, 0, len(rcodes))
+	counts := make([]int, 0, len(rcodes))
+	for _, rc := range rcodes {
+		labels = append(labels, rcodeName(rc))
+		counts = append(counts, stats.rcodes[rc])
+	}
+	graph.BuildRcodeGraph(*nameserver, len(*client) != 0, labels, counts, *outputDir)
+	st, rates := outcomeRates()
+	rateLabels := sortedRates(rates)
+	rateSeries := make([][]float64, 0, len(rateLabels))
+	for _, l := range rateLabels {
+		rateSeries = append(rateSeries, rates[l])
+	}
+	graph.BuildRcodeSeries(*nameserver, len(*client) != 0, st, rateLabels, rateSeries, *outputDir)
+	lt, loss := lossRates()
+	graph.BuildLossGraph(*nameserver, len(*client) != 0, lt, loss, *outputDir)
+	graph.BuildLatencyScatter(*nameserver, len(*client) != 0, latencyTimes, latencyMs(), *outputDir)
+	graph.BuildLatencyCDF(*nameserver, len(*client) != 0, []string{compareLabel(*nameserver, *client)},
+		[][]float64{latencyMs()}, *outputDir)
+
+	digStats()
 	fmt.Printf("\n\nFinal Statistics\n"+
 		"[+] Attempts:         %v\n"+
 		"[+] Success:          %v\n"+
@@ -418,25 +844,77 @@ func finalStats() {
 		"[+] Elapsed Time:     %.3f s\n",
 		stats.attempts, stats.success, stats.fail,
 		avgTries, getStatAvg(), td.Seconds())
+
+	fmt.Printf("[+] Response Codes:\n")
+	for i, l := range labels {
+		fmt.Printf("[+]   %-16s%v\n", l+":", counts[i])
+	}
+
+	latencyStats()
+	lossStats()
+	portStats()
+	setupStats()
+	poolReport()
+	tfoReport()
+	failoverStats()
+	breakerStats()
+	ttlStats()
+	shapeStats()
+	cnameStats()
+	reverseStats()
+	writeUniqueIPs()
+	echStats()
+	srvStats()
+	ptrStats()
+	mixStats()
+	subnetStats()
+	scopeStats()
+	diversityStats(answerSets)
+	catchmentStats(*nameserver, len(*client) != 0, catchment)
+	identityStats()
+	rdapStats()
+	enrichStats()
+	anyStats()
+	dohReport()
+	appendSeries()
+	expectStats()
+	geoStats()
+	goldenStats()
+	topStats()
+	unmatchedStats()
+
+	fmt.Printf("[+] Errors:\n")
+	for c := errorClass(0); c < numErrorClasses; c++ {
+		fmt.Printf("[+]   %-16s%v\n", c.String()+":", errorCount(c))
+	}
+
+	if rrlOnset >= 0 {
+		fmt.Printf("[+] Rate Limited:     after %.2f s (%d episodes, %d windows)\n",
+			rrlOnset, len(rrlMarks), rrlWindows)
+	}
+
+	for _, f := range findings {
+		fmt.Printf("[+] %-18s%v\n", f.name, f.value)
+	}
+	closeSinks(summarize(runCompleted))
 }
 
 func init() {
+	graph.Metadata = graphMeta
+	graph.RunID = currentRunID
+	graph.Create = createExclusive
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options] -ns {nameserver}\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] {command} [args]\n", os.Args[0])
 		flag.PrintDefaults()
+		commandUsage()
 	}
 	flag.Parse()
-
-	if *domainList == "" {
-		fmt.Println("Missing required domain list")
-		flag.Usage()
-		os.Exit(1)
-	}
-
-	if flag.NArg() != 0 {
-		flag.Usage()
+	if err := divertOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "output: %s\n", err)
 		os.Exit(1)
 	}
+	rand.Seed(time.Now().UnixNano())
 
 	sendingDelay = time.Duration(1000000000/(*packetsPerSecond)) * time.Nanosecond
 	var err error
@@ -446,23 +924,182 @@ func init() {
 		os.Exit(1)
 	}
 
-	var clientSub string
-	if *client == "" {
-		clientSub = "disabled"
-	} else {
-		clientSub = *client
+	if *expectFile != "" {
+		if err := loadExpectations(*expectFile); err != nil {
+			fmt.Fprintf(os.Stderr, "expect: %s\n", err)
+			os.Exit(1)
+		}
 	}
 
-	getBanner(sendingDelay, retryDelay, clientSub)
+	if *geoExpectFile != "" {
+		if err := loadGeoExpectations(*geoExpectFile); err != nil {
+			fmt.Fprintf(os.Stderr, "geo-expect: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *subnetMapFile != "" {
+		if err := loadSubnetMap(*subnetMapFile); err != nil {
+			fmt.Fprintf(os.Stderr, "subnet-map: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *goldenFile != "" {
+		if err := loadGolden(*goldenFile); err != nil {
+			fmt.Fprintf(os.Stderr, "golden: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *sloFile != "" {
+		if err := loadSLO(*sloFile); err != nil {
+			fmt.Fprintf(os.Stderr, "slo: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *webhookOn != "always" && *webhookOn != "breach" {
+		fmt.Fprintf(os.Stderr, "webhook: unknown condition %s\n", *webhookOn)
+		os.Exit(1)
+	}
+
+	queryType, err = parseType(*queryTypeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "type: %s\n", err)
+		os.Exit(1)
+	}
+
+	if *tsigFlag != "" {
+		k, err := parseTSIG(*tsigFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "tsig: %s\n", err)
+			os.Exit(1)
+		}
+		tsig = k
+	}
+
+	if *typeMix != "" {
+		if err := parseMix(*typeMix); err != nil {
+			fmt.Fprintf(os.Stderr, "mix: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *bufSize != 0 && (*bufSize < 512 || *bufSize > 65535) {
+		fmt.Fprintf(os.Stderr, "bufsize: %d out of range 512-65535\n", *bufSize)
+		os.Exit(1)
+	}
+
+	if *dscp < 0 || *dscp > 63 {
+		fmt.Fprintf(os.Stderr, "dscp: %d out of range 0-63\n", *dscp)
+		os.Exit(1)
+	}
+
+	if *portStrategy != "per-worker" && *portStrategy != "per-query" {
+		fmt.Fprintf(os.Stderr, "ports: unknown strategy %s\n", *portStrategy)
+		os.Exit(1)
+	}
+
+	switch *webhookFmt {
+	case "json", "slack", "teams":
+	default:
+		fmt.Fprintf(os.Stderr, "webhook: unknown format %s\n", *webhookFmt)
+		os.Exit(1)
+	}
+
+	if *dohURL != "" {
+		if err := setupDoH(); err != nil {
+			fmt.Fprintf(os.Stderr, "doh: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	if *localMode != "" {
+		if err := setupLocal(); err != nil {
+			fmt.Fprintf(os.Stderr, "local: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := setupTransport(); err != nil {
+		fmt.Fprintf(os.Stderr, "transport: %s\n", err)
+		os.Exit(1)
+	}
+	if err := setupPins(); err != nil {
+		fmt.Fprintf(os.Stderr, "pin: %s\n", err)
+		os.Exit(1)
+	}
+	if err := setupSocks(); err != nil {
+		fmt.Fprintf(os.Stderr, "socks5: %s\n", err)
+		os.Exit(1)
+	}
+	if err := setupCountries(); err != nil {
+		fmt.Fprintf(os.Stderr, "client-country: %s\n", err)
+		os.Exit(1)
+	}
+	if err := setupRandomSubnet(); err != nil {
+		fmt.Fprintf(os.Stderr, "random-subnet: %s\n", err)
+		os.Exit(1)
+	}
+	if err := setupEnrichers(); err != nil {
+		fmt.Fprintf(os.Stderr, "enrich: %s\n", err)
+		os.Exit(1)
+	}
+	if err := setupSinks(); err != nil {
+		fmt.Fprintf(os.Stderr, "sink: %s\n", err)
+		os.Exit(1)
+	}
+	if err := setupGraphs(); err != nil {
+		fmt.Fprintf(os.Stderr, "graph: %s\n", err)
+		os.Exit(1)
+	}
+
+	if flag.NArg() != 0 {
+		c, ok := commands[flag.Arg(0)]
+		if !ok {
+			flag.Usage()
+			os.Exit(1)
+		}
+		subcommand = &c
+		return
+	}
+
+	if *ptrRange != "" {
+		if _, err := ptrDomains(*ptrRange); err != nil {
+			fmt.Fprintf(os.Stderr, "ptr: %s\n", err)
+			os.Exit(1)
+		}
+		queryType = dns.TypePTR
+	} else if *domainList == "" {
+		fmt.Println("Missing required domain list")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *iterative {
+		*nameserver = "iterative"
+	}
+
+	getBanner(sendingDelay, retryDelay, clientLabel())
+}
+
+func clientLabel() string {
+	if *randomSubnet != "" {
+		return "random (" + *randomSubnet + ")"
+	}
+	if *client == "" {
+		return "disabled"
+	}
+	return *client
 }
 
 func getBanner(sndDelay, retryDelay time.Duration, client string) {
 	fmt.Printf("DNS Resolver Subnet Client Test\n"+
 		"[+] Nameserver:    %v\n"+
 		"[+] Subnet Client: %v\n"+
+		"[+] Query Type:    %v\n"+
 		"[+] Thread Count:  %v\n"+
 		"[+] Sending Delay: %s (%d pps)\n"+
 		"[+] Retry Delay:   %s\n\n",
-		*nameserver, client, *concurrency, sendingDelay,
+		*nameserver, client, queryTypeLabel(), *concurrency, sendingDelay,
 		*packetsPerSecond, retryDelay)
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rtmoranorg/dns-client-subnet-ext/graph"
+)
+
+var (
+	appendFile = flag.String("append", "", "Append the series of the run to this data file and graph all runs it holds together")
+	appendRuns = flag.Int("append-graph", 10, "Number of most recent runs of the -append file that are graphed")
+)
+
+// seriesRecord is one line of an -append data file
+type seriesRecord struct {
+	Meta      *runMeta  `json:"meta"`
+	Client    string    `json:"client,omitempty"`
+	Times     []float64 `json:"times"`
+	Rates     []float64 `json:"rates"`
+	Latencies []float64 `json:"latencies_ms"`
+}
+
+func (r seriesRecord) label() string {
+	return r.Meta.Started.Local().Format("2006-01-02 15:04:05")
+}
+
+func readSeries(name string) ([]seriesRecord, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []seriesRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		var r seriesRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil || r.Meta == nil {
+			continue
+		}
+		runs = append(runs, r)
+	}
+	return runs, scanner.Err()
+}
+
+// appendSeries adds the run to the data file and renders the recent runs
+// it holds, earlier runs of other nameservers are graphed alongside
+func appendSeries() {
+	if *appendFile == "" {
+		return
+	}
+	runs, err := readSeries(*appendFile)
+	if err != nil {
+		fmt.Printf("Error reading append file\n%v\n", err)
+		return
+	}
+	r := seriesRecord{currentMeta(), *client, timeValues, rateValues, latencyMs()}
+
+	f, err := os.OpenFile(*appendFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Printf("Error writing append file\n%v\n", err)
+		return
+	}
+	json.NewEncoder(f).Encode(r)
+	f.Close()
+
+	runs = append(runs, r)
+	if *appendRuns > 0 && len(runs) > *appendRuns {
+		runs = runs[len(runs)-*appendRuns:]
+	}
+	labels := make([]string, 0, len(runs))
+	var times, rates, samples [][]float64
+	for _, run := range runs {
+		label := run.label()
+		if run.Meta.Flags["ns"] != *nameserver {
+			label += " " + run.Meta.Flags["ns"]
+		}
+		labels = append(labels, label)
+		times = append(times, run.Times)
+		rates = append(rates, run.Rates)
+		samples = append(samples, run.Latencies)
+	}
+	graph.BuildRunsGraph(*nameserver, labels, times, rates, *outputDir)
+	graph.BuildRunsCDF(*nameserver, labels, samples, *outputDir)
+	fmt.Printf("[+] Appended Runs:    %v in %v\n", len(runs), *appendFile)
+}
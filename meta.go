@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+type hostInfo struct {
+	Hostname  string `json:"hostname"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	CPUs      int    `json:"cpus"`
+	GoVersion string `json:"go_version"`
+}
+
+// runMeta identifies the run an artifact came from along with everything
+// needed to interpret or repeat it
+type runMeta struct {
+	RunID   string            `json:"run_id"`
+	Version string            `json:"version"`
+	Args    []string          `json:"args"`
+	Flags   map[string]string `json:"flags"`
+	Host    hostInfo          `json:"host"`
+	Started time.Time         `json:"started"`
+	Written time.Time         `json:"written"`
+	Server  *serverInfo       `json:"server,omitempty"`
+}
+
+// runID names the current run, it is renewed by every benchmark run and
+// otherwise made up the first time an artifact is written
+var runID string
+
+func newRunID() string {
+	return fmt.Sprintf("%v-%06x", time.Now().UTC().Format("20060102T150405"), rand.Intn(1<<24))
+}
+
+// toolVersion is the module version and revision the binary was built
+// from, as far as the build recorded them
+func toolVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		return bi.Main.Version
+	}
+	v := "(devel)"
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if len(s.Value) > 12 {
+				s.Value = s.Value[:12]
+			}
+			v = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				defer func() { v += "+dirty" }()
+			}
+		}
+	}
+	return v
+}
+
+// redactFlag hides the credentials some flags carry, artifacts are shared
+// more freely than the command lines they came from
+func redactFlag(name, value string) string {
+	switch name {
+	case "tsig":
+		if i := strings.LastIndex(value, ":"); i >= 0 {
+			return value[:i+1] + "redacted"
+		}
+	case "doh-header":
+		h := strings.ToLower(value)
+		if strings.HasPrefix(h, "authorization:") || strings.HasPrefix(h, "cookie:") {
+			return value[:strings.Index(value, ":")+1] + " redacted"
+		}
+	}
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	// webhook URLs of Slack and Teams are secrets in their path
+	urls := strings.Split(value, ",")
+	for i, u := range urls {
+		urls[i] = redactURL(u, name == "webhook")
+	}
+	return strings.Join(urls, ",")
+}
+
+// redactURL hides the userinfo and query values of the URL in s, and its
+// path as well with path set. Whatever precedes the scheme, like the name
+// of a sink, is kept and an unparsable URL is hidden entirely.
+func redactURL(s string, path bool) string {
+	i := strings.Index(s, "://")
+	if i < 0 {
+		return s
+	}
+	start := strings.LastIndexFunc(s[:i], func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.')
+	}) + 1
+
+	u, err := url.Parse(s[start:])
+	if err != nil {
+		return s[:start] + "redacted"
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "redacted")
+		} else {
+			u.User = url.User("redacted")
+		}
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for k := range q {
+			q[k] = []string{"redacted"}
+		}
+		u.RawQuery = q.Encode()
+	}
+	if path && u.Path != "" && u.Path != "/" {
+		u.Path, u.RawPath = "/redacted", ""
+	}
+	return s[:start] + u.String()
+}
+
+// redactArgs applies redactFlag to the command line, flags may be given
+// as -name=value or -name value
+func redactArgs(args []string) []string {
+	out := make([]string, len(args))
+	copy(out, args)
+	for i, a := range out {
+		name := strings.TrimLeft(a, "-")
+		if name == a {
+			continue
+		}
+		if j := strings.Index(name, "="); j >= 0 {
+			out[i] = a[:len(a)-len(name)] + name[:j+1] + redactFlag(name[:j], name[j+1:])
+		} else if i+1 < len(out) {
+			out[i+1] = redactFlag(name, out[i+1])
+		}
+	}
+	return out
+}
+
+func currentRunID() string {
+	if runID == "" {
+		runID = newRunID()
+	}
+	return runID
+}
+
+func currentMeta() *runMeta {
+	flags := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		flags[f.Name] = redactFlag(f.Name, f.Value.String())
+	})
+	var headers []string
+	for _, h := range dohHeaders {
+		headers = append(headers, redactFlag("doh-header", h))
+	}
+	flags["doh-header"] = strings.Join(headers, ", ")
+
+	host, _ := os.Hostname()
+	m := &runMeta{
+		RunID:   currentRunID(),
+		Version: toolVersion(),
+		Args:    redactArgs(os.Args[1:]),
+		Flags:   flags,
+		Host: hostInfo{host, runtime.GOOS, runtime.GOARCH,
+			runtime.NumCPU(), runtime.Version()},
+		Started: t0,
+		Written: time.Now(),
+	}
+	if len(probedServer.Chaos) > 0 || probedServer.Fingerprint != "" {
+		s := probedServer
+		m.Server = &s
+	}
+	return m
+}
+
+// metaHeader renders the metadata as comment lines for text artifacts,
+// only the command line is given for the parameters since together with
+// the version it determines every other flag
+func metaHeader(comment string) string {
+	m := currentMeta()
+	var b strings.Builder
+	for _, kv := range [][2]string{
+		{"run_id", m.RunID},
+		{"version", m.Version},
+		{"args", strings.Join(m.Args, " ")},
+		{"host", fmt.Sprintf("%v %v/%v %v", m.Host.Hostname, m.Host.OS, m.Host.Arch, m.Host.GoVersion)},
+		{"started", m.Started.Format(time.RFC3339)},
+		{"written", m.Written.Format(time.RFC3339)},
+	} {
+		fmt.Fprintf(&b, "%v %v: %v\n", comment, kv[0], kv[1])
+	}
+	if m.Server != nil {
+		for _, n := range chaosNames {
+			if v, ok := m.Server.Chaos[n]; ok {
+				fmt.Fprintf(&b, "%v %v: %v\n", comment, n, v)
+			}
+		}
+		if m.Server.Fingerprint != "" {
+			fmt.Fprintf(&b, "%v software: %v (%v)\n", comment, m.Server.Software, m.Server.Fingerprint)
+		}
+	}
+	return b.String()
+}
+
+// writeMeta opens an artifact with its metadata in whatever way the format
+// allows, comment lines for text and a leading record for JSON lines.
+// Single JSON documents carry it as a field of their own instead.
+func writeMeta(f *os.File, ext string) {
+	switch ext {
+	case "tsv", "txt":
+		f.WriteString(metaHeader("#"))
+	case "zone":
+		f.WriteString(metaHeader(";"))
+	case "jsonl":
+		json.NewEncoder(f).Encode(struct {
+			Meta *runMeta `json:"meta"`
+		}{currentMeta()})
+	}
+}
+
+// graphMeta is embedded into every graph as PNG text chunks
+func graphMeta() map[string]string {
+	m := currentMeta()
+	b, _ := json.Marshal(m)
+	return map[string]string{
+		"Software":      "dns-client-subnet-ext " + m.Version,
+		"Creation Time": m.Written.Format(time.RFC1123Z),
+		"Comment":       string(b),
+	}
+}
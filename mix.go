@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	typeMix = flag.String("mix", "", "Weighted query types drawn at random per domain, e.g. A:70,AAAA:20,HTTPS:10 (overrides -type)")
+)
+
+type mixEntry struct {
+	qtype  uint16
+	weight int
+}
+
+var (
+	mixEntries []mixEntry
+	mixTotal   int
+	// mixSent counts the domains of the run by the type drawn for them
+	mixSent map[uint16]int
+)
+
+func parseMix(s string) error {
+	mixEntries, mixTotal = nil, 0
+	for _, f := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(f), ":", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("expected TYPE:WEIGHT, got %q", f)
+		}
+		t, err := parseType(kv[0])
+		if err != nil {
+			return err
+		}
+		w, err := strconv.Atoi(kv[1])
+		if err != nil || w < 0 {
+			return fmt.Errorf("invalid weight %q", kv[1])
+		}
+		mixEntries = append(mixEntries, mixEntry{t, w})
+		mixTotal += w
+	}
+	if mixTotal == 0 {
+		return fmt.Errorf("weights add up to zero")
+	}
+	return nil
+}
+
+// pickType draws the query type of a new domain
+func pickType() uint16 {
+	if mixTotal == 0 {
+		return queryType
+	}
+	n := rand.Intn(mixTotal)
+	for _, e := range mixEntries {
+		if n < e.weight {
+			mixSent[e.qtype]++
+			return e.qtype
+		}
+		n -= e.weight
+	}
+	return queryType
+}
+
+func queryTypeLabel() string {
+	if mixTotal > 0 {
+		return *typeMix
+	}
+	return typeName(queryType)
+}
+
+func mixStats() {
+	if len(mixSent) == 0 {
+		return
+	}
+	types := make([]uint16, 0, len(mixSent))
+	total := 0
+	for t, n := range mixSent {
+		types = append(types, t)
+		total += n
+	}
+	sort.Slice(types, func(i, j int) bool { return mixSent[types[i]] > mixSent[types[j]] })
+
+	fmt.Printf("[+] Query Mix:\n")
+	for _, t := range types {
+		fmt.Printf("[+]   %-16s%v (%.1f%%)\n", typeName(t)+":", mixSent[t],
+			float64(mixSent[t])/float64(total)*100)
+	}
+}
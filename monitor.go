@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	monitorInterval = flag.Duration("interval", 5*time.Minute, "Time between runs of the monitor command")
+	monitorCron     = flag.String("cron", "", "Cron expression scheduling runs of the monitor command, overrides -interval")
+	metricsAddr     = flag.String("metrics", "", "Serve Prometheus metrics of the monitor command on this address")
+)
+
+var (
+	monitorMu   sync.Mutex
+	monitorRuns int
+	lastRun     *runSummary
+	nextRun     time.Time
+	// cancelRun aborts the run in progress, it is nil between runs
+	cancelRun func()
+	runStart  time.Time
+	runNow    = make(chan struct{}, 1)
+)
+
+func setRunning(cancel func()) {
+	monitorMu.Lock()
+	cancelRun = cancel
+	runStart = time.Now()
+	monitorMu.Unlock()
+}
+
+// schedule returns when the run following the one started at t is due
+func schedule() (func(t time.Time) time.Time, error) {
+	if *monitorCron == "" {
+		return func(t time.Time) time.Time { return t.Add(*monitorInterval) }, nil
+	}
+	c, err := parseCron(*monitorCron)
+	if err != nil {
+		return nil, err
+	}
+	return c.next, nil
+}
+
+func runMonitor(args []string) error {
+	if *domainList == "" {
+		return fmt.Errorf("missing required domain list")
+	}
+	next, err := schedule()
+	if err != nil {
+		return err
+	}
+
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", serveMetrics)
+		go serve("metrics", *metricsAddr, mux)
+	}
+	if *apiAddr != "" {
+		go serve("api", *apiAddr, apiHandler())
+	}
+	if *grpcAddr != "" {
+		go serveGRPC(*grpcAddr)
+	}
+
+	getBanner(sendingDelay, retryDelay, clientLabel())
+	if *preflight && !*iterative {
+		if err := checkHealth(); err != nil {
+			return err
+		}
+	}
+	runProbes()
+
+	at := time.Now()
+	if *monitorCron != "" {
+		at = next(at)
+	}
+	for {
+		monitorMu.Lock()
+		nextRun = at
+		monitorMu.Unlock()
+
+		if d := time.Until(at); d > 0 {
+			fmt.Printf("[+] Next run:         %v\n", at.Format(time.RFC3339))
+			select {
+			case <-time.After(d):
+			case <-runNow:
+			}
+		}
+		start := time.Now()
+
+		// a run that cannot start is skipped, the next one may well succeed
+		if completed, err := runBenchmark(); err != nil {
+			fmt.Fprintf(os.Stderr, "run: %s\n", err)
+		} else {
+			finalStats()
+
+			s := summarize(completed)
+			if err := appendHistory(s); err != nil {
+				fmt.Fprintf(os.Stderr, "history: %s\n", err)
+			}
+			notifyRun(s)
+			reportThresholds(s)
+			reportSLO(s)
+			monitorMu.Lock()
+			monitorRuns++
+			lastRun = &s
+			monitorMu.Unlock()
+		}
+
+		at = next(start)
+		for !at.IsZero() && !at.After(time.Now()) {
+			at = next(at)
+		}
+		if at.IsZero() {
+			return fmt.Errorf("schedule %q has no further runs", *monitorCron)
+		}
+	}
+}
+
+func serve(name, addr string, h http.Handler) {
+	if err := http.ListenAndServe(addr, h); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// serveMetrics exposes the most recent run in the Prometheus text format
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	monitorMu.Lock()
+	defer monitorMu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# TYPE dns_client_runs_total counter\n"+
+		"dns_client_runs_total %d\n", monitorRuns)
+	if lastRun == nil {
+		return
+	}
+
+	writeRunMetrics(w, lastRun)
+}
+
+// writeRunMetrics renders the statistics of a run as Prometheus gauges
+func writeRunMetrics(w io.Writer, s *runSummary) {
+	completed := 0
+	if s.Completed {
+		completed = 1
+	}
+	for _, g := range []struct {
+		name  string
+		value float64
+	}{
+		{"last_run_timestamp_seconds", float64(s.Time.Unix())},
+		{"last_run_completed", float64(completed)},
+		{"last_run_attempts", float64(s.Attempts)},
+		{"last_run_success", float64(s.Success)},
+		{"last_run_fail", float64(s.Fail)},
+		{"last_run_rate", s.AvgRate},
+		{"last_run_elapsed_seconds", s.Elapsed},
+	} {
+		fmt.Fprintf(w, "# TYPE dns_client_%v gauge\ndns_client_%v %v\n", g.name, g.name, g.value)
+	}
+
+	fmt.Fprintf(w, "# TYPE dns_client_last_run_rcode gauge\n")
+	for _, k := range sortedCounts(s.Rcodes) {
+		fmt.Fprintf(w, "dns_client_last_run_rcode{rcode=%q} %d\n", k, s.Rcodes[k])
+	}
+	fmt.Fprintf(w, "# TYPE dns_client_last_run_errors gauge\n")
+	for c := errorClass(0); c < numErrorClasses; c++ {
+		fmt.Fprintf(w, "dns_client_last_run_errors{class=%q} %d\n", c.String(), s.Errors[c.String()])
+	}
+}
+
+func sortedCounts(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
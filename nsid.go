@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/rtmoranorg/dns-client-subnet-ext/graph"
+)
+
+var (
+	nsidOn = flag.Bool("nsid", false, "Ask for the NSID of the answering server and map which site serves which client subnet")
+)
+
+// catchment counts the responses of every client subnet by the NSID of the
+// site that answered them
+var catchment map[string]map[string]int
+
+// nsidOption asks for the identifier of the answering server
+func nsidOption() *dns.EDNS0_NSID {
+	return &dns.EDNS0_NSID{Code: dns.EDNS0NSID}
+}
+
+// responseNSID returns the NSID of msg, readable ones as text and any
+// other as hex
+func responseNSID(msg *dns.Msg) string {
+	o := msg.IsEdns0()
+	if o == nil {
+		return ""
+	}
+	for _, e := range o.Option {
+		n, ok := e.(*dns.EDNS0_NSID)
+		if !ok {
+			continue
+		}
+		b, err := hex.DecodeString(n.Nsid)
+		if err != nil {
+			return n.Nsid
+		}
+		for _, c := range b {
+			if c < 0x20 || c > 0x7e {
+				return n.Nsid
+			}
+		}
+		return string(b)
+	}
+	return ""
+}
+
+func recordNSID(subnet string, msg *dns.Msg) {
+	if !*nsidOn {
+		return
+	}
+	site := responseNSID(msg)
+	if site == "" {
+		site = "none"
+	}
+	if subnet == "" {
+		subnet = "disabled"
+	}
+	if catchment[subnet] == nil {
+		catchment[subnet] = make(map[string]int)
+	}
+	catchment[subnet][site]++
+}
+
+// mergeCatchment adds the catchment of another run to into
+func mergeCatchment(into, from map[string]map[string]int) {
+	for subnet, sites := range from {
+		if into[subnet] == nil {
+			into[subnet] = make(map[string]int)
+		}
+		for s, c := range sites {
+			into[subnet][s] += c
+		}
+	}
+}
+
+// catchmentStats lists the sites answering every subnet, an anycast
+// resolver routing a subnet to several sites shows them side by side. The
+// graph counts the responses per site across all subnets.
+func catchmentStats(name string, clientStatus bool, c map[string]map[string]int) {
+	if len(c) == 0 {
+		return
+	}
+	subnets := make([]string, 0, len(c))
+	perSite := make(map[string]int)
+	for s, sites := range c {
+		subnets = append(subnets, s)
+		for site, n := range sites {
+			perSite[site] += n
+		}
+	}
+	sort.Strings(subnets)
+
+	fmt.Printf("[+] NSID Catchment:   %v sites\n", len(perSite))
+	for _, s := range subnets {
+		fmt.Printf("[+]   %-20s%v\n", s, siteCounts(c[s]))
+	}
+
+	sites := sortedSites(perSite)
+	counts := make([]int, 0, len(sites))
+	for _, s := range sites {
+		counts = append(counts, perSite[s])
+	}
+	graph.BuildCatchmentGraph(name, clientStatus, sites, counts, *outputDir)
+}
+
+func sortedSites(sites map[string]int) []string {
+	s := make([]string, 0, len(sites))
+	for site := range sites {
+		s = append(s, site)
+	}
+	sort.Slice(s, func(i, j int) bool {
+		if sites[s[i]] != sites[s[j]] {
+			return sites[s[i]] > sites[s[j]]
+		}
+		return s[i] < s[j]
+	})
+	return s
+}
+
+func siteCounts(sites map[string]int) string {
+	s := sortedSites(sites)
+	for i, site := range s {
+		s[i] = fmt.Sprintf("%v (%v)", site, sites[site])
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var (
+	force = flag.Bool("force", false, "Overwrite output files that already exist instead of refusing to")
+)
+
+// createExclusive creates name unless it exists, a file of an earlier run
+// is only replaced with -force
+func createExclusive(name string) (*os.File, error) {
+	flags := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if !*force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(name, flags, 0644)
+	if os.IsExist(err) {
+		return nil, fmt.Errorf("refusing to overwrite %v, use -force", name)
+	}
+	return f, err
+}
+
+// createOutputFile opens a new file next to the graphs of this run, kind
+// names the content and ext its format
+func createOutputFile(kind, ext string) (*os.File, error) {
+	dir := filepath.Join(*outputDir, *nameserver)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	f, err := createExclusive(filepath.Join(dir, fmt.Sprintf("ns-%v_client-%v_%v_%v.%v",
+		*nameserver, len(*client) != 0, kind, currentRunID(), ext)))
+	if err != nil {
+		return nil, err
+	}
+	writeMeta(f, ext)
+	return f, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var (
+	pins = flag.String("pin", "", "Comma separated base64 SHA-256 pins of the DoT or DoH server, spki:HASH for the public key or cert:HASH for the whole certificate")
+)
+
+type pin struct {
+	cert bool
+	hash [sha256.Size]byte
+}
+
+var pinSet []pin
+
+// pinError is returned from the handshake when no presented certificate
+// matches a pin, it carries the leaf's hashes so a stale pin can be
+// told from an intercepted connection
+type pinError struct {
+	spki string
+	cert string
+}
+
+func (e *pinError) Error() string {
+	return fmt.Sprintf("certificate matches no pin (presented spki:%v cert:%v)", e.spki, e.cert)
+}
+
+func parsePins(s string) ([]pin, error) {
+	var set []pin
+	for _, f := range strings.Split(s, ",") {
+		i := strings.Index(f, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("pin %q lacks spki: or cert: prefix", f)
+		}
+		var p pin
+		switch f[:i] {
+		case "spki":
+		case "cert":
+			p.cert = true
+		default:
+			return nil, fmt.Errorf("unknown pin kind %v", f[:i])
+		}
+		b, err := base64.StdEncoding.DecodeString(f[i+1:])
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("pin %q is not a base64 SHA-256 hash", f)
+		}
+		copy(p.hash[:], b)
+		set = append(set, p)
+	}
+	return set, nil
+}
+
+// verifyPins runs after the usual chain verification, any certificate of
+// the presented chain matching a pin accepts it the way HPKP did
+func verifyPins(raw [][]byte, _ [][]*x509.Certificate) error {
+	if len(pinSet) == 0 {
+		return nil
+	}
+	for _, der := range raw {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			continue
+		}
+		spki := sha256.Sum256(c.RawSubjectPublicKeyInfo)
+		cert := sha256.Sum256(der)
+		for _, p := range pinSet {
+			if (p.cert && p.hash == cert) || (!p.cert && p.hash == spki) {
+				return nil
+			}
+		}
+	}
+	e := &pinError{}
+	if len(raw) > 0 {
+		if c, err := x509.ParseCertificate(raw[0]); err == nil {
+			spki, cert := sha256.Sum256(c.RawSubjectPublicKeyInfo), sha256.Sum256(raw[0])
+			e.spki = base64.StdEncoding.EncodeToString(spki[:])
+			e.cert = base64.StdEncoding.EncodeToString(cert[:])
+		}
+	}
+	return e
+}
+
+// dialError tells pin failures apart from other connection errors
+func dialError(err error) errorClass {
+	var pe *pinError
+	if errors.As(err, &pe) {
+		return errPin
+	}
+	return errNetwork
+}
+
+func setupPins() error {
+	if *pins == "" {
+		return nil
+	}
+	if *transport != "dot" && !strings.HasPrefix(*dohURL, "https:") {
+		return fmt.Errorf("pins need -transport dot or an https -doh endpoint")
+	}
+	var err error
+	pinSet, err = parsePins(*pins)
+	return err
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	pmtuNames = flag.String("pmtu-names", ".:DNSKEY,com.:DNSKEY,org.:DNSKEY", "Comma separated name:TYPE records with large answers the pmtu command asks for")
+)
+
+// pmtuSizes are the EDNS buffer sizes advertised in turn, 1232 and 1472
+// fit unfragmented into an IPv6 minimum and an Ethernet MTU respectively
+var pmtuSizes = []uint16{512, 1232, 1400, 1472, 2048, 4096}
+
+type pmtuProbe struct {
+	name  string
+	qtype uint16
+}
+
+func (p pmtuProbe) String() string {
+	return p.name + ":" + dns.TypeToString[p.qtype]
+}
+
+func parsePMTUNames(s string) ([]pmtuProbe, error) {
+	var probes []pmtuProbe
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		p := pmtuProbe{name: f, qtype: dns.TypeTXT}
+		if i := strings.LastIndex(f, ":"); i > 0 {
+			t, ok := dns.StringToType[strings.ToUpper(f[i+1:])]
+			if !ok {
+				return nil, fmt.Errorf("unknown type %v", f[i+1:])
+			}
+			p.name, p.qtype = f[:i], t
+		}
+		p.name = dns.Fqdn(p.name)
+		probes = append(probes, p)
+	}
+	if len(probes) == 0 {
+		return nil, fmt.Errorf("no probe names")
+	}
+	return probes, nil
+}
+
+// pmtuPath collects what got through to the client, answers are expected
+// to arrive whole whenever they fit the advertised size and truncated
+// otherwise. Losing them instead points to fragments dropped on the path.
+type pmtuPath struct {
+	largest   int
+	truncated int
+	lost      int
+}
+
+func (p *pmtuPath) received(n int) {
+	if n > p.largest {
+		p.largest = n
+	}
+}
+
+// probeUDP asks for p with the given EDNS buffer size without falling back
+// to TCP, returning the size of the UDP response
+func probeUDP(addr string, p pmtuProbe, size uint16) (*dns.Msg, int, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(p.name, p.qtype)
+	m.SetEdns0(size, true)
+
+	c := &dns.Client{Timeout: retryDelay}
+	r, _, err := c.Exchange(m, addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	return r, r.Len(), nil
+}
+
+// fullSize is the size of the complete answer as given over TCP
+func fullSize(addr string, p pmtuProbe) int {
+	m := new(dns.Msg)
+	m.SetQuestion(p.name, p.qtype)
+	m.SetEdns0(dns.MaxMsgSize, true)
+
+	c := &dns.Client{Net: "tcp", Timeout: retryDelay}
+	r, _, err := c.Exchange(m, addr)
+	if err != nil {
+		return 0
+	}
+	return r.Len()
+}
+
+// runPMTU elicits larger and larger responses from every nameserver to
+// find the size at which answers stop reaching the client over UDP
+func runPMTU(args []string) error {
+	probes, err := parsePMTUNames(*pmtuNames)
+	if err != nil {
+		return fmt.Errorf("pmtu-names: %v", err)
+	}
+	servers := args
+	if len(servers) == 0 {
+		servers = []string{*nameserver}
+	}
+
+	for _, ns := range servers {
+		addr := net.JoinHostPort(ns, "53")
+		fmt.Printf("Path MTU Probe\n"+
+			"[+] Nameserver:       %v\n"+
+			"[+] Probes:           %v\n\n", ns, len(probes))
+
+		path := pmtuPath{lost: -1}
+		for _, p := range probes {
+			full := fullSize(addr, p)
+			fmt.Printf("[+] %v (%v bytes over TCP)\n", p, full)
+
+			for _, size := range pmtuSizes {
+				r, n, err := probeUDP(addr, p, size)
+				expect := full
+				if expect == 0 || expect > int(size) {
+					expect = int(size)
+				}
+				switch {
+				case err != nil:
+					fmt.Printf("[-]   %-16v%v\n", size, err)
+					if path.lost < 0 || expect < path.lost {
+						path.lost = expect
+					}
+				case r.Truncated:
+					fmt.Printf("[+]   %-16vtruncated at %v bytes\n", size, n)
+					path.truncated++
+					path.received(n)
+				default:
+					fmt.Printf("[+]   %-16v%v bytes\n", size, n)
+					path.received(n)
+				}
+			}
+		}
+
+		fmt.Printf("\n[+] Largest Received: %v bytes\n", path.largest)
+		fmt.Printf("[+] Truncated:        %v of %v\n", path.truncated, len(probes)*len(pmtuSizes))
+		if path.lost >= 0 {
+			fmt.Printf("[-] Lost From:        ~%v bytes\n", path.lost)
+		} else {
+			fmt.Printf("[+] Lost From:        none\n")
+		}
+		fmt.Println()
+	}
+	return nil
+}
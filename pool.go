@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	maxInflight = flag.Int("max-inflight", 0, "Most queries awaiting an answer on one stream connection, 0 for no limit")
+)
+
+// poolStats describes how the stream connections of a run were used
+var poolStats struct {
+	sync.Mutex
+	perConn      []int
+	reconnects   int
+	serverCloses int
+}
+
+// pooledConn is a per-worker stream connection that is redialed when the
+// server closes it, -sockets sets how many make up the pool. Writes wait
+// while -max-inflight queries are unanswered, a query owed for longer
+// than the retry delay no longer counts as it will be retried anyway.
+type pooledConn struct {
+	addr string
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	c       net.Conn
+	queries int
+	owed    []time.Time
+	closed  bool
+}
+
+func newPooledConn(addr string) (*pooledConn, error) {
+	c, err := dialNameserver(addr)
+	if err != nil {
+		return nil, err
+	}
+	p := &pooledConn{addr: addr, c: c}
+	p.cond = sync.NewCond(&p.mu)
+	return p, nil
+}
+
+// retire records the queries carried by the current connection, the
+// caller holds mu
+func (p *pooledConn) retire() {
+	poolStats.Lock()
+	poolStats.perConn = append(poolStats.perConn, p.queries)
+	poolStats.Unlock()
+	p.c.Close()
+	p.c, p.queries, p.owed = nil, 0, nil
+}
+
+// waitSlot blocks until the connection can take another query, the caller
+// holds mu
+func (p *pooledConn) waitSlot() {
+	for *maxInflight > 0 && len(p.owed) >= *maxInflight {
+		if time.Since(p.owed[0]) >= retryDelay {
+			p.owed = p.owed[1:]
+			continue
+		}
+		p.mu.Unlock()
+		time.Sleep(time.Millisecond)
+		p.mu.Lock()
+	}
+}
+
+func (p *pooledConn) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if p.c == nil {
+		c, err := dialNameserver(p.addr)
+		if err != nil {
+			return 0, err
+		}
+		p.c = c
+		poolStats.Lock()
+		poolStats.reconnects++
+		poolStats.Unlock()
+		p.cond.Broadcast()
+	}
+	p.waitSlot()
+	n, err := p.c.Write(b)
+	if err == nil {
+		p.queries++
+		p.owed = append(p.owed, time.Now())
+	}
+	return n, err
+}
+
+// Read returns the next answer of whichever connection is current, when
+// the server closes one it waits for the writer to dial the next
+func (p *pooledConn) Read(b []byte) (int, error) {
+	for {
+		p.mu.Lock()
+		for p.c == nil && !p.closed {
+			p.cond.Wait()
+		}
+		if p.closed {
+			p.mu.Unlock()
+			return 0, io.EOF
+		}
+		c := p.c
+		p.mu.Unlock()
+
+		n, err := c.Read(b)
+		p.mu.Lock()
+		switch {
+		case err == nil:
+			if len(p.owed) > 0 {
+				p.owed = p.owed[1:]
+			}
+			p.mu.Unlock()
+			return n, nil
+		case p.closed:
+			p.mu.Unlock()
+			return 0, io.EOF
+		case p.c == c:
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				p.mu.Unlock()
+				return 0, err
+			}
+			poolStats.Lock()
+			poolStats.serverCloses++
+			poolStats.Unlock()
+			p.retire()
+		}
+		p.mu.Unlock()
+	}
+}
+
+func (p *pooledConn) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if p.c != nil {
+		p.retire()
+	}
+	p.cond.Broadcast()
+	return nil
+}
+
+func (p *pooledConn) conn() net.Conn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.c
+}
+
+func (p *pooledConn) LocalAddr() net.Addr {
+	if c := p.conn(); c != nil {
+		return c.LocalAddr()
+	}
+	return nil
+}
+
+func (p *pooledConn) RemoteAddr() net.Addr {
+	if c := p.conn(); c != nil {
+		return c.RemoteAddr()
+	}
+	return nil
+}
+
+func (p *pooledConn) SetDeadline(t time.Time) error {
+	if c := p.conn(); c != nil {
+		return c.SetDeadline(t)
+	}
+	return nil
+}
+
+func (p *pooledConn) SetReadDeadline(t time.Time) error {
+	if c := p.conn(); c != nil {
+		return c.SetReadDeadline(t)
+	}
+	return nil
+}
+
+func (p *pooledConn) SetWriteDeadline(t time.Time) error {
+	if c := p.conn(); c != nil {
+		return c.SetWriteDeadline(t)
+	}
+	return nil
+}
+
+func resetPool() {
+	poolStats.Lock()
+	poolStats.perConn, poolStats.reconnects, poolStats.serverCloses = nil, 0, 0
+	poolStats.Unlock()
+}
+
+func poolReport() {
+	poolStats.Lock()
+	defer poolStats.Unlock()
+	if len(poolStats.perConn) == 0 {
+		return
+	}
+	total, most := 0, 0
+	for _, n := range poolStats.perConn {
+		total += n
+		if n > most {
+			most = n
+		}
+	}
+	fmt.Printf("[+] Stream Pool:      %v connections\n", querySockets())
+	fmt.Printf("[+]   %-16s%.1f (max %v)\n", "Queries/Conn:",
+		float64(total)/float64(len(poolStats.perConn)), most)
+	fmt.Printf("[+]   %-16s%v\n", "Reconnects:", poolStats.reconnects)
+	fmt.Printf("[+]   %-16s%v\n", "Server Closes:", poolStats.serverCloses)
+	if *maxInflight > 0 {
+		fmt.Printf("[+]   %-16s%v\n", "Max In-flight:", *maxInflight)
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// qminTestDomain answers differently depending on whether the querying
+// resolver sends the full QNAME to every server in the delegation chain
+const qminTestDomain = "qnamemintest.internet.nl."
+
+var (
+	probeQmin  = flag.Bool("qmin", false, "Probe nameserver for QNAME minimization before the run")
+	probeChaos = flag.Bool("chaos", false, "Query CHAOS version and identity names before the run")
+	probeFp    = flag.Bool("fingerprint", false, "Guess nameserver software from responses to crafted queries")
+	preflight  = flag.Bool("preflight", true, "Verify nameserver responds before starting the run")
+	probeNx    = flag.Bool("nxcheck", false, "Check whether the nameserver rewrites NXDOMAIN for random names")
+	probeIcpt  = flag.Bool("intercept", false, "Check whether port 53 traffic is transparently intercepted")
+)
+
+type finding struct {
+	name  string
+	value string
+}
+
+var findings []finding
+
+// serverInfo is what the probes learned about the nameserver itself, it
+// goes into the metadata of every run made afterwards
+type serverInfo struct {
+	Chaos       map[string]string `json:"chaos,omitempty"`
+	Software    string            `json:"software,omitempty"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
+}
+
+var probedServer serverInfo
+
+// chaosNames are queried in the CHAOS class for the server's identity
+var chaosNames = []string{"version.bind", "hostname.bind", "id.server"}
+
+func addFinding(name, format string, a ...interface{}) {
+	findings = append(findings, finding{name, fmt.Sprintf(format, a...)})
+}
+
+func nameserverAddr() string {
+	if nsAddr != "" {
+		return nsAddr
+	}
+	return net.JoinHostPort(*nameserver, "53")
+}
+
+// exchange performs a single synchronous query against the nameserver
+func exchange(name string, qtype, qclass uint16) (*dns.Msg, time.Duration, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.Question[0].Qclass = qclass
+
+	return exchangeMsg(m)
+}
+
+func exchangeMsg(m *dns.Msg) (*dns.Msg, time.Duration, error) {
+	if *transport != "udp" {
+		return exchangeStream(m, nameserverAddr())
+	}
+	return exchangeAt(m, nameserverAddr())
+}
+
+// exchangeAt sends m to addr, retrying over TCP when the answer was
+// truncated
+func exchangeAt(m *dns.Msg, addr string) (*dns.Msg, time.Duration, error) {
+	c := &dns.Client{Timeout: retryDelay}
+	if tsig != nil {
+		c.TsigSecret = tsig.secrets()
+		tsig.sign(m)
+	}
+	r, rtt, err := c.Exchange(m, addr)
+	if err == nil && r.Truncated {
+		c.Net = "tcp"
+		return c.Exchange(m, addr)
+	}
+	return r, rtt, err
+}
+
+// checkHealth verifies the nameserver answers at all before any load is
+// generated, recursion and ECS support are only reported since
+// authoritative servers are legitimate targets too
+func checkHealth() error {
+	m := new(dns.Msg)
+	m.SetQuestion(".", dns.TypeNS)
+	if *client != "" {
+		m.Extra = append(m.Extra, setupOptions(*client))
+	}
+
+	r, rtt, err := exchangeMsg(m)
+	if err != nil {
+		return fmt.Errorf("nameserver %v not responding: %v", *nameserver, err)
+	}
+	addFinding("Preflight RTT:", "%v (%v)", rtt, dns.RcodeToString[r.Rcode])
+
+	if !r.RecursionAvailable {
+		fmt.Fprintf(os.Stderr, "warning: nameserver %v does not offer recursion\n", *nameserver)
+	}
+	addFinding("Recursion:", "%v", r.RecursionAvailable)
+
+	if *client != "" {
+		ecs := false
+		if o := r.IsEdns0(); o != nil {
+			for _, e := range o.Option {
+				if _, ok := e.(*dns.EDNS0_SUBNET); ok {
+					ecs = true
+				}
+			}
+		}
+		if !ecs {
+			fmt.Fprintf(os.Stderr, "warning: nameserver %v ignored the client subnet option\n", *nameserver)
+		}
+		addFinding("ECS Supported:", "%v", ecs)
+	}
+	return nil
+}
+
+func runProbes() {
+	if *probeQmin {
+		addFinding("QNAME Minimizing:", "%v", detectQmin())
+	}
+	if *probeChaos {
+		probedServer.Chaos = make(map[string]string)
+		for _, n := range chaosNames {
+			txt, ok := queryChaos(n)
+			if ok {
+				probedServer.Chaos[n] = txt
+			}
+			addFinding(n+".", "%v", txt)
+		}
+	}
+	if *probeNx {
+		addFinding("NXDOMAIN Hijack:", "%v", detectNxHijack())
+	}
+	if *probeIcpt {
+		intercepted, egress := detectInterception()
+		addFinding("Intercepted:", "%v", intercepted)
+		addFinding("Resolver Egress:", "%v", egress)
+	}
+	if *probeFp {
+		impl, sig := fingerprint()
+		probedServer.Software, probedServer.Fingerprint = impl, sig
+		addFinding("Server Software:", "%v", impl)
+		addFinding("Fingerprint:", "%v", sig)
+	}
+}
+
+// detectQmin queries the internet.nl test zone, whose TXT answer reports
+// whether the resolver minimized the names it sent to the authoritatives
+func detectQmin() string {
+	r, _, err := exchange(qminTestDomain, dns.TypeTXT, dns.ClassINET)
+	if err != nil {
+		return fmt.Sprintf("unknown (%v)", err)
+	}
+
+	for _, a := range r.Answer {
+		t, ok := a.(*dns.TXT)
+		if !ok {
+			continue
+		}
+		txt := strings.Join(t.Txt, "")
+		switch {
+		case strings.HasPrefix(txt, "HOORAY"):
+			return "enabled"
+		case strings.HasPrefix(txt, "NO"):
+			return "disabled"
+		}
+	}
+	return fmt.Sprintf("unknown (%v)", dns.RcodeToString[r.Rcode])
+}
+
+// queryChaos returns the CH/TXT answer for name, servers commonly expose
+// their software version and site identity under these names. Without an
+// answer it describes why and returns false.
+func queryChaos(name string) (string, bool) {
+	r, _, err := exchange(name, dns.TypeTXT, dns.ClassCHAOS)
+	if err != nil {
+		return fmt.Sprintf("unknown (%v)", err), false
+	}
+
+	for _, a := range r.Answer {
+		if t, ok := a.(*dns.TXT); ok {
+			return strings.Join(t.Txt, " "), true
+		}
+	}
+	return fmt.Sprintf("unavailable (%v)", dns.RcodeToString[r.Rcode]), false
+}
+
+type fpProbe struct {
+	name   string
+	opcode int
+	qname  string
+	qtype  uint16
+	qclass uint16
+}
+
+// fpProbes are sent in order and their response codes concatenated into
+// the signature. Implementation specific CHAOS names and the handling of
+// unusual opcodes differ enough between servers to tell them apart.
+var fpProbes = []fpProbe{
+	{"iquery", dns.OpcodeIQuery, ".", dns.TypeA, dns.ClassINET},
+	{"notify", dns.OpcodeNotify, ".", dns.TypeSOA, dns.ClassINET},
+	{"status", dns.OpcodeStatus, ".", dns.TypeA, dns.ClassINET},
+	{"authors.bind", dns.OpcodeQuery, "authors.bind.", dns.TypeTXT, dns.ClassCHAOS},
+	{"version.pdns", dns.OpcodeQuery, "version.pdns.", dns.TypeTXT, dns.ClassCHAOS},
+	{"version.server", dns.OpcodeQuery, "version.server.", dns.TypeTXT, dns.ClassCHAOS},
+	{"version.bind", dns.OpcodeQuery, "version.bind.", dns.TypeTXT, dns.ClassCHAOS},
+}
+
+// fpKeywords match the version strings servers volunteer about themselves
+var fpKeywords = []struct {
+	keyword string
+	impl    string
+}{
+	{"powerdns", "PowerDNS"},
+	{"unbound", "Unbound"},
+	{"knot", "Knot"},
+	{"bind", "BIND"},
+	{"dnsmasq", "dnsmasq"},
+}
+
+// fingerprint returns a best guess of the server implementation along with
+// the raw signature it was derived from
+func fingerprint() (string, string) {
+	sig := make([]string, 0, len(fpProbes))
+	rcodes := make(map[string]int)
+	var versions []string
+
+	for _, p := range fpProbes {
+		m := new(dns.Msg)
+		m.SetQuestion(p.qname, p.qtype)
+		m.Question[0].Qclass = p.qclass
+		m.Opcode = p.opcode
+
+		r, _, err := exchangeMsg(m)
+		if err != nil {
+			sig = append(sig, p.name+"=timeout")
+			rcodes[p.name] = -1
+			continue
+		}
+		sig = append(sig, fmt.Sprintf("%v=%v", p.name, dns.RcodeToString[r.Rcode]))
+		rcodes[p.name] = r.Rcode
+
+		for _, a := range r.Answer {
+			if t, ok := a.(*dns.TXT); ok {
+				versions = append(versions, strings.ToLower(strings.Join(t.Txt, " ")))
+			}
+		}
+	}
+	signature := strings.Join(sig, ",")
+
+	for _, k := range fpKeywords {
+		for _, v := range versions {
+			if strings.Contains(v, k.keyword) {
+				return k.impl, signature
+			}
+		}
+	}
+
+	switch {
+	case rcodes["version.pdns"] == dns.RcodeSuccess:
+		return "PowerDNS", signature
+	case rcodes["authors.bind"] == dns.RcodeSuccess:
+		return "BIND", signature
+	case rcodes["version.server"] == dns.RcodeSuccess &&
+		rcodes["version.bind"] == dns.RcodeSuccess:
+		return "Unbound", signature
+	}
+	return "unknown", signature
+}
+
+// nxSuffixes are the parents under which random names are generated, a
+// random TLD catches resolvers that only rewrite some zones
+var nxSuffixes = []string{"com.", "net.", "org.", ""}
+
+func randomLabel(n int) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = letters[rand.Intn(len(letters))]
+	}
+	return string(b)
+}
+
+// detectNxHijack queries names that cannot exist, any address in the
+// answer means the resolver substitutes its own records for NXDOMAIN
+func detectNxHijack() string {
+	var hijacked []string
+	checked := 0
+
+	for _, suffix := range nxSuffixes {
+		name := randomLabel(20) + "." + suffix
+		if suffix == "" {
+			name = randomLabel(20) + "." + randomLabel(12) + "."
+		}
+
+		r, _, err := exchange(name, dns.TypeA, dns.ClassINET)
+		if err != nil {
+			continue
+		}
+		checked++
+
+		for _, a := range r.Answer {
+			if t, ok := a.(*dns.A); ok {
+				hijacked = append(hijacked, fmt.Sprintf("%v -> %v", name, t.A))
+				break
+			}
+		}
+	}
+
+	switch {
+	case checked == 0:
+		return "unknown (no responses)"
+	case len(hijacked) > 0:
+		return fmt.Sprintf("yes (%v)", strings.Join(hijacked, ", "))
+	}
+	return fmt.Sprintf("no (%d of %d names)", checked, len(nxSuffixes))
+}
+
+// interceptSinks are reserved for documentation (RFC 5737) so no DNS
+// server can legitimately answer there
+var interceptSinks = []string{"192.0.2.1", "198.51.100.1", "203.0.113.1"}
+
+// whoamiNames are answered by their authoritatives with the address of the
+// resolver that asked
+var whoamiNames = []struct {
+	name  string
+	qtype uint16
+}{
+	{"o-o.myaddr.l.google.com.", dns.TypeTXT},
+	{"whoami.akamai.net.", dns.TypeA},
+}
+
+// detectInterception sends queries to addresses without a DNS server, an
+// answer can only come from a middlebox rewriting port 53 traffic. The
+// egress addresses reported by the whoami services show where answers for
+// the nameserver actually came from.
+func detectInterception() (string, string) {
+	m := new(dns.Msg)
+	m.SetQuestion(whoamiNames[1].name, whoamiNames[1].qtype)
+
+	intercepted := "no"
+	for _, sink := range interceptSinks {
+		if _, _, err := exchangeAt(m, net.JoinHostPort(sink, "53")); err == nil {
+			intercepted = fmt.Sprintf("yes (%v answered)", sink)
+			break
+		}
+	}
+
+	var egress []string
+	for _, w := range whoamiNames {
+		r, _, err := exchange(w.name, w.qtype, dns.ClassINET)
+		if err != nil {
+			continue
+		}
+		for _, a := range r.Answer {
+			switch t := a.(type) {
+			case *dns.A:
+				egress = append(egress, t.A.String())
+			case *dns.TXT:
+				egress = append(egress, strings.Join(t.Txt, " "))
+			}
+		}
+	}
+	if len(egress) == 0 {
+		return intercepted, "unknown"
+	}
+	return intercepted, strings.Join(egress, ", ")
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// promBuckets are the upper bounds in seconds of the query latency
+// histogram, from cache hits to answers waiting on slow authoritatives
+var promBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+var promClient = &http.Client{Timeout: 10 * time.Second}
+
+// prometheusSink exports the summary with a histogram of the latencies of
+// the answered domains. A URL is taken for a Pushgateway the metrics are
+// pushed to under the nameserver, anything else for the file of a
+// node_exporter textfile collector which is replaced atomically.
+type prometheusSink struct {
+	target  string
+	buckets []int
+	count   int
+	sum     float64
+}
+
+func newPrometheusSink(arg string) (OutputSink, error) {
+	return &prometheusSink{target: arg}, nil
+}
+
+func (s *prometheusSink) Name() string { return "prometheus" }
+
+func (s *prometheusSink) Open() error {
+	s.buckets, s.count, s.sum = make([]int, len(promBuckets)), 0, 0
+	return nil
+}
+
+func (s *prometheusSink) Record(r sinkRecord) error {
+	if r.Status != "ok" {
+		return nil
+	}
+	secs := r.Latency / 1000
+	for i, le := range promBuckets {
+		if secs <= le {
+			s.buckets[i]++
+		}
+	}
+	s.count++
+	s.sum += secs
+	return nil
+}
+
+func (s *prometheusSink) Summary(sum runSummary) error {
+	var b bytes.Buffer
+	writeRunMetrics(&b, &sum)
+	fmt.Fprintf(&b, "# TYPE dns_client_last_run_query_duration_seconds histogram\n")
+	for i, le := range promBuckets {
+		fmt.Fprintf(&b, "dns_client_last_run_query_duration_seconds_bucket{le=\"%v\"} %d\n", le, s.buckets[i])
+	}
+	fmt.Fprintf(&b, "dns_client_last_run_query_duration_seconds_bucket{le=\"+Inf\"} %d\n"+
+		"dns_client_last_run_query_duration_seconds_sum %v\n"+
+		"dns_client_last_run_query_duration_seconds_count %d\n", s.count, s.sum, s.count)
+
+	if strings.HasPrefix(s.target, "http://") || strings.HasPrefix(s.target, "https://") {
+		return s.push(b.Bytes())
+	}
+	return s.writeFile(b.Bytes())
+}
+
+// push replaces the metrics of the nameserver's group on the Pushgateway
+func (s *prometheusSink) push(metrics []byte) error {
+	u := strings.TrimRight(s.target, "/") + "/metrics/job/dns_client/nameserver/" + url.PathEscape(*nameserver)
+	req, err := http.NewRequest("PUT", u, bytes.NewReader(metrics))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := promClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway answered %v", resp.Status)
+	}
+	return nil
+}
+
+// writeFile renames a complete file into place so the collector never
+// reads a partial one
+func (s *prometheusSink) writeFile(metrics []byte) error {
+	path := s.target
+	if path == "" {
+		os.MkdirAll(*outputDir, os.ModePerm)
+		path = filepath.Join(*outputDir, "dns_client.prom")
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, metrics, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *prometheusSink) Close() error { return nil }
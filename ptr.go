@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	ptrRange = flag.String("ptr", "", "Query the PTR record of every address in CIDR instead of a domain list")
+)
+
+// ptrMaxBits bounds the sweep to 2^20 addresses, an IPv6 /64 would never
+// finish
+const ptrMaxBits = 20
+
+var ptrNamed int
+
+// ptrDomains returns the reverse names of every address in cidr
+func ptrDomains(cidr string) ([]string, error) {
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := ipnet.Mask.Size()
+	if bits-ones > ptrMaxBits {
+		return nil, fmt.Errorf("%v has more than %d addresses", cidr, 1<<ptrMaxBits)
+	}
+
+	ip = ip.Mask(ipnet.Mask)
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	names := make([]string, 0, 1<<uint(bits-ones))
+	for ; ipnet.Contains(ip); ip = nextIP(ip) {
+		rev, err := dns.ReverseAddr(ip.String())
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, strings.TrimSuffix(rev, "."))
+	}
+	return names, nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+func recordPTR(msg *dns.Msg) {
+	for _, a := range msg.Answer {
+		if _, ok := a.(*dns.PTR); ok {
+			ptrNamed++
+			return
+		}
+	}
+}
+
+// ptrStats reports how much of the swept range has reverse names
+func ptrStats() {
+	if *ptrRange == "" || stats.attempts == 0 {
+		return
+	}
+	fmt.Printf("[+] PTR Coverage:     %v of %v addresses (%.1f%%)\n", ptrNamed, stats.attempts,
+		float64(ptrNamed)/float64(stats.attempts)*100)
+}
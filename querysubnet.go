@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/rtmoranorg/dns-client-subnet-ext/graph"
+)
+
+var (
+	subnetMapFile = flag.String("subnet-map", "", "File of domain and client subnet pairs sending each domain with its own subnet, a second column in the domain list takes precedence")
+	randomSubnet  = flag.String("random-subnet", "", "Send every query with a random client subnet, from the public IPv4 space (v4) or the -subnets and -client-country ones (pool)")
+	randomPrefix  = flag.Int("random-prefix", 24, "Prefix length of the random -random-subnet v4 subnets")
+)
+
+var (
+	// subnetMap holds the subnet of every domain of -subnet-map
+	subnetMap map[string]string
+	// querySubnets holds the second column of the domain list by position,
+	// it is written before the first domain is handed out
+	querySubnets []string
+	// subnetPool holds the subnets -random-subnet pool draws from
+	subnetPool []string
+	// subnetsSent counts the queries of the run by the subnet they carried
+	subnetsSent map[string]int
+	// subnetLatencies holds the latencies of the answered queries by subnet
+	subnetLatencies map[string][]time.Duration
+)
+
+// subnetChartMin is the number of answers a subnet needs to get a box in
+// the chart, subnetChartMax the number of boxes that still fit
+const (
+	subnetChartMin = 5
+	subnetChartMax = 12
+)
+
+// parseSubnet accepts an address or a CIDR prefix
+func parseSubnet(s string) error {
+	if _, _, err := net.ParseCIDR(s); err == nil {
+		return nil
+	}
+	if net.ParseIP(s) == nil {
+		return fmt.Errorf("%q is neither an address nor a prefix", s)
+	}
+	return nil
+}
+
+func loadSubnetMap(n string) error {
+	f, err := os.Open(n)
+	if err != nil {
+		return fmt.Errorf("Failed to open subnet map")
+	}
+	defer f.Close()
+
+	subnetMap = make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return fmt.Errorf("line %d: expected domain and subnet", line)
+		}
+		if err := parseSubnet(fields[1]); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		subnetMap[strings.ToLower(dns.Fqdn(fields[0]))] = fields[1]
+	}
+	return scanner.Err()
+}
+
+// splitDomains separates the optional subnet column from the names of the
+// domain list
+func splitDomains(in []string) ([]string, []string, error) {
+	names := make([]string, len(in))
+	var subnets []string
+	for i, l := range in {
+		fields := strings.Fields(l)
+		if len(fields) < 2 {
+			names[i] = strings.TrimSpace(l)
+			continue
+		}
+		if err := parseSubnet(fields[1]); err != nil {
+			return nil, nil, fmt.Errorf("domain %d: %v", i+1, err)
+		}
+		if subnets == nil {
+			subnets = make([]string, len(in))
+		}
+		names[i], subnets[i] = fields[0], fields[1]
+	}
+	return names, subnets, nil
+}
+
+// pickSubnet returns the client subnet the nth domain of the run is sent
+// with
+func pickSubnet(n int, domain string) string {
+	s := *client
+	if n < len(querySubnets) && querySubnets[n] != "" {
+		s = querySubnets[n]
+	} else if m, ok := subnetMap[strings.ToLower(domain)]; ok {
+		s = m
+	} else if *randomSubnet != "" {
+		s = randomClientSubnet()
+	}
+	if s != "" {
+		subnetsSent[s]++
+	}
+	return s
+}
+
+// bogons are never routed on the internet, a subnet out of them tells a
+// resolver nothing about where the client is
+var bogons = parseNets("0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8",
+	"169.254.0.0/16", "172.16.0.0/12", "192.0.0.0/24", "192.0.2.0/24", "192.168.0.0/16",
+	"198.18.0.0/15", "198.51.100.0/24", "203.0.113.0/24", "224.0.0.0/4", "240.0.0.0/4")
+
+func parseNets(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, _ := net.ParseCIDR(c)
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+func isBogon(ip net.IP) bool {
+	for _, n := range bogons {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func randomClientSubnet() string {
+	if *randomSubnet == "pool" {
+		return subnetPool[rand.Intn(len(subnetPool))]
+	}
+	mask := net.CIDRMask(*randomPrefix, 32)
+	for {
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, rand.Uint32())
+		if !isBogon(ip) {
+			return fmt.Sprintf("%v/%d", ip.Mask(mask), *randomPrefix)
+		}
+	}
+}
+
+// setupRandomSubnet collects the pool after -client-country was resolved
+func setupRandomSubnet() error {
+	switch *randomSubnet {
+	case "":
+	case "v4":
+		if *randomPrefix < 8 || *randomPrefix > 32 {
+			return fmt.Errorf("-random-prefix %d out of range 8-32", *randomPrefix)
+		}
+	case "pool":
+		subnetPool = nil
+		if *compareSubnets != "" {
+			for _, s := range strings.Split(*compareSubnets, ",") {
+				subnetPool = append(subnetPool, strings.TrimSpace(s))
+			}
+		}
+		subnetPool = append(subnetPool, countrySubnets...)
+		if len(subnetPool) == 0 {
+			return fmt.Errorf("pool needs -subnets or -client-country")
+		}
+		for _, s := range subnetPool {
+			if err := parseSubnet(s); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown mode %v", *randomSubnet)
+	}
+	return nil
+}
+
+func recordSubnetLatency(subnet string, latency time.Duration) {
+	if subnet != "" {
+		subnetLatencies[subnet] = append(subnetLatencies[subnet], latency)
+	}
+}
+
+func resetSubnets() {
+	querySubnets = nil
+	subnetsSent = make(map[string]int)
+	subnetLatencies = make(map[string][]time.Duration)
+}
+
+func subnetStats() {
+	if querySubnets == nil && subnetMap == nil && *randomSubnet == "" {
+		return
+	}
+	fmt.Printf("[+] Query Subnets:    %v distinct\n", len(subnetsSent))
+
+	subnets := make([]string, 0, len(subnetsSent))
+	for s := range subnetsSent {
+		subnets = append(subnets, s)
+	}
+	sort.Slice(subnets, func(i, j int) bool {
+		if subnetsSent[subnets[i]] != subnetsSent[subnets[j]] {
+			return subnetsSent[subnets[i]] > subnetsSent[subnets[j]]
+		}
+		return subnets[i] < subnets[j]
+	})
+	if *topN > 0 && len(subnets) > *topN {
+		subnets = subnets[:*topN]
+	}
+	for _, s := range subnets {
+		fmt.Printf("[+]   %v (%v)\n", s, subnetsSent[s])
+	}
+	subnetLatencyStats()
+}
+
+// subnetLatencyStats sets the latencies of the most used subnets side by
+// side, subnets with too few answers for a distribution are left out
+func subnetLatencyStats() {
+	subnets := make([]string, 0, len(subnetLatencies))
+	for s, l := range subnetLatencies {
+		if len(l) >= subnetChartMin {
+			subnets = append(subnets, s)
+		}
+	}
+	if len(subnets) < 2 {
+		return
+	}
+	sort.Slice(subnets, func(i, j int) bool {
+		if len(subnetLatencies[subnets[i]]) != len(subnetLatencies[subnets[j]]) {
+			return len(subnetLatencies[subnets[i]]) > len(subnetLatencies[subnets[j]])
+		}
+		return subnets[i] < subnets[j]
+	})
+	if len(subnets) > subnetChartMax {
+		subnets = subnets[:subnetChartMax]
+	}
+
+	fmt.Printf("[+] Subnet Latency:\n")
+	samples := make([][]float64, 0, len(subnets))
+	for _, s := range subnets {
+		l := subnetLatencies[s]
+		fmt.Printf("[+]   %-20sp50 %v, p95 %v\n", s, formatMs(percentileOf(l, 50)), formatMs(percentileOf(l, 95)))
+
+		ms := make([]float64, len(l))
+		for i, d := range l {
+			ms[i] = durationMs(d)
+		}
+		samples = append(samples, ms)
+	}
+	graph.BuildSubnetLatency(*nameserver, len(*client) != 0, "latency by client subnet",
+		subnets, samples, *outputDir)
+}
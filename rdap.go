@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var (
+	rdapOn  = flag.Bool("rdap", false, "Look up the organization of every answer network over RDAP and report where each subnet is directed")
+	rdapURL = flag.String("rdap-url", "https://rdap.org/ip/", "RDAP service the answer addresses are appended to")
+)
+
+// rdapCacheFile is kept in the output directory, networks in it are not
+// looked up again for rdapCacheTTL
+const (
+	rdapCacheFile = "rdap.json"
+	rdapCacheTTL  = 30 * 24 * time.Hour
+)
+
+// rdapMaxLookups bounds the queries to the RDAP service per run, the
+// public registries rate limit well below what a large run answers with
+const rdapMaxLookups = 100
+
+var rdapClient = &http.Client{Timeout: 10 * time.Second}
+
+// rdapNetwork is the part of an RDAP ip network object that is cached
+type rdapNetwork struct {
+	Start   string    `json:"start"`
+	End     string    `json:"end"`
+	Handle  string    `json:"handle"`
+	Name    string    `json:"name"`
+	Org     string    `json:"org,omitempty"`
+	Fetched time.Time `json:"fetched"`
+}
+
+type rdapEntity struct {
+	Roles      []string        `json:"roles"`
+	VcardArray json.RawMessage `json:"vcardArray"`
+}
+
+type rdapResponse struct {
+	Handle       string       `json:"handle"`
+	Name         string       `json:"name"`
+	StartAddress string       `json:"startAddress"`
+	EndAddress   string       `json:"endAddress"`
+	Entities     []rdapEntity `json:"entities"`
+}
+
+var (
+	rdapCache   []rdapNetwork
+	rdapLoaded  bool
+	rdapChanged bool
+	// subnetAnswers holds the distinct answer addresses of every subnet
+	subnetAnswers map[string]map[string]net.IP
+)
+
+func recordSubnetAnswers(subnet string, ips []net.IP) {
+	if !*rdapOn {
+		return
+	}
+	if subnet == "" {
+		subnet = "disabled"
+	}
+	if subnetAnswers[subnet] == nil {
+		subnetAnswers[subnet] = make(map[string]net.IP)
+	}
+	for _, ip := range ips {
+		subnetAnswers[subnet][ip.String()] = ip
+	}
+}
+
+func rdapCachePath() string {
+	return filepath.Join(*outputDir, rdapCacheFile)
+}
+
+func loadRDAPCache() {
+	rdapLoaded = true
+	b, err := ioutil.ReadFile(rdapCachePath())
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(b, &rdapCache); err != nil {
+		fmt.Fprintf(os.Stderr, "rdap: ignoring cache: %v\n", err)
+		rdapCache = nil
+	}
+}
+
+func saveRDAPCache() {
+	if !rdapChanged {
+		return
+	}
+	b, _ := json.MarshalIndent(rdapCache, "", "  ")
+	err := os.MkdirAll(*outputDir, 0755)
+	if err == nil {
+		err = ioutil.WriteFile(rdapCachePath(), b, 0644)
+	}
+	if err != nil {
+		fmt.Printf("Error writing RDAP cache\n%v\n", err)
+	}
+	rdapChanged = false
+}
+
+func (n *rdapNetwork) contains(ip net.IP) bool {
+	start, end := net.ParseIP(n.Start), net.ParseIP(n.End)
+	if start == nil || end == nil || (start.To4() == nil) != (ip.To4() == nil) {
+		return false
+	}
+	return bytes.Compare(ip.To16(), start.To16()) >= 0 && bytes.Compare(ip.To16(), end.To16()) <= 0
+}
+
+// cachedNetwork returns the most specific fresh network containing ip
+func cachedNetwork(ip net.IP) *rdapNetwork {
+	var best *rdapNetwork
+	for i := range rdapCache {
+		n := &rdapCache[i]
+		if time.Since(n.Fetched) > rdapCacheTTL || !n.contains(ip) {
+			continue
+		}
+		if best == nil || bytes.Compare(net.ParseIP(n.Start).To16(), net.ParseIP(best.Start).To16()) > 0 {
+			best = n
+		}
+	}
+	return best
+}
+
+// vcardName returns the formatted name of a jCard
+func vcardName(raw json.RawMessage) string {
+	var card []interface{}
+	if json.Unmarshal(raw, &card) != nil || len(card) < 2 {
+		return ""
+	}
+	props, _ := card[1].([]interface{})
+	for _, p := range props {
+		f, _ := p.([]interface{})
+		if len(f) == 4 && f[0] == "fn" {
+			s, _ := f[3].(string)
+			return s
+		}
+	}
+	return ""
+}
+
+// registrant returns the name of the registrant among the entities, or
+// of the first named one when none has that role
+func registrant(entities []rdapEntity) string {
+	var first string
+	for _, e := range entities {
+		name := vcardName(e.VcardArray)
+		for _, r := range e.Roles {
+			if r == "registrant" && name != "" {
+				return name
+			}
+		}
+		if first == "" {
+			first = name
+		}
+	}
+	return first
+}
+
+func fetchRDAP(ip net.IP) (*rdapNetwork, error) {
+	resp, err := rdapClient.Get(*rdapURL + ip.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v: %v", ip, resp.Status)
+	}
+
+	var r rdapResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+	n := rdapNetwork{Start: r.StartAddress, End: r.EndAddress, Handle: r.Handle, Name: r.Name,
+		Org: registrant(r.Entities), Fetched: time.Now()}
+	if n.Start == "" || n.End == "" {
+		// without its range the network only stands for the address
+		n.Start, n.End = ip.String(), ip.String()
+	}
+	rdapCache = append(rdapCache, n)
+	rdapChanged = true
+	return &rdapCache[len(rdapCache)-1], nil
+}
+
+func (n *rdapNetwork) String() string {
+	switch {
+	case n.Org != "":
+		return n.Org
+	case n.Name != "":
+		return n.Name
+	}
+	return n.Handle
+}
+
+// rdapStats reports the organizations whose networks every subnet was
+// answered with, counted by distinct addresses
+func rdapStats() {
+	if !*rdapOn || len(subnetAnswers) == 0 {
+		return
+	}
+	if !rdapLoaded {
+		loadRDAPCache()
+	}
+	defer saveRDAPCache()
+
+	subnets := make([]string, 0, len(subnetAnswers))
+	for s := range subnetAnswers {
+		subnets = append(subnets, s)
+	}
+	sort.Strings(subnets)
+
+	lookups, failed := 0, 0
+	fmt.Printf("[+] Answer Networks:\n")
+	for _, s := range subnets {
+		orgs := make(map[string]int)
+		for _, ip := range subnetAnswers[s] {
+			n := cachedNetwork(ip)
+			if n == nil && lookups < rdapMaxLookups {
+				lookups++
+				var err error
+				if n, err = fetchRDAP(ip); err != nil {
+					failed++
+					if *verbose {
+						fmt.Fprintf(os.Stderr, "rdap: %s\n", err)
+					}
+				}
+			}
+			if n == nil {
+				orgs["unknown"]++
+				continue
+			}
+			orgs[n.String()]++
+		}
+		fmt.Printf("[+]   %-20s%v\n", s, siteCounts(orgs))
+	}
+	if lookups == rdapMaxLookups || failed > 0 {
+		fmt.Printf("[+] RDAP Lookups:     %v (%v failed, limit %v)\n", lookups, failed, rdapMaxLookups)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	topN = flag.Int("top", 5, "Number of slowest and failed domains listed in the final report")
+)
+
+// domainOutcome is the final state of a domain once it was answered or ran
+// out of attempts, class only applies to failed domains
+type domainOutcome struct {
+	domain  string
+	ok      bool
+	rcode   string
+	class   errorClass
+	latency time.Duration
+	retries int
+	ips     []net.IP
+	subnet  string
+	server  string
+}
+
+// answered and failed are kept for the top N report
+var (
+	answered []domainOutcome
+	failed   []domainOutcome
+)
+
+func recordDomain(o domainOutcome) {
+	if o.ok {
+		answered = append(answered, o)
+	} else if len(failed) < *topN {
+		failed = append(failed, o)
+	}
+	recordSinks(o)
+}
+
+// fileSink is the per-domain results file of the run, one line per domain
+// so individual failures can be looked up afterwards
+type fileSink struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+func newFileSink(arg string) (OutputSink, error) {
+	return &fileSink{}, nil
+}
+
+func (s *fileSink) Name() string { return "file" }
+
+func (s *fileSink) Open() error {
+	f, err := createOutputFile("domains", "tsv")
+	if err != nil {
+		return err
+	}
+	s.f, s.w = f, bufio.NewWriter(f)
+	fmt.Fprintf(s.w, "domain\tstatus\trcode\terror\tlatency_ms\tretries\tanswers\n")
+	return nil
+}
+
+func (s *fileSink) Record(r sinkRecord) error {
+	class, answers := r.Error, strings.Join(r.Answers, ",")
+	if class == "" {
+		class = "-"
+	}
+	if answers == "" {
+		answers = "-"
+	}
+	_, err := fmt.Fprintf(s.w, "%v\t%v\t%v\t%v\t%.3f\t%v\t%v\n", r.Domain, r.Status, r.Rcode,
+		class, r.Latency, r.Retries, answers)
+	return err
+}
+
+func (s *fileSink) Summary(sum runSummary) error { return nil }
+
+func (s *fileSink) Close() error {
+	if err := s.w.Flush(); err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
+
+// topStats lists the slowest answered domains and the first failures with
+// their error class
+func topStats() {
+	if *topN <= 0 {
+		return
+	}
+
+	sort.SliceStable(answered, func(i, j int) bool { return answered[i].latency > answered[j].latency })
+	if len(answered) > 0 {
+		fmt.Printf("[+] Slowest Queries:\n")
+	}
+	for i, o := range answered {
+		if i == *topN {
+			break
+		}
+		fmt.Printf("[+]   %-16s%v (%v)\n", fmt.Sprintf("%.1fms:", durationMs(o.latency)), o.domain, o.rcode)
+	}
+
+	if len(failed) > 0 {
+		fmt.Printf("[+] Failed Queries:\n")
+	}
+	for _, o := range failed {
+		fmt.Printf("[+]   %-16s%v (%v)\n", o.class.String()+":", o.domain, o.rcode)
+	}
+}
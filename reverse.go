@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	reverseIPs = flag.Bool("reverse", false, "Look up the PTR names of all answer addresses after the run")
+)
+
+// reverseNames maps the answer addresses of the run to their PTR names,
+// filled in by resolveReverse
+var reverseNames map[string]string
+
+// resolveReverse looks up every unique answer address, the names often
+// carry the site or POP that served the client subnet
+func resolveReverse() {
+	type lookup struct {
+		ip, name string
+	}
+	work := make(chan string)
+	found := make(chan lookup)
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range work {
+				rev, err := dns.ReverseAddr(ip)
+				if err != nil {
+					continue
+				}
+				r, _, err := exchange(rev, dns.TypePTR, dns.ClassINET)
+				if err != nil {
+					continue
+				}
+				for _, a := range r.Answer {
+					if p, ok := a.(*dns.PTR); ok {
+						found <- lookup{ip, strings.ToLower(p.Ptr)}
+						break
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		for ip := range answerIPs {
+			work <- ip
+		}
+		close(work)
+		wg.Wait()
+		close(found)
+	}()
+
+	for l := range found {
+		reverseNames[l.ip] = l.name
+	}
+}
+
+// reverseStats lists the PTR names answers pointed at most, weighted by
+// the number of domains answered with each address
+func reverseStats() {
+	if !*reverseIPs || len(answerIPs) == 0 {
+		return
+	}
+	resolveReverse()
+
+	counts := make(map[string]int)
+	for _, o := range answered {
+		for _, ip := range o.ips {
+			if n, ok := reverseNames[ip.String()]; ok {
+				counts[n]++
+			}
+		}
+	}
+	names := make([]string, 0, len(counts))
+	for n := range counts {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	fmt.Printf("[+] Reverse Names:    %v of %v addresses\n", len(reverseNames), len(answerIPs))
+	for i, n := range names {
+		if i == *topN {
+			break
+		}
+		fmt.Printf("[+]   %-24s%v\n", n, counts[n])
+	}
+
+	f, err := createOutputFile("reverse", "tsv")
+	if err != nil {
+		fmt.Printf("Error writing reverse file\n%v\n", err)
+		return
+	}
+	defer f.Close()
+
+	for _, ip := range sortedIPs() {
+		if n, ok := reverseNames[ip.String()]; ok {
+			fmt.Fprintf(f, "%v\t%v\n", ip, n)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// rrlMinResponses is the smallest window worth judging
+	rrlMinResponses = 10
+	// rrlErrorRatio of REFUSED, SERVFAIL or truncated answers in a window
+	rrlErrorRatio = 0.5
+	// rrlDropRatio of the smoothed rate below which a window counts as a drop
+	rrlDropRatio = 0.2
+)
+
+type rrlWindow struct {
+	responses int
+	refused   int
+	servfail  int
+	truncated int
+}
+
+var (
+	// rrlCur is filled by the guard goroutine and closed by the stats
+	// goroutine, rrlMu guards it
+	rrlCur     rrlWindow
+	rrlMu      sync.Mutex
+	rrlAvg     float64
+	rrlOnset   float64 = -1
+	rrlActive  bool
+	rrlWindows int
+	// rrlMarks holds the start of every throttling episode
+	rrlMarks []float64
+)
+
+// recordResponse accounts a matched response to the current window
+func recordResponse(da *domainAnswer) {
+	rrlMu.Lock()
+	defer rrlMu.Unlock()
+	rrlCur.responses++
+	switch {
+	case da.truncated:
+		rrlCur.truncated++
+	case da.rcode == dns.RcodeRefused:
+		rrlCur.refused++
+	case da.rcode == dns.RcodeServerFailure:
+		rrlCur.servfail++
+	}
+}
+
+// checkThrottle inspects the window that just closed for the signatures of
+// response rate limiting: a majority of refusals, server failures or
+// truncated answers, or a collapse in rate while queries are still being sent
+func checkThrottle(now, rate float64, sent int) {
+	rrlMu.Lock()
+	w := rrlCur
+	rrlCur = rrlWindow{}
+	rrlMu.Unlock()
+
+	throttled := false
+	if w.responses >= rrlMinResponses {
+		bad := w.refused + w.servfail + w.truncated
+		throttled = float64(bad)/float64(w.responses) >= rrlErrorRatio
+	}
+	if sent >= rrlMinResponses && rrlAvg > 0 && rate < rrlAvg*rrlDropRatio {
+		throttled = true
+	}
+	rrlAvg = 0.9*rrlAvg + 0.1*rate
+
+	if !throttled {
+		rrlActive = false
+		return
+	}
+	if rrlOnset < 0 {
+		rrlOnset = now
+	}
+	if !rrlActive {
+		rrlMarks = append(rrlMarks, now)
+	}
+	rrlActive = true
+	rrlWindows++
+}
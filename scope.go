@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/miekg/dns"
+	"github.com/rtmoranorg/dns-client-subnet-ext/graph"
+)
+
+var (
+	// scopeLengths counts the responses by the scope prefix length of their
+	// client subnet option
+	scopeLengths map[int]int
+	// scopeMissing counts the responses to a query with a subnet that came
+	// back without the option
+	scopeMissing int
+	// scopeNarrower and scopeWider count the scopes differing from the
+	// source prefix length of the query
+	scopeNarrower, scopeWider int
+)
+
+func recordScope(subnet string, msg *dns.Msg) {
+	if subnet == "" {
+		return
+	}
+	o := msg.IsEdns0()
+	if o == nil {
+		scopeMissing++
+		return
+	}
+	for _, e := range o.Option {
+		s, ok := e.(*dns.EDNS0_SUBNET)
+		if !ok {
+			continue
+		}
+		scopeLengths[int(s.SourceScope)]++
+		switch {
+		case s.SourceScope > s.SourceNetmask:
+			scopeNarrower++
+		case s.SourceScope < s.SourceNetmask:
+			scopeWider++
+		}
+		return
+	}
+	scopeMissing++
+}
+
+func resetScopes() {
+	scopeLengths = make(map[int]int)
+	scopeMissing, scopeNarrower, scopeWider = 0, 0, 0
+}
+
+// scopeStats reports the histogram of scope prefix lengths, a resolver
+// answering everything with scope 0 does not tailor answers to subnets at
+// all while one echoing the source length caches per subnet. Scopes are
+// compared to the source length echoed in the response.
+func scopeStats() {
+	if len(scopeLengths) == 0 && scopeMissing == 0 {
+		return
+	}
+
+	lengths := make([]int, 0, len(scopeLengths))
+	for n := range scopeLengths {
+		lengths = append(lengths, n)
+	}
+	sort.Ints(lengths)
+
+	total := scopeMissing
+	for _, n := range lengths {
+		total += scopeLengths[n]
+	}
+	fmt.Printf("[+] ECS Scopes:\n")
+	labels := make([]string, 0, len(lengths))
+	counts := make([]int, 0, len(lengths))
+	for _, n := range lengths {
+		fmt.Printf("[+]   %-16s%v (%.1f%%)\n", fmt.Sprintf("/%d:", n), scopeLengths[n],
+			float64(scopeLengths[n])*100/float64(total))
+		labels = append(labels, fmt.Sprintf("/%d", n))
+		counts = append(counts, scopeLengths[n])
+	}
+	if scopeMissing > 0 {
+		fmt.Printf("[+]   %-16s%v (%.1f%%)\n", "no option:", scopeMissing,
+			float64(scopeMissing)*100/float64(total))
+	}
+	fmt.Printf("[+] Scope vs Source:  %v narrower, %v wider\n", scopeNarrower, scopeWider)
+
+	graph.BuildScopeGraph(*nameserver, len(*client) != 0, labels, counts, *outputDir)
+}
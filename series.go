@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// seriesBins is about how many points the per-rcode time series have,
+// bins are never shorter than minBinWidth seconds
+const (
+	seriesBins  = 60
+	minBinWidth = 0.25
+)
+
+type outcomeEvent struct {
+	t     float64
+	rcode string
+}
+
+// outcomeEvents holds when every domain got its final answer or timed out
+var outcomeEvents []outcomeEvent
+
+func recordOutcomeEvent(rcode string) {
+	outcomeEvents = append(outcomeEvents, outcomeEvent{getRunTime(), rcode})
+}
+
+// binWidth returns the width of the bins covering the run up to end and how
+// many there are
+func binWidth(end float64) (float64, int) {
+	width := end / seriesBins
+	if width < minBinWidth {
+		width = minBinWidth
+	}
+	return width, int(end/width) + 1
+}
+
+func binCenters(width float64, n int) []float64 {
+	t := make([]float64, n)
+	for i := range t {
+		t[i] = (float64(i) + 0.5) * width
+	}
+	return t
+}
+
+// outcomeRates bins the events of the run and returns the bin centers along
+// with the rate per second of every rcode in each bin
+func outcomeRates() ([]float64, map[string][]float64) {
+	if len(outcomeEvents) == 0 {
+		return nil, nil
+	}
+	width, n := binWidth(outcomeEvents[len(outcomeEvents)-1].t)
+
+	rates := make(map[string][]float64)
+	for _, e := range outcomeEvents {
+		r, ok := rates[e.rcode]
+		if !ok {
+			r = make([]float64, n)
+			rates[e.rcode] = r
+		}
+		r[int(e.t/width)] += 1 / width
+	}
+	return binCenters(width, n), rates
+}
+
+// attemptTimes and lostTimes are when every attempt was sent and when the
+// attempts that timed out had been sent, in seconds since the start
+var (
+	attemptTimes []float64
+	lostTimes    []float64
+)
+
+func recordAttempt() {
+	attemptTimes = append(attemptTimes, getRunTime())
+}
+
+// recordLost counts a timed out attempt against the time it was sent, so
+// loss lines up with the traffic that suffered it
+func recordLost(sent time.Time) {
+	lostTimes = append(lostTimes, sent.Sub(t0).Seconds())
+}
+
+// lossRates returns the bin centers and the share of attempts sent in each
+// bin that never got an answer
+func lossRates() ([]float64, []float64) {
+	if len(attemptTimes) == 0 {
+		return nil, nil
+	}
+	width, n := binWidth(attemptTimes[len(attemptTimes)-1])
+
+	sent := make([]float64, n)
+	for _, t := range attemptTimes {
+		sent[int(t/width)]++
+	}
+	loss := make([]float64, n)
+	for _, t := range lostTimes {
+		if i := int(t / width); i < n {
+			loss[i]++
+		}
+	}
+	for i := range loss {
+		if sent[i] > 0 {
+			loss[i] = 100 * loss[i] / sent[i]
+		}
+	}
+	return binCenters(width, n), loss
+}
+
+func lossStats() {
+	if len(attemptTimes) == 0 {
+		return
+	}
+	fmt.Printf("[+] Lost Attempts:    %v of %v (%.1f%%)\n", len(lostTimes), len(attemptTimes),
+		100*float64(len(lostTimes))/float64(len(attemptTimes)))
+}
+
+func sortedRates(m map[string][]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// shapeSamples limits how many empty NOERROR domains are listed
+const shapeSamples = 5
+
+var (
+	answerSizes  = make(map[int]int)
+	answerTypes  = make(map[uint16]int)
+	emptyNoError []string
+)
+
+// recordShape accounts the number and types of records in a response,
+// a NOERROR without any answer records is a NODATA response
+func recordShape(domain string, msg *dns.Msg) {
+	answerSizes[len(msg.Answer)]++
+	for _, a := range msg.Answer {
+		answerTypes[a.Header().Rrtype]++
+	}
+
+	if msg.Rcode == dns.RcodeSuccess && len(msg.Answer) == 0 {
+		emptyNoError = append(emptyNoError, domain)
+	}
+}
+
+func shapeStats() {
+	if len(answerSizes) == 0 {
+		return
+	}
+
+	sizes := make([]int, 0, len(answerSizes))
+	for n := range answerSizes {
+		sizes = append(sizes, n)
+	}
+	sort.Ints(sizes)
+
+	fmt.Printf("[+] Answer Counts:\n")
+	for _, n := range sizes {
+		fmt.Printf("[+]   %-16s%v\n", fmt.Sprintf("%d RRs:", n), answerSizes[n])
+	}
+
+	types := make([]uint16, 0, len(answerTypes))
+	for t := range answerTypes {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	if len(types) > 0 {
+		fmt.Printf("[+] Record Types:\n")
+	}
+	for _, t := range types {
+		fmt.Printf("[+]   %-16s%v\n", typeName(t)+":", answerTypes[t])
+	}
+
+	if len(emptyNoError) > 0 {
+		sample := emptyNoError
+		if len(sample) > shapeSamples {
+			sample = sample[:shapeSamples]
+		}
+		fmt.Printf("[+] Empty NOERROR:    %v (%v)\n", len(emptyNoError),
+			strings.Join(sample, ", "))
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	sinkList = flag.String("sink", "file", "Comma separated sinks receiving every domain and the run summary: file, jsonl[:PATH], sqlite[:PATH] or prometheus[:PATH|URL]")
+)
+
+// OutputSink receives the outcome of every domain of a run followed by its
+// summary. Open is called at the start of every run and Close once the
+// summary was handed over, so a sink may keep state between the two.
+type OutputSink interface {
+	Name() string
+	Open() error
+	Record(r sinkRecord) error
+	Summary(s runSummary) error
+	Close() error
+}
+
+// sinkFactories builds the sinks named by -sink from what follows the colon
+// of their entry, a new kind of output only needs an entry
+var sinkFactories = map[string]func(arg string) (OutputSink, error){
+	"file":       newFileSink,
+	"jsonl":      newJSONLSink,
+	"prometheus": newPrometheusSink,
+	"sqlite":     newSQLiteSink,
+}
+
+// sinkRecord is what sinks get for every domain once it was answered or ran
+// out of attempts, the error class is empty for answered domains
+type sinkRecord struct {
+	Domain  string    `json:"domain"`
+	Status  string    `json:"status"`
+	Rcode   string    `json:"rcode"`
+	Error   string    `json:"error,omitempty"`
+	Latency float64   `json:"latency_ms"`
+	Retries int       `json:"retries"`
+	Answers []string  `json:"answers,omitempty"`
+	Subnet  string    `json:"subnet,omitempty"`
+	Server  string    `json:"server,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+var (
+	sinks []OutputSink
+	// openedSinks are the sinks of the run in progress, a sink failing to
+	// take a record is closed and left out for the rest of the run
+	openedSinks []OutputSink
+)
+
+func setupSinks() error {
+	sinks = nil
+	if *sinkList == "" {
+		return nil
+	}
+	for _, e := range strings.Split(*sinkList, ",") {
+		kv := strings.SplitN(strings.TrimSpace(e), ":", 2)
+		f, ok := sinkFactories[kv[0]]
+		if !ok {
+			return fmt.Errorf("unknown sink %q", kv[0])
+		}
+		arg := ""
+		if len(kv) == 2 {
+			arg = kv[1]
+		}
+		s, err := f(arg)
+		if err != nil {
+			return fmt.Errorf("%v: %v", kv[0], err)
+		}
+		sinks = append(sinks, s)
+	}
+	return nil
+}
+
+func openSinks() error {
+	openedSinks = nil
+	for _, s := range sinks {
+		if err := s.Open(); err != nil {
+			closeSinks(runSummary{})
+			return fmt.Errorf("%v: %v", s.Name(), err)
+		}
+		openedSinks = append(openedSinks, s)
+	}
+	return nil
+}
+
+func recordSinks(o domainOutcome) {
+	if len(openedSinks) == 0 {
+		return
+	}
+
+	r := sinkRecord{Domain: o.domain, Status: "ok", Rcode: o.rcode,
+		Latency: durationMs(o.latency), Retries: o.retries, Subnet: o.subnet,
+		Server: o.server, Time: time.Now()}
+	if !o.ok {
+		r.Status, r.Error = "fail", o.class.String()
+	}
+	for _, ip := range o.ips {
+		r.Answers = append(r.Answers, ip.String())
+	}
+
+	kept := openedSinks[:0]
+	for _, s := range openedSinks {
+		if err := s.Record(r); err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %s, no further records are written\n", s.Name(), err)
+			s.Close()
+			continue
+		}
+		kept = append(kept, s)
+	}
+	openedSinks = kept
+}
+
+// closeSinks hands the summary to the sinks of the run and closes them, a
+// zero summary only closes them
+func closeSinks(sum runSummary) {
+	for _, s := range openedSinks {
+		if !sum.Time.IsZero() {
+			if err := s.Summary(sum); err != nil {
+				fmt.Fprintf(os.Stderr, "%v: %s\n", s.Name(), err)
+			}
+		}
+		if err := s.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %s\n", s.Name(), err)
+		}
+	}
+	openedSinks = nil
+}
+
+// jsonlSink writes one JSON object per domain and the summary as the last
+// line, keyed by "summary" to tell it from the records. Without a path every
+// run gets a file of its own, a path is appended to by every run.
+type jsonlSink struct {
+	path string
+	f    *os.File
+	enc  *json.Encoder
+}
+
+func newJSONLSink(arg string) (OutputSink, error) {
+	return &jsonlSink{path: arg}, nil
+}
+
+func (s *jsonlSink) Name() string { return "jsonl" }
+
+func (s *jsonlSink) Open() error {
+	var err error
+	if s.path == "" {
+		s.f, err = createOutputFile("queries", "jsonl")
+	} else {
+		s.f, err = os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err == nil {
+			writeMeta(s.f, "jsonl")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	s.enc = json.NewEncoder(s.f)
+	return nil
+}
+
+func (s *jsonlSink) Record(r sinkRecord) error {
+	return s.enc.Encode(r)
+}
+
+func (s *jsonlSink) Summary(sum runSummary) error {
+	return s.enc.Encode(struct {
+		Summary runSummary `json:"summary"`
+	}{sum})
+}
+
+func (s *jsonlSink) Close() error {
+	return s.f.Close()
+}
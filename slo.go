@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	sloFile = flag.String("slo", "", "Location of SLO file the run is evaluated against")
+)
+
+type objective struct {
+	kind  string // latency, errors or rcode
+	arg   string // percentile or rcode name
+	limit float64
+}
+
+func (o objective) String() string {
+	if o.arg == "" {
+		return o.kind
+	}
+	return o.kind + " " + o.arg
+}
+
+var objectives []objective
+
+// loadSLO reads lines of the form
+//
+//	latency p95 50ms
+//	latency p99.9 250ms
+//	errors 1%
+//	rcode SERVFAIL 0.5%
+//
+// latency objectives bound a percentile of the answered queries, errors is
+// the budget of failed domains and rcode bounds the share of responses
+// carrying that code
+func loadSLO(n string) error {
+	f, err := os.Open(n)
+	if err != nil {
+		return fmt.Errorf("Failed to open SLO file")
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		o, err := parseObjective(fields)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		objectives = append(objectives, o)
+	}
+	return scanner.Err()
+}
+
+func parseObjective(fields []string) (objective, error) {
+	o := objective{kind: strings.ToLower(fields[0])}
+	var err error
+
+	switch {
+	case o.kind == "latency" && len(fields) == 3:
+		o.arg = strings.ToLower(fields[1])
+		p, perr := strconv.ParseFloat(strings.TrimPrefix(o.arg, "p"), 64)
+		if perr != nil || !strings.HasPrefix(o.arg, "p") || p <= 0 || p > 100 {
+			return o, fmt.Errorf("%q is not a percentile", fields[1])
+		}
+		var d time.Duration
+		d, err = time.ParseDuration(fields[2])
+		o.limit = durationMs(d)
+	case o.kind == "errors" && len(fields) == 2:
+		o.limit, err = parsePercent(fields[1])
+	case o.kind == "rcode" && len(fields) == 3:
+		o.arg = strings.ToUpper(fields[1])
+		if _, ok := dns.StringToRcode[o.arg]; !ok {
+			return o, fmt.Errorf("%q is not an rcode", fields[1])
+		}
+		o.limit, err = parsePercent(fields[2])
+	default:
+		return o, fmt.Errorf("unknown objective %q", strings.Join(fields, " "))
+	}
+	return o, err
+}
+
+func parsePercent(s string) (float64, error) {
+	if !strings.HasSuffix(s, "%") {
+		return 0, fmt.Errorf("%q is not a percentage", s)
+	}
+	return strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+}
+
+type objectiveResult struct {
+	Objective string  `json:"objective"`
+	Limit     float64 `json:"limit"`
+	Actual    float64 `json:"actual"`
+	Unit      string  `json:"unit"`
+	Pass      bool    `json:"pass"`
+	// NoData is set when the run had nothing to measure the objective on,
+	// which counts as missing it
+	NoData bool `json:"no_data,omitempty"`
+}
+
+type sloVerdict struct {
+	Pass       bool              `json:"pass"`
+	Time       time.Time         `json:"time"`
+	Nameserver string            `json:"nameserver"`
+	Objectives []objectiveResult `json:"objectives"`
+}
+
+func evaluateSLO(s runSummary) sloVerdict {
+	v := sloVerdict{Pass: true, Time: s.Time, Nameserver: s.Nameserver}
+
+	responses := 0
+	for _, n := range s.Rcodes {
+		responses += n
+	}
+	for _, o := range objectives {
+		r := objectiveResult{Objective: o.String(), Limit: o.limit, Unit: "%"}
+		switch o.kind {
+		case "latency":
+			p, _ := strconv.ParseFloat(strings.TrimPrefix(o.arg, "p"), 64)
+			r.Actual, r.Unit = durationMs(latencyPercentile(p)), "ms"
+			r.NoData = len(latencies) == 0
+		case "errors":
+			if s.Attempts > 0 {
+				r.Actual = 100 * float64(s.Fail) / float64(s.Attempts)
+			}
+			r.NoData = s.Attempts == 0
+		case "rcode":
+			if responses > 0 {
+				r.Actual = 100 * float64(s.Rcodes[o.arg]) / float64(responses)
+			}
+			r.NoData = responses == 0
+		}
+		r.Pass = !r.NoData && r.Actual <= r.Limit
+		v.Pass = v.Pass && r.Pass
+		v.Objectives = append(v.Objectives, r)
+	}
+	return v
+}
+
+// reportSLO prints and saves the verdict of the run, it returns whether an
+// objective was missed
+func reportSLO(s runSummary) bool {
+	if len(objectives) == 0 {
+		return false
+	}
+	v := evaluateSLO(s)
+
+	fmt.Printf("[+] %-18s%v\n", "SLO:", map[bool]string{true: "pass", false: "fail"}[v.Pass])
+	for _, r := range v.Objectives {
+		mark := "+"
+		if !r.Pass {
+			mark = "-"
+		}
+		actual := fmt.Sprintf("%.2f%v", r.Actual, r.Unit)
+		if r.NoData {
+			actual = "no data"
+		}
+		fmt.Printf("[%v]   %-16s%v (limit %v%v)\n", mark, r.Objective+":", actual, r.Limit, r.Unit)
+	}
+
+	f, err := createOutputFile("slo", "json")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "slo: %s\n", err)
+		return !v.Pass
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	enc.Encode(struct {
+		Meta *runMeta `json:"meta"`
+		sloVerdict
+	}{currentMeta(), v})
+	return !v.Pass
+}
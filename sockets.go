@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+var (
+	portStrategy = flag.String("ports", "per-worker", "Source port strategy: per-worker sockets shared by many queries, or a new socket per-query")
+	socketCount  = flag.Int("sockets", 1, "Number of sockets, or pooled stream connections, queries are spread over with -ports per-worker")
+	dscp         = flag.Int("dscp", 0, "DSCP value (0-63) to mark outgoing queries with")
+)
+
+// dscpNames are the code points with a well known per hop behaviour
+var dscpNames = map[int]string{
+	0: "default", 8: "CS1", 10: "AF11", 18: "AF21", 26: "AF31",
+	34: "AF41", 40: "CS5", 46: "EF", 48: "CS6", 56: "CS7",
+}
+
+var (
+	portsMu   sync.Mutex
+	portsUsed map[int]int
+)
+
+func recordPort(c net.Conn) {
+	if a, ok := c.LocalAddr().(*net.UDPAddr); ok {
+		portsMu.Lock()
+		portsUsed[a.Port]++
+		portsMu.Unlock()
+	}
+}
+
+// querySockets is the number of sockets queries are assigned to, every
+// per-query socket counts as socket 0 since it only ever carries one ID
+// (or 1 when it goes to the secondary nameserver)
+func querySockets() int {
+	if *portStrategy == "per-query" || *socketCount < 1 {
+		return 1
+	}
+	return *socketCount
+}
+
+func dialNameserver(addr string) (net.Conn, error) {
+	var c net.Conn
+	var err error
+	switch {
+	case *localMode != "":
+		c, err = dialMulticast(addr)
+	case *transport != "udp":
+		c, err = dialStream(addr)
+	default:
+		c, err = net.Dial("udp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := markSocket(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+	recordPort(c)
+	return c, nil
+}
+
+// markSocket sets the DSCP bits of the TOS or traffic class byte, the
+// remaining two bits belong to ECN and are left clear. Connections wrapped
+// by TLS or framing were marked by markedDialer and are left alone.
+func markSocket(c net.Conn) error {
+	if *dscp == 0 {
+		return nil
+	}
+	if _, ok := c.(syscall.Conn); !ok {
+		return nil
+	}
+	var ip net.IP
+	switch a := c.RemoteAddr().(type) {
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.TCPAddr:
+		ip = a.IP
+	default:
+		return nil
+	}
+	if ip.To4() != nil {
+		return ipv4.NewConn(c).SetTOS(*dscp << 2)
+	}
+	return ipv6.NewConn(c).SetTrafficClass(*dscp << 2)
+}
+
+// markedDialer marks the TCP connections of the stream transports and DoH.
+// It is the forward dialer of the SOCKS5 proxy as well, the hop to the
+// proxy is the one a local network can prioritize.
+type markedDialer struct {
+	*net.Dialer
+}
+
+func (d markedDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d markedDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	c, err := d.Dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := markSocket(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func dscpName(v int) string {
+	if n, ok := dscpNames[v]; ok {
+		return fmt.Sprintf("%d (%v)", v, n)
+	}
+	return fmt.Sprintf("%d", v)
+}
+
+// openSockets dials the sockets shared by the queries of a per-worker run,
+// as many again are opened to the secondary nameserver
+func openSockets() ([]net.Conn, error) {
+	n := querySockets()
+	if *secondary != "" {
+		n *= 2
+	}
+	conns := make([]net.Conn, 0, n)
+	for i := 0; i < n; i++ {
+		var c net.Conn
+		var err error
+		if *transport != "udp" {
+			c, err = newPooledConn(socketAddr(i))
+		} else {
+			c, err = dialNameserver(socketAddr(i))
+		}
+		if err != nil {
+			closeSockets(conns)
+			return nil, err
+		}
+		conns = append(conns, c)
+	}
+	return conns, nil
+}
+
+func closeSockets(conns []net.Conn) {
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// writePerQuery sends every attempt from a socket of its own, each is read
+// until the attempt times out and then closed
+func writePerQuery(tryResolving <-chan *domainRecord, resolved chan<- *domainAnswer, stop <-chan struct{}) {
+	for dr := range tryResolving {
+		c, err := dialNameserver(socketAddr(dr.socket))
+		if err != nil {
+			countError(dialError(err))
+			if *verbose {
+				fmt.Fprintf(os.Stderr, "bind(%s, %s): %s\n", *transport, *nameserver, err)
+			}
+			time.Sleep(sendingDelay)
+			continue
+		}
+		c.SetReadDeadline(time.Now().Add(retryDelay))
+		go func(socket int) {
+			readRequest(c, socket, resolved, stop)
+			c.Close()
+		}(dr.socket)
+		writeQuery(c, dr)
+	}
+}
+
+func portStats() {
+	portsMu.Lock()
+	defer portsMu.Unlock()
+	if len(portsUsed) == 0 {
+		return
+	}
+	fmt.Printf("[+] Source Ports:     %v distinct (%v)\n", len(portsUsed), *portStrategy)
+	if *dscp != 0 {
+		fmt.Printf("[+] DSCP:             %v\n", dscpName(*dscp))
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/proxy"
+)
+
+var (
+	socksProxy = flag.String("socks5", "", "Route TCP, DoT and DoH queries through the SOCKS5 proxy at [user:pass@]host:port, such as ssh -D or Tor")
+)
+
+var socksDialer proxy.ContextDialer
+
+// setupSocks builds the proxy dialer, names are handed to the proxy
+// unresolved so the far end looks them up from its own vantage point
+func setupSocks() error {
+	if *socksProxy == "" {
+		return nil
+	}
+	if *transport == "udp" && *dohURL == "" {
+		return fmt.Errorf("SOCKS5 needs -transport tcp, dot or a -doh endpoint")
+	}
+	if *fastOpen {
+		return fmt.Errorf("-tfo would only reach the proxy")
+	}
+
+	var auth *proxy.Auth
+	addr := *socksProxy
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		user := addr[:i]
+		auth = &proxy.Auth{User: user}
+		if j := strings.Index(user, ":"); j >= 0 {
+			auth.User, auth.Password = user[:j], user[j+1:]
+		}
+		addr = addr[i+1:]
+	}
+	d, err := proxy.SOCKS5("tcp", addr, auth, markedDialer{&net.Dialer{Timeout: retryDelay}})
+	if err != nil {
+		return err
+	}
+	socksDialer = d.(proxy.ContextDialer)
+	return nil
+}
+
+// dialTCP connects directly or by the proxy, its connect time then covers
+// the SOCKS negotiation as well
+func dialTCP(ctx context.Context, d *net.Dialer, addr string) (net.Conn, error) {
+	if socksDialer != nil {
+		return socksDialer.DialContext(ctx, "tcp", addr)
+	}
+	return markedDialer{d}.DialContext(ctx, "tcp", addr)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// registers the pure Go sqlite driver, builds keep working without cgo
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema keeps every run in the database, queries refer to the run
+// they belong to by its id
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	run_id TEXT PRIMARY KEY,
+	time TEXT,
+	nameserver TEXT,
+	client TEXT,
+	completed INTEGER,
+	attempts INTEGER,
+	success INTEGER,
+	fail INTEGER,
+	avg_rate REAL,
+	elapsed_seconds REAL,
+	latency_p95_ms REAL,
+	summary TEXT
+);
+CREATE TABLE IF NOT EXISTS queries (
+	run_id TEXT,
+	domain TEXT,
+	status TEXT,
+	rcode TEXT,
+	error TEXT,
+	latency_ms REAL,
+	retries INTEGER,
+	answers TEXT,
+	subnet TEXT,
+	server TEXT,
+	time TEXT
+);
+CREATE INDEX IF NOT EXISTS queries_run ON queries (run_id);
+`
+
+// sqliteSink adds every run to a database in the output directory unless
+// a path is given. The queries of a run are written in one transaction
+// that is committed along with its summary.
+type sqliteSink struct {
+	path  string
+	db    *sql.DB
+	tx    *sql.Tx
+	query *sql.Stmt
+}
+
+func newSQLiteSink(arg string) (OutputSink, error) {
+	return &sqliteSink{path: arg}, nil
+}
+
+func (s *sqliteSink) Name() string { return "sqlite" }
+
+func (s *sqliteSink) Open() error {
+	path := s.path
+	if path == "" {
+		os.MkdirAll(*outputDir, os.ModePerm)
+		path = filepath.Join(*outputDir, "results.db")
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		db.Close()
+		return err
+	}
+	query, err := tx.Prepare("INSERT INTO queries VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		db.Close()
+		return err
+	}
+	s.db, s.tx, s.query = db, tx, query
+	return nil
+}
+
+func (s *sqliteSink) Record(r sinkRecord) error {
+	_, err := s.query.Exec(currentRunID(), r.Domain, r.Status, r.Rcode, r.Error, r.Latency,
+		r.Retries, strings.Join(r.Answers, ","), r.Subnet, r.Server, r.Time.Format(time.RFC3339Nano))
+	return err
+}
+
+func (s *sqliteSink) Summary(sum runSummary) error {
+	b, err := json.Marshal(sum)
+	if err != nil {
+		return err
+	}
+	_, err = s.tx.Exec("INSERT OR REPLACE INTO runs VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		currentRunID(), sum.Time.Format(time.RFC3339Nano), sum.Nameserver, sum.Client, sum.Completed,
+		sum.Attempts, sum.Success, sum.Fail, sum.AvgRate, sum.Elapsed, sum.LatencyP95, string(b))
+	if err != nil {
+		return err
+	}
+	err = s.tx.Commit()
+	s.tx = nil
+	return err
+}
+
+// Close discards the queries of a run that never got its summary
+func (s *sqliteSink) Close() error {
+	s.query.Close()
+	if s.tx != nil {
+		s.tx.Rollback()
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	srvChase = flag.Bool("srv-chase", false, "Resolve the targets of SRV answers that came without addresses")
+)
+
+type srvEndpoint struct {
+	service string
+	srv     *dns.SRV
+	addrs   []net.IP
+}
+
+var srvEndpoints []*srvEndpoint
+
+// recordSRV keeps the SRV records of an answer along with any addresses
+// of their targets given in the additional section
+func recordSRV(domain string, msg *dns.Msg) {
+	for _, a := range msg.Answer {
+		s, ok := a.(*dns.SRV)
+		if !ok {
+			continue
+		}
+		ep := &srvEndpoint{service: domain, srv: s}
+		target := strings.ToLower(s.Target)
+		for _, x := range msg.Extra {
+			if !strings.EqualFold(x.Header().Name, target) {
+				continue
+			}
+			switch t := x.(type) {
+			case *dns.A:
+				ep.addrs = append(ep.addrs, t.A)
+			case *dns.AAAA:
+				ep.addrs = append(ep.addrs, t.AAAA)
+			}
+		}
+		srvEndpoints = append(srvEndpoints, ep)
+	}
+}
+
+// chaseTargets looks up the A and AAAA records of targets that came
+// without glue, a "." target means the service is not offered
+func chaseTargets() {
+	work := make(chan *srvEndpoint)
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ep := range work {
+				var addrs []net.IP
+				for _, t := range []uint16{dns.TypeA, dns.TypeAAAA} {
+					r, _, err := exchange(ep.srv.Target, t, dns.ClassINET)
+					if err != nil {
+						continue
+					}
+					addrs = append(addrs, newAnswer(r).ips...)
+				}
+				ep.addrs = addrs
+			}
+		}()
+	}
+	for _, ep := range srvEndpoints {
+		if len(ep.addrs) == 0 && ep.srv.Target != "." {
+			work <- ep
+		}
+	}
+	close(work)
+	wg.Wait()
+}
+
+func srvStats() {
+	if len(srvEndpoints) == 0 {
+		return
+	}
+	if *srvChase {
+		chaseTargets()
+	}
+	sort.SliceStable(srvEndpoints, func(i, j int) bool {
+		a, b := srvEndpoints[i], srvEndpoints[j]
+		if a.service != b.service {
+			return a.service < b.service
+		}
+		if a.srv.Priority != b.srv.Priority {
+			return a.srv.Priority < b.srv.Priority
+		}
+		return a.srv.Weight > b.srv.Weight
+	})
+
+	f, err := createOutputFile("srv", "tsv")
+	if err != nil {
+		fmt.Printf("Error writing SRV file\n%v\n", err)
+		return
+	}
+	defer f.Close()
+
+	addrs, unresolved := 0, 0
+	for _, ep := range srvEndpoints {
+		s := make([]string, 0, len(ep.addrs))
+		for _, ip := range ep.addrs {
+			s = append(s, net.JoinHostPort(ip.String(), fmt.Sprint(ep.srv.Port)))
+		}
+		if len(s) == 0 && ep.srv.Target != "." {
+			unresolved++
+		}
+		addrs += len(s)
+		fmt.Fprintf(f, "%v\t%v\t%v\t%v\t%v\t%v\n", ep.service, ep.srv.Priority, ep.srv.Weight,
+			ep.srv.Target, ep.srv.Port, strings.Join(s, ","))
+	}
+	fmt.Printf("[+] SRV Targets:      %v\n"+
+		"[+]   %-16s%v\n"+
+		"[+]   %-16s%v\n",
+		len(srvEndpoints), "Endpoints:", addrs, "Unresolved:", unresolved)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// resultBuffer is how many results a subscriber may fall behind before
+// further results are dropped for it
+const resultBuffer = 4096
+
+// queryResult is published for every domain once its outcome is known
+type queryResult struct {
+	Domain  string    `json:"domain"`
+	Rcode   string    `json:"rcode"`
+	IPs     []string  `json:"ips,omitempty"`
+	Tries   int       `json:"tries"`
+	Latency float64   `json:"latency_ms,omitempty"`
+	Server  string    `json:"server,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+var (
+	resultMu   sync.Mutex
+	resultSubs = make(map[chan queryResult]struct{})
+)
+
+func subscribeResults() chan queryResult {
+	ch := make(chan queryResult, resultBuffer)
+	resultMu.Lock()
+	resultSubs[ch] = struct{}{}
+	resultMu.Unlock()
+	return ch
+}
+
+func unsubscribeResults(ch chan queryResult) {
+	resultMu.Lock()
+	delete(resultSubs, ch)
+	resultMu.Unlock()
+}
+
+// publishResult hands the outcome of a domain to every subscriber, it never
+// blocks so a slow consumer cannot stall the run. The TSV output is written
+// synchronously since pipelines must not lose lines.
+func publishResult(domain, rcode string, ips []net.IP, tries int, latency time.Duration, server string) {
+	resultMu.Lock()
+	defer resultMu.Unlock()
+	if len(resultSubs) == 0 && !*tsvOutput {
+		return
+	}
+
+	r := queryResult{Domain: domain, Rcode: rcode, Tries: tries,
+		Latency: durationMs(latency), Server: server, Time: time.Now()}
+	for _, ip := range ips {
+		r.IPs = append(r.IPs, ip.String())
+	}
+	if *tsvOutput {
+		writeTSV(r)
+	}
+	for ch := range resultSubs {
+		select {
+		case ch <- r:
+		default:
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+)
+
+var (
+	summaryJSON = flag.Bool("summary-json", false, "Print the final statistics as a JSON object on stdout, everything else goes to stderr")
+)
+
+// summaryOut is the real stdout while -summary-json diverts the rest of the
+// output to stderr
+var summaryOut = os.Stdout
+
+type summaryReport struct {
+	runSummary
+	Breaches []string          `json:"breaches,omitempty"`
+	SLO      *sloVerdict       `json:"slo,omitempty"`
+	Findings map[string]string `json:"findings,omitempty"`
+}
+
+// divertOutput sends the banner, progress and reports to stderr so stdout
+// only carries the summary, or discards them entirely for the TSV output
+func divertOutput() error {
+	switch {
+	case *tsvOutput:
+		f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		os.Stdout = f
+	case *summaryJSON:
+		os.Stdout = os.Stderr
+	}
+	return nil
+}
+
+func writeSummaryJSON(s runSummary) {
+	if !*summaryJSON {
+		return
+	}
+
+	r := summaryReport{runSummary: s, Breaches: checkThresholds(s)}
+	if len(objectives) > 0 {
+		v := evaluateSLO(s)
+		r.SLO = &v
+	}
+	if len(findings) > 0 {
+		r.Findings = make(map[string]string)
+		for _, f := range findings {
+			r.Findings[strings.TrimSuffix(f.name, ":")] = f.value
+		}
+	}
+	json.NewEncoder(summaryOut).Encode(r)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// SVCB and HTTPS are not known to the dns package yet, their answers are
+// decoded from the raw RDATA of the RFC 3597 records it hands back
+const (
+	typeSVCB  uint16 = 64
+	typeHTTPS uint16 = 65
+)
+
+// svcParamKeys are the SvcParamKeys of draft-ietf-dnsop-svcb-https
+var svcParamKeys = map[uint16]string{
+	0: "mandatory",
+	1: "alpn",
+	2: "no-default-alpn",
+	3: "port",
+	4: "ipv4hint",
+	5: "ech",
+	6: "ipv6hint",
+}
+
+type svcRecord struct {
+	Priority      uint16   `json:"priority"`
+	Target        string   `json:"target"`
+	Mandatory     []string `json:"mandatory,omitempty"`
+	ALPN          []string `json:"alpn,omitempty"`
+	NoDefaultALPN bool     `json:"no_default_alpn,omitempty"`
+	Port          uint16   `json:"port,omitempty"`
+	IPv4Hint      []string `json:"ipv4hint,omitempty"`
+	ECH           string   `json:"ech,omitempty"`
+	IPv6Hint      []string `json:"ipv6hint,omitempty"`
+	Other         []string `json:"other,omitempty"`
+}
+
+// parseType accepts the type names of the dns package as well as SVCB,
+// HTTPS and the generic TYPEnnn form
+func parseType(s string) (uint16, error) {
+	s = strings.ToUpper(s)
+	switch s {
+	case "SVCB":
+		return typeSVCB, nil
+	case "HTTPS":
+		return typeHTTPS, nil
+	}
+	if t, ok := dns.StringToType[s]; ok {
+		return t, nil
+	}
+	if strings.HasPrefix(s, "TYPE") {
+		if n, err := strconv.ParseUint(s[4:], 10, 16); err == nil {
+			return uint16(n), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown type %v", s)
+}
+
+func typeName(t uint16) string {
+	switch t {
+	case typeSVCB:
+		return "SVCB"
+	case typeHTTPS:
+		return "HTTPS"
+	}
+	return dns.Type(t).String()
+}
+
+// parseSVCB decodes an SVCB or HTTPS record, ok is false for any other
+// record
+func parseSVCB(rr dns.RR) (*svcRecord, bool) {
+	raw, ok := rr.(*dns.RFC3597)
+	if !ok || raw.Hdr.Rrtype != typeSVCB && raw.Hdr.Rrtype != typeHTTPS {
+		return nil, false
+	}
+	b, err := hex.DecodeString(raw.Rdata)
+	if err != nil || len(b) < 3 {
+		return nil, false
+	}
+
+	s := &svcRecord{Priority: binary.BigEndian.Uint16(b)}
+	target, off, err := dns.UnpackDomainName(b, 2)
+	if err != nil {
+		return nil, false
+	}
+	s.Target = target
+
+	for off+4 <= len(b) {
+		key := binary.BigEndian.Uint16(b[off:])
+		n := int(binary.BigEndian.Uint16(b[off+2:]))
+		off += 4
+		if off+n > len(b) {
+			return nil, false
+		}
+		s.setParam(key, b[off:off+n])
+		off += n
+	}
+	return s, true
+}
+
+func (s *svcRecord) setParam(key uint16, v []byte) {
+	switch key {
+	case 0:
+		for i := 0; i+2 <= len(v); i += 2 {
+			s.Mandatory = append(s.Mandatory, svcKeyName(binary.BigEndian.Uint16(v[i:])))
+		}
+	case 1:
+		for i := 0; i < len(v); {
+			n := int(v[i])
+			if i+1+n > len(v) {
+				break
+			}
+			s.ALPN = append(s.ALPN, string(v[i+1:i+1+n]))
+			i += 1 + n
+		}
+	case 2:
+		s.NoDefaultALPN = true
+	case 3:
+		if len(v) == 2 {
+			s.Port = binary.BigEndian.Uint16(v)
+		}
+	case 4:
+		for i := 0; i+net.IPv4len <= len(v); i += net.IPv4len {
+			s.IPv4Hint = append(s.IPv4Hint, net.IP(v[i:i+net.IPv4len]).String())
+		}
+	case 5:
+		s.ECH = base64.StdEncoding.EncodeToString(v)
+	case 6:
+		for i := 0; i+net.IPv6len <= len(v); i += net.IPv6len {
+			s.IPv6Hint = append(s.IPv6Hint, net.IP(v[i:i+net.IPv6len]).String())
+		}
+	default:
+		s.Other = append(s.Other, fmt.Sprintf("%v=%x", svcKeyName(key), v))
+	}
+}
+
+func svcKeyName(key uint16) string {
+	if n, ok := svcParamKeys[key]; ok {
+		return n
+	}
+	return fmt.Sprintf("key%d", key)
+}
+
+// String returns the presentation format of the record data
+func (s *svcRecord) String() string {
+	f := []string{strconv.Itoa(int(s.Priority)), s.Target}
+	if len(s.Mandatory) > 0 {
+		f = append(f, "mandatory="+strings.Join(s.Mandatory, ","))
+	}
+	if len(s.ALPN) > 0 {
+		f = append(f, "alpn="+strings.Join(s.ALPN, ","))
+	}
+	if s.NoDefaultALPN {
+		f = append(f, "no-default-alpn")
+	}
+	if s.Port != 0 {
+		f = append(f, fmt.Sprintf("port=%d", s.Port))
+	}
+	if len(s.IPv4Hint) > 0 {
+		f = append(f, "ipv4hint="+strings.Join(s.IPv4Hint, ","))
+	}
+	if s.ECH != "" {
+		f = append(f, "ech="+s.ECH)
+	}
+	if len(s.IPv6Hint) > 0 {
+		f = append(f, "ipv6hint="+strings.Join(s.IPv6Hint, ","))
+	}
+	return strings.Join(append(f, s.Other...), " ")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	fastOpen = flag.Bool("tfo", false, "Send the first query of stream connections in the SYN with TCP Fast Open where the platform supports it")
+)
+
+// tfoStats compares connections whose SYN carried data with those that
+// fell back to a full handshake, typically the first to a server while
+// no cookie is cached yet
+var tfoStats struct {
+	sync.Mutex
+	first [2][]time.Duration
+}
+
+// recordTFO notes whether a connection used fast open and how long it took
+// from the dial until its first answer, or handshake completion for DoT
+func recordTFO(used bool, d time.Duration) {
+	i := 0
+	if used {
+		i = 1
+	}
+	tfoStats.Lock()
+	tfoStats.first[i] = append(tfoStats.first[i], d)
+	tfoStats.Unlock()
+}
+
+func resetTFO() {
+	tfoStats.Lock()
+	tfoStats.first = [2][]time.Duration{}
+	tfoStats.Unlock()
+}
+
+func tfoReport() {
+	tfoStats.Lock()
+	defer tfoStats.Unlock()
+	without, with := tfoStats.first[0], tfoStats.first[1]
+	if len(without)+len(with) == 0 {
+		return
+	}
+	fmt.Printf("[+] TCP Fast Open:    %v of %v connections\n", len(with), len(with)+len(without))
+	if len(with) > 0 && len(without) > 0 {
+		fmt.Printf("[+]   %-16s%v / without %v\n", "First Answer:",
+			formatMs(meanDuration(with)), formatMs(meanDuration(without)))
+		fmt.Printf("[+]   %-16s%v\n", "Saved:", formatMs(meanDuration(without)-meanDuration(with)))
+	}
+}
@@ -0,0 +1,44 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// tcpiOptSynData is set in tcp_info options once the server acknowledged
+// data sent along with the SYN
+const tcpiOptSynData = 0x20
+
+// tfoControl makes connect return at once and defers the SYN to the first
+// write so the query can ride along (TCP_FASTOPEN_CONNECT, Linux 4.11)
+func tfoControl(network, address string, c syscall.RawConn) error {
+	var serr error
+	err := c.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN_CONNECT, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+func tfoUsed(c net.Conn) bool {
+	tc, ok := c.(*net.TCPConn)
+	if !ok {
+		return false
+	}
+	raw, err := tc.SyscallConn()
+	if err != nil {
+		return false
+	}
+	var info *unix.TCPInfo
+	raw.Control(func(fd uintptr) {
+		info, _ = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	})
+	return info != nil && info.Options&tcpiOptSynData != 0
+}
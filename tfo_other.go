@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+func tfoControl(network, address string, c syscall.RawConn) error {
+	return errors.New("TCP Fast Open is not supported on this platform")
+}
+
+func tfoUsed(c net.Conn) bool {
+	return false
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/rtmoranorg/dns-client-subnet-ext/graph"
+)
+
+var (
+	graphTitle  = flag.String("graph-title", "", "Title of every graph, {title} is replaced by the generated one")
+	graphXLabel = flag.String("graph-xlabel", "", "X axis label of every graph, {label} is replaced by the generated one")
+	graphYLabel = flag.String("graph-ylabel", "", "Y axis label of every graph, {label} is replaced by the generated one")
+	graphWidth  = flag.Int("graph-width", 0, "Width of graphs in pixels (default 650)")
+	graphHeight = flag.Int("graph-height", 0, "Height of graphs in pixels (default 400)")
+	graphTheme  = flag.String("graph-theme", "light", "Color theme of graphs (light|dark)")
+)
+
+func setupGraphs() error {
+	if *graphTheme != "light" && *graphTheme != "dark" {
+		return fmt.Errorf("unknown theme %v", *graphTheme)
+	}
+	if *graphWidth < 0 || *graphHeight < 0 {
+		return fmt.Errorf("graph size must not be negative")
+	}
+	graph.Settings = graph.Options{
+		Title:  *graphTitle,
+		XLabel: *graphXLabel,
+		YLabel: *graphYLabel,
+		Width:  *graphWidth,
+		Height: *graphHeight,
+		Dark:   *graphTheme == "dark",
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// exitThreshold is the exit status of a run that completed but exceeded an
+// alert threshold or missed an SLO, 2 is kept for runs abandoned because requests were
+// declined
+const exitThreshold = 3
+
+var (
+	maxFailPct = flag.Float64("max-fail-pct", -1, "Alert when more than PCT percent of the domains fail, negative disables")
+	maxP95Ms   = flag.Float64("max-p95-ms", -1, "Alert when the 95th percentile latency exceeds MS milliseconds, negative disables")
+)
+
+// checkThresholds returns a description of every alert threshold the run
+// exceeded
+func checkThresholds(s runSummary) []string {
+	var breaches []string
+	if *maxFailPct >= 0 && s.Attempts > 0 {
+		pct := 100 * float64(s.Fail) / float64(s.Attempts)
+		if pct > *maxFailPct {
+			breaches = append(breaches, fmt.Sprintf("fail rate %.1f%% exceeds %v%%", pct, *maxFailPct))
+		}
+	}
+	if *maxP95Ms >= 0 && s.LatencyP95 > *maxP95Ms {
+		breaches = append(breaches, fmt.Sprintf("p95 latency %.1fms exceeds %vms", s.LatencyP95, *maxP95Ms))
+	}
+	return breaches
+}
+
+// reportThresholds prints the failed checks and returns whether any did
+func reportThresholds(s runSummary) bool {
+	breaches := checkThresholds(s)
+	for _, b := range breaches {
+		fmt.Printf("[-] %-18s%v\n", "Threshold:", b)
+	}
+	return len(breaches) > 0
+}
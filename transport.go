@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	transport = flag.String("transport", "udp", "Transport of queries to the nameserver, dot is DNS over TLS on port 853 (udp|tcp|dot)")
+	tlsName   = flag.String("tls-name", "", "Server name to verify the DoT certificate against instead of the nameserver")
+)
+
+// streamConn frames messages with the two byte length of RFC 1035 4.2.2
+// so TCP and TLS connections can stand in for the UDP sockets, writes
+// are serialized since retries may race the writer on one connection
+type streamConn struct {
+	net.Conn
+	r  *bufio.Reader
+	mu sync.Mutex
+
+	// tcp and dialed are kept until the first answer of a fast open
+	// connection, only then is it known whether the SYN carried the query
+	tcp    net.Conn
+	dialed time.Time
+}
+
+func (c *streamConn) Write(b []byte) (int, error) {
+	buf := make([]byte, 2+len(b))
+	binary.BigEndian.PutUint16(buf, uint16(len(b)))
+	copy(buf[2:], b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.Conn.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *streamConn) Read(b []byte) (int, error) {
+	var l [2]byte
+	if _, err := io.ReadFull(c.r, l[:]); err != nil {
+		return 0, err
+	}
+	n := int(binary.BigEndian.Uint16(l[:]))
+	if n > len(b) {
+		if _, err := c.r.Discard(n); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("message of %d bytes exceeds buffer", n)
+	}
+	n, err := io.ReadFull(c.r, b[:n])
+	if err == nil && c.tcp != nil {
+		recordTFO(tfoUsed(c.tcp), time.Since(c.dialed))
+		c.tcp = nil
+	}
+	return n, err
+}
+
+// streamAddr moves the default port to 853 for DoT, a port given along
+// with the nameserver is kept
+func streamAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || *transport != "dot" || port != "53" {
+		return addr
+	}
+	return net.JoinHostPort(host, "853")
+}
+
+// dotConfig verifies the certificate against -tls-name or the nameserver
+// and checks any -pin on top of the chain
+func dotConfig() *tls.Config {
+	name := *tlsName
+	if name == "" {
+		name = *nameserver
+	}
+	return &tls.Config{ServerName: name, VerifyPeerCertificate: verifyPins,
+		ClientSessionCache: tlsSessions}
+}
+
+// dialStream connects and, for DoT, runs the handshake as a separate step
+// so both can be timed. With -tfo the connect returns before the SYN is
+// even sent, its round trip shows up in the first answer or handshake.
+func dialStream(addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: retryDelay}
+	if *fastOpen {
+		d.Control = tfoControl
+	}
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), retryDelay)
+	c, err := dialTCP(ctx, d, streamAddr(addr))
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	recordConnect(time.Since(start))
+	raw := c
+
+	if *transport == "dot" {
+		start = time.Now()
+		tc := tls.Client(c, dotConfig())
+		tc.SetDeadline(start.Add(retryDelay))
+		if err := tc.Handshake(); err != nil {
+			c.Close()
+			return nil, err
+		}
+		tc.SetDeadline(time.Time{})
+		recordHandshake(streamAddr(addr), time.Since(start), tc.ConnectionState().DidResume)
+		if *fastOpen {
+			recordTFO(tfoUsed(raw), time.Since(start))
+		}
+		return &streamConn{Conn: tc, r: bufio.NewReader(tc)}, nil
+	}
+	sc := &streamConn{Conn: c, r: bufio.NewReader(c)}
+	if *fastOpen {
+		sc.tcp, sc.dialed = raw, start
+	}
+	return sc, nil
+}
+
+// exchangeStream is exchangeAt over the stream transport, used by the
+// preflight and probes so they reach the server the way the run does
+func exchangeStream(m *dns.Msg, addr string) (*dns.Msg, time.Duration, error) {
+	c, err := dialStream(addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer c.Close()
+
+	co := &dns.Conn{Conn: c.(*streamConn).Conn}
+	if tsig != nil {
+		co.TsigSecret = tsig.secrets()
+		tsig.sign(m)
+	}
+	co.SetDeadline(time.Now().Add(retryDelay))
+	start := time.Now()
+	if err := co.WriteMsg(m); err != nil {
+		return nil, 0, err
+	}
+	r, err := co.ReadMsg()
+	return r, time.Since(start), err
+}
+
+func setupTransport() error {
+	switch *transport {
+	case "udp":
+	case "tcp", "dot":
+		if *localMode != "" || *dohURL != "" || *iterative {
+			return fmt.Errorf("%v cannot be combined with -local, -doh or -iterative", *transport)
+		}
+	default:
+		return fmt.Errorf("unknown transport %v", *transport)
+	}
+	if *fastOpen && *transport == "udp" {
+		return fmt.Errorf("-tfo needs -transport tcp or dot")
+	}
+	return nil
+}
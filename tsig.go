@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	tsigFlag = flag.String("tsig", "", "TSIG key as [algorithm:]name:secret (base64) to sign queries and zone transfers with")
+)
+
+// tsigFudge is the clock skew allowed between client and server
+const tsigFudge = 300
+
+// tsigAlgorithms are the accepted algorithm names, the default is
+// hmac-sha256 like dig -y
+var tsigAlgorithms = map[string]string{
+	"hmac-md5":    dns.HmacMD5,
+	"hmac-sha1":   dns.HmacSHA1,
+	"hmac-sha256": dns.HmacSHA256,
+	"hmac-sha512": dns.HmacSHA512,
+}
+
+type tsigKey struct {
+	name      string
+	algorithm string
+	secret    string
+}
+
+// tsig is the parsed -tsig, nil when no key is configured
+var tsig *tsigKey
+
+func parseTSIG(s string) (*tsigKey, error) {
+	f := strings.Split(s, ":")
+	alg := "hmac-sha256"
+	switch len(f) {
+	case 2:
+	case 3:
+		alg, f = strings.ToLower(f[0]), f[1:]
+	default:
+		return nil, fmt.Errorf("expected [algorithm:]name:secret")
+	}
+	a, ok := tsigAlgorithms[alg]
+	if !ok {
+		return nil, fmt.Errorf("unknown algorithm %v", alg)
+	}
+	if _, err := base64.StdEncoding.DecodeString(f[1]); err != nil {
+		return nil, fmt.Errorf("secret is not base64: %v", err)
+	}
+	return &tsigKey{dns.Fqdn(strings.ToLower(f[0])), a, f[1]}, nil
+}
+
+func (k *tsigKey) sign(m *dns.Msg) {
+	m.SetTsig(k.name, k.algorithm, tsigFudge, time.Now().Unix())
+}
+
+func (k *tsigKey) secrets() map[string]string {
+	return map[string]string{k.name: k.secret}
+}
+
+// verifyTSIG checks the response to a signed query carries a valid TSIG
+// computed over the MAC of the latest attempt
+func verifyTSIG(dr *domainRecord, da *domainAnswer) error {
+	if tsig == nil {
+		return nil
+	}
+	t := da.msg.IsTsig()
+	if t == nil {
+		return fmt.Errorf("response not signed")
+	}
+	if t.Error != dns.RcodeSuccess {
+		return fmt.Errorf("server reported %v", dns.RcodeToString[int(t.Error)])
+	}
+	mac, _ := dr.mac.Load().(string)
+	return dns.TsigVerify(da.raw, tsig.secret, mac, false)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var (
+	tsvOutput = flag.Bool("tsv", false, "Print one tab separated line per domain (name, rcode, latency ms, answers) and nothing else")
+)
+
+var tsvWriter *bufio.Writer
+
+func writeTSV(r queryResult) {
+	if tsvWriter == nil {
+		tsvWriter = bufio.NewWriter(summaryOut)
+	}
+	answers := strings.Join(r.IPs, ",")
+	if answers == "" {
+		answers = "-"
+	}
+	fmt.Fprintf(tsvWriter, "%v\t%v\t%.3f\t%v\n", r.Domain, r.Rcode, r.Latency, answers)
+}
+
+func flushTSV() {
+	if tsvWriter != nil {
+		tsvWriter.Flush()
+	}
+}
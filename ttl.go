@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	ttlPerDomain = flag.Bool("ttl-domains", false, "Write the answer TTLs of every domain to the output directory")
+)
+
+// ttlBuckets are the upper bounds (exclusive) of the reported TTL ranges
+var ttlBuckets = []struct {
+	max   uint32
+	label string
+}{
+	{1, "0s"},
+	{60, "<1m"},
+	{300, "1m-5m"},
+	{3600, "5m-1h"},
+	{86400, "1h-1d"},
+	{^uint32(0), ">=1d"},
+}
+
+var (
+	ttlValues []uint32
+	ttlDomain = make(map[string][]uint32)
+)
+
+func recordTTLs(domain string, msg *dns.Msg) {
+	ttls := make([]uint32, 0, len(msg.Answer))
+	for _, a := range msg.Answer {
+		ttls = append(ttls, a.Header().Ttl)
+	}
+
+	ttlValues = append(ttlValues, ttls...)
+	if *ttlPerDomain {
+		ttlDomain[domain] = ttls
+	}
+}
+
+func ttlStats() {
+	if len(ttlValues) == 0 {
+		return
+	}
+
+	sorted := make([]uint32, len(ttlValues))
+	copy(sorted, ttlValues)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	fmt.Printf("[+] TTLs:             min %v / median %v / max %v\n",
+		sorted[0], sorted[len(sorted)/2], sorted[len(sorted)-1])
+
+	counts := make([]int, len(ttlBuckets))
+	for _, t := range sorted {
+		for i, b := range ttlBuckets {
+			if t < b.max || i == len(ttlBuckets)-1 {
+				counts[i]++
+				break
+			}
+		}
+	}
+	for i, b := range ttlBuckets {
+		fmt.Printf("[+]   %-16s%v (%.1f%%)\n", b.label+":", counts[i],
+			float64(counts[i])/float64(len(sorted))*100)
+	}
+
+	if *ttlPerDomain {
+		writeDomainTTLs()
+	}
+}
+
+func writeDomainTTLs() {
+	f, err := createOutputFile("ttl", "tsv")
+	if err != nil {
+		fmt.Printf("Error writing TTL file\n%v\n", err)
+		return
+	}
+	defer f.Close()
+
+	domains := make([]string, 0, len(ttlDomain))
+	for d := range ttlDomain {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+
+	for _, d := range domains {
+		fmt.Fprintf(f, "%v", d)
+		for _, t := range ttlDomain[d] {
+			fmt.Fprintf(f, "\t%v", t)
+		}
+		fmt.Fprintln(f)
+	}
+}
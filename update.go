@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	updateCheck   = flag.String("update-check", "", "Comma separated servers the update command polls for the new records (default the nameserver)")
+	updatePoll    = flag.Duration("update-poll", 100*time.Millisecond, "Interval the update command re-queries for a record until it is visible")
+	updateTimeout = flag.Duration("update-timeout", 30*time.Second, "Time after which the update command gives up waiting for a record")
+)
+
+type updateResult struct {
+	rtt     time.Duration
+	visible map[string]time.Duration
+}
+
+// sendUpdate sends an RFC 2136 update to the nameserver, TSIG signed when
+// a key is configured
+func sendUpdate(zone string, insert, remove []dns.RR) (time.Duration, error) {
+	m := new(dns.Msg)
+	m.SetUpdate(zone)
+	if len(insert) > 0 {
+		m.Insert(insert)
+	}
+	if len(remove) > 0 {
+		m.Remove(remove)
+	}
+	r, rtt, err := exchangeMsg(m)
+	if err != nil {
+		return 0, err
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		return rtt, fmt.Errorf("update refused with %v", rcodeName(r.Rcode))
+	}
+	return rtt, nil
+}
+
+// waitVisible polls server until the TXT record carries value, returning
+// the time since the update was sent
+func waitVisible(server, name, value string, start time.Time) (time.Duration, bool) {
+	addr := net.JoinHostPort(server, "53")
+	for time.Since(start) < *updateTimeout {
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeTXT)
+		if r, _, err := exchangeAt(m, addr); err == nil {
+			for _, a := range r.Answer {
+				if t, ok := a.(*dns.TXT); ok && strings.Join(t.Txt, "") == value {
+					return time.Since(start), true
+				}
+			}
+		}
+		time.Sleep(*updatePoll)
+	}
+	return 0, false
+}
+
+// runUpdate adds and removes TXT records below zone, measuring how long
+// each update takes to be answered and to become visible on every server
+func runUpdate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected a zone and optionally the number of updates")
+	}
+	zone := dns.Fqdn(strings.ToLower(args[0]))
+	count := 10
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid update count %v", args[1])
+		}
+		count = n
+	}
+	servers := []string{*nameserver}
+	if *updateCheck != "" {
+		servers = strings.Split(*updateCheck, ",")
+	}
+
+	fmt.Printf("Dynamic Update Test\n"+
+		"[+] Nameserver:       %v\n"+
+		"[+] Zone:             %v\n"+
+		"[+] Updates:          %v\n"+
+		"[+] TSIG:             %v\n\n", *nameserver, zone, count, tsig != nil)
+
+	var rtts []time.Duration
+	visible := make(map[string][]time.Duration)
+	missing := make(map[string]int)
+	failed := 0
+	for i := 0; i < count; i++ {
+		name := randomLabel(12) + "." + zone
+		value := strconv.FormatInt(time.Now().UnixNano(), 10)
+		rr, err := dns.NewRR(fmt.Sprintf("%v 60 IN TXT %q", name, value))
+		if err != nil {
+			return err
+		}
+
+		sent := time.Now()
+		rtt, err := sendUpdate(zone, []dns.RR{rr}, nil)
+		if err != nil {
+			failed++
+			fmt.Printf("[-] %-40v %v\n", name, err)
+			continue
+		}
+		rtts = append(rtts, rtt)
+
+		for _, s := range servers {
+			s = strings.TrimSpace(s)
+			d, ok := waitVisible(s, name, value, sent)
+			if !ok {
+				missing[s]++
+				continue
+			}
+			visible[s] = append(visible[s], d)
+		}
+		if _, err := sendUpdate(zone, nil, []dns.RR{rr}); err != nil {
+			fmt.Printf("[-] %-40v cleanup: %v\n", name, err)
+		}
+	}
+
+	if len(rtts) == 0 {
+		return fmt.Errorf("no update was accepted")
+	}
+	fmt.Printf("[+] Accepted:         %v of %v\n"+
+		"[+] Update RTT:       %v avg / %v median\n",
+		len(rtts), count, avgDuration(rtts), medianDuration(rtts))
+	fmt.Printf("[+] Visible After:\n")
+	for _, s := range servers {
+		s = strings.TrimSpace(s)
+		v := visible[s]
+		fmt.Printf("[+]   %-16s%v avg / %v median (%v not visible)\n", s+":",
+			avgDuration(v).Round(time.Millisecond), medianDuration(v).Round(time.Millisecond), missing[s])
+	}
+	return nil
+}
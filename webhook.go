@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+var (
+	webhookURL = flag.String("webhook", "", "POST a JSON summary of every run to this URL")
+	webhookOn  = flag.String("webhook-on", "always", "Send the webhook always or only on threshold breach")
+	webhookFmt = flag.String("webhook-format", "json", "Format of the webhook body: json, slack or teams")
+)
+
+type webhookEvent struct {
+	Event    string     `json:"event"`
+	Summary  runSummary `json:"summary"`
+	Breaches []string   `json:"breaches,omitempty"`
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// notifyRun reports a finished run to the configured webhook, delivery
+// failures are only warned about so they never fail the run itself
+func notifyRun(s runSummary) {
+	if *webhookURL == "" {
+		return
+	}
+
+	e := webhookEvent{Event: "run_completed", Summary: s, Breaches: checkThresholds(s)}
+	if len(e.Breaches) > 0 {
+		e.Event = "threshold_breached"
+	} else if *webhookOn == "breach" {
+		return
+	}
+
+	var msg interface{} = e
+	switch *webhookFmt {
+	case "slack":
+		msg = slackMessage(e)
+	case "teams":
+		msg = teamsMessage(e)
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "webhook: %s\n", err)
+		return
+	}
+	resp, err := webhookClient.Post(*webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "webhook: %s\n", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		fmt.Fprintf(os.Stderr, "webhook: %s answered %v\n", *webhookURL, resp.Status)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	soaResolver = flag.Bool("soa-resolver", false, "Include the nameserver under test in the soa command")
+)
+
+type authServer struct {
+	name string
+	addr string
+}
+
+func (a authServer) String() string {
+	return fmt.Sprintf("%v (%v)", a.name, a.addr)
+}
+
+// lookupAddrs resolves the A and AAAA records of name through the
+// configured nameserver
+func lookupAddrs(name string) []string {
+	var addrs []string
+	for _, t := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		r, _, err := exchange(name, t, dns.ClassINET)
+		if err != nil {
+			continue
+		}
+		for _, a := range r.Answer {
+			switch rr := a.(type) {
+			case *dns.A:
+				addrs = append(addrs, rr.A.String())
+			case *dns.AAAA:
+				addrs = append(addrs, rr.AAAA.String())
+			}
+		}
+	}
+	return addrs
+}
+
+// zoneServers discovers the NS set of zone and every address it serves on
+func zoneServers(zone string) ([]authServer, error) {
+	r, _, err := exchange(zone, dns.TypeNS, dns.ClassINET)
+	if err != nil {
+		return nil, fmt.Errorf("NS lookup for %v: %v", zone, err)
+	}
+
+	var servers []authServer
+	for _, a := range r.Answer {
+		ns, ok := a.(*dns.NS)
+		if !ok {
+			continue
+		}
+		for _, addr := range lookupAddrs(ns.Ns) {
+			servers = append(servers, authServer{strings.ToLower(ns.Ns), addr})
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no nameservers found for %v (%v)", zone, rcodeName(r.Rcode))
+	}
+
+	sort.Slice(servers, func(i, j int) bool {
+		if servers[i].name != servers[j].name {
+			return servers[i].name < servers[j].name
+		}
+		return servers[i].addr < servers[j].addr
+	})
+	return servers, nil
+}
+
+// queryAuthoritative asks server directly, without recursion, for name
+func queryAuthoritative(s authServer, name string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.RecursionDesired = false
+
+	r, _, err := exchangeAt(m, net.JoinHostPort(s.addr, "53"))
+	return r, err
+}
+
+// answerKey summarizes a response independent of TTLs and record order
+func answerKey(r *dns.Msg, err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	data := make([]string, 0, len(r.Answer))
+	for _, a := range r.Answer {
+		data = append(data, dns.Type(a.Header().Rrtype).String()+" "+rrData(a))
+	}
+	sort.Strings(data)
+	return strings.TrimSpace(rcodeName(r.Rcode) + " " + strings.Join(data, " "))
+}
+
+// zoneNames returns the names to check inside zone, taken from the domain
+// list when one is given, names outside the zone are treated as relative
+func zoneNames(zone string) ([]string, error) {
+	if *domainList == "" {
+		return []string{zone}, nil
+	}
+
+	in, err := GetDomains(*domainList)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(in))
+	for _, n := range in {
+		n = dns.Fqdn(strings.TrimSpace(n))
+		if n == "." {
+			continue
+		}
+		if !dns.IsSubDomain(zone, n) {
+			n = n + zone
+		}
+		names = append(names, n)
+	}
+	return names, nil
+}
+
+func runPropagation(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected a single zone")
+	}
+	zone := dns.Fqdn(strings.ToLower(args[0]))
+
+	servers, err := zoneServers(zone)
+	if err != nil {
+		return err
+	}
+	names, err := zoneNames(zone)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Propagation Check\n"+
+		"[+] Zone:             %v\n"+
+		"[+] Servers:          %v\n"+
+		"[+] Names:            %v\n\n",
+		zone, len(servers), len(names))
+
+	inconsistent := 0
+	for _, n := range names {
+		groups := make(map[string][]string)
+		for _, s := range servers {
+			r, err := queryAuthoritative(s, n, dns.TypeA)
+			checkLame(zone, s, r, err)
+			k := answerKey(r, err)
+			if err == nil && !r.Authoritative {
+				k += " (not authoritative)"
+			}
+			groups[k] = append(groups[k], s.String())
+		}
+		if len(groups) == 1 {
+			continue
+		}
+
+		inconsistent++
+		fmt.Printf("[-] %v\n", n)
+		for k, s := range groups {
+			fmt.Printf("      %v\n        %v\n", k, strings.Join(s, "\n        "))
+		}
+	}
+
+	fmt.Printf("\n[+] Inconsistent:     %v of %v names\n", inconsistent, len(names))
+	lameReport()
+	return nil
+}
+
+// serialBehind returns how far serial a trails serial b using RFC 1982
+// arithmetic so that wrapped serials compare correctly
+func serialBehind(a, b uint32) int64 {
+	return int64(int32(b - a))
+}
+
+func runSOA(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected a single zone")
+	}
+	zone := dns.Fqdn(strings.ToLower(args[0]))
+
+	servers, err := zoneServers(zone)
+	if err != nil {
+		return err
+	}
+	if *soaResolver {
+		servers = append(servers, authServer{"resolver", *nameserver})
+	}
+
+	fmt.Printf("SOA Serial Check\n"+
+		"[+] Zone:             %v\n"+
+		"[+] Servers:          %v\n\n",
+		zone, len(servers))
+
+	serials := make(map[authServer]uint32)
+	var newest uint32
+	found := false
+	for _, s := range servers {
+		m := new(dns.Msg)
+		m.SetQuestion(zone, dns.TypeSOA)
+		m.RecursionDesired = s.name == "resolver"
+
+		r, _, err := exchangeAt(m, net.JoinHostPort(s.addr, "53"))
+		if s.name != "resolver" {
+			checkLame(zone, s, r, err)
+		}
+		if err != nil {
+			fmt.Printf("[-] %-40v %v\n", s, err)
+			continue
+		}
+
+		var soa *dns.SOA
+		for _, a := range r.Answer {
+			if rr, ok := a.(*dns.SOA); ok {
+				soa = rr
+			}
+		}
+		if soa == nil {
+			fmt.Printf("[-] %-40v no SOA (%v)\n", s, rcodeName(r.Rcode))
+			continue
+		}
+
+		serials[s] = soa.Serial
+		if !found || serialBehind(newest, soa.Serial) > 0 {
+			newest = soa.Serial
+			found = true
+		}
+	}
+
+	var skew int64
+	for _, s := range servers {
+		serial, ok := serials[s]
+		if !ok {
+			continue
+		}
+		behind := serialBehind(serial, newest)
+		if behind > skew {
+			skew = behind
+		}
+		mark := "+"
+		if behind > 0 {
+			mark = "-"
+		}
+		fmt.Printf("[%v] %-40v %v (behind %v)\n", mark, s, serial, behind)
+	}
+
+	fmt.Printf("\n[+] Newest Serial:    %v\n"+
+		"[+] Serial Skew:      %v\n", newest, skew)
+	lameReport()
+	return nil
+}